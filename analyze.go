@@ -0,0 +1,78 @@
+package hashtable
+
+import "math"
+
+// Distribution describes how evenly keys are spread across the buckets of a table.
+type Distribution struct {
+	// Buckets is the number of buckets and Keys the number of keys placed in them.
+	Buckets, Keys int
+	// Empty is the number of buckets that hold no keys.
+	Empty int
+	// Longest is the length of the longest bucket, the worst-case number of keys
+	// compared by a lookup.
+	Longest int
+	// Skew is Longest divided by the mean bucket length. A good hash keeps it
+	// small, though it grows slowly with the number of buckets even for a perfect one.
+	Skew float64
+	// ChiSquared is Pearson's chi-squared statistic of the bucket lengths against
+	// a uniform distribution. For a good hash it is close to Buckets-1.
+	ChiSquared float64
+}
+
+// Uniform reports whether ChiSquared is within three standard deviations of its
+// expected value for a uniformly distributed hash. Keys that are not distinct, or
+// far fewer keys than buckets, make the test unreliable.
+func (d Distribution) Uniform() bool {
+	if d.Buckets < 2 {
+		return true
+	}
+	df := float64(d.Buckets - 1)
+	return math.Abs(d.ChiSquared-df) <= 3*math.Sqrt(2*df)
+}
+
+// Distribution reports how evenly the stored keys are spread across the buckets.
+func (ht *HashTable[K, V]) Distribution() Distribution {
+	positions, _ := ht.store.slots()
+	counts := make([]int, positions)
+	ht.store.all(func(data *kv[K, V]) bool {
+		counts[data.hash%uint64(positions)]++
+		return true
+	})
+	return newDistribution(counts, ht.Len())
+}
+
+// AnalyzeHasher reports how evenly h would spread keys across a table with n number
+// of internal buckets, so a custom Hasher can be checked against a sample of real
+// keys before it is used. The keys should be distinct. A number of buckets below one
+// is treated as one, as by New.
+func AnalyzeHasher[K any](h Hasher[K], keys []K, numberOfBuckets int) Distribution {
+	numberOfBuckets = max(numberOfBuckets, 1)
+	counts := make([]int, numberOfBuckets)
+	for _, key := range keys {
+		counts[h.Hash(key)%uint64(numberOfBuckets)]++
+	}
+	return newDistribution(counts, len(keys))
+}
+
+// newDistribution computes a Distribution from the length of each bucket.
+func newDistribution(counts []int, keys int) Distribution {
+	d := Distribution{Buckets: len(counts), Keys: keys}
+	if d.Buckets == 0 {
+		return d
+	}
+	mean := float64(keys) / float64(d.Buckets)
+	for _, c := range counts {
+		if c == 0 {
+			d.Empty++
+		}
+		d.Longest = max(d.Longest, c)
+		if mean > 0 {
+			diff := float64(c) - mean
+			d.ChiSquared += diff * diff / mean
+		}
+	}
+	if mean > 0 {
+		d.Skew = float64(d.Longest) / mean
+	}
+	return d
+}
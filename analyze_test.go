@@ -0,0 +1,58 @@
+package hashtable
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/jkittell/hashtable/hashers"
+)
+
+func TestAnalyzeHasher(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+
+	good := AnalyzeHasher[string](hashers.String{Seed: 1}, keys, 64)
+	if !good.Uniform() {
+		t.Errorf("FNV-1a: ChiSquared = %f, want close to 63", good.ChiSquared)
+	}
+	if good.Keys != len(keys) || good.Empty != 0 || good.Skew > 2 {
+		t.Errorf("FNV-1a: %+v", good)
+	}
+
+	// hashing by length sends every key of the same length to the same bucket
+	bad := AnalyzeHasher[string](HasherFunc[string](func(s string) uint64 {
+		return uint64(len(s))
+	}), keys, 64)
+	if bad.Uniform() {
+		t.Errorf("length hash: Uniform() = true, ChiSquared = %f", bad.ChiSquared)
+	}
+	if bad.Empty != 64-4 || bad.Longest != 9000 {
+		t.Errorf("length hash: Empty = %d, Longest = %d, want 60, 9000", bad.Empty, bad.Longest)
+	}
+}
+
+func TestAnalyzeHasher_NoBuckets(t *testing.T) {
+	keys := []string{"a", "b", "c"}
+	for _, n := range []int{0, -1} {
+		d := AnalyzeHasher[string](hashers.String{Seed: 1}, keys, n)
+		want := Distribution{Buckets: 1, Keys: 3, Longest: 3, Skew: 1}
+		if d != want {
+			t.Errorf("AnalyzeHasher with %d buckets = %+v, want %+v", n, d, want)
+		}
+	}
+}
+
+func TestHashTable_Distribution(t *testing.T) {
+	ht := New(4, withHashInt[int]())
+	for _, k := range []int{0, 4, 8, 1} {
+		ht.Insert(k, k)
+	}
+
+	d := ht.Distribution()
+	want := Distribution{Buckets: 4, Keys: 4, Empty: 2, Longest: 3, Skew: 3, ChiSquared: 6}
+	if d != want {
+		t.Fatalf("Distribution() = %+v, want %+v", d, want)
+	}
+}
@@ -0,0 +1,300 @@
+package hashtable
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// asyncRingSize is the number of writes AsyncInsert can queue before producers
+	// wait for the applier to catch up.
+	asyncRingSize = 1 << 12
+	// asyncBatch is the number of queued writes the applier applies at a time.
+	asyncBatch = 256
+)
+
+// A ring is a bounded queue that any number of goroutines push to and one goroutine
+// pops from, without locks. Each slot carries a sequence number telling whether it
+// is free for the push of a given position or holds the value for its pop.
+type ring[T any] struct {
+	slots []ringSlot[T]
+	mask  uint64
+	// tail is the position of the next push. head, the position of the next pop,
+	// is only used by the popping goroutine.
+	tail atomic.Uint64
+	head uint64
+}
+
+type ringSlot[T any] struct {
+	seq   atomic.Uint64
+	value T
+}
+
+func newRing[T any](size int) *ring[T] {
+	r := &ring[T]{slots: make([]ringSlot[T], size), mask: uint64(size - 1)}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// push appends value, calling full and yielding the processor for as long as the
+// ring has no free slot.
+func (r *ring[T]) push(value T, full func()) {
+	for {
+		pos := r.tail.Load()
+		slot := &r.slots[pos&r.mask]
+		switch seq := slot.seq.Load(); {
+		case seq == pos:
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				slot.value = value
+				slot.seq.Store(pos + 1)
+				return
+			}
+		case seq < pos:
+			// the slot still holds the value pushed one lap ago
+			full()
+			runtime.Gosched()
+		}
+		// otherwise another goroutine pushed to pos first
+	}
+}
+
+// ready reports whether the next pop will return a value.
+func (r *ring[T]) ready() bool {
+	return r.slots[r.head&r.mask].seq.Load() == r.head+1
+}
+
+// pop removes and returns the oldest value, if one has been pushed.
+func (r *ring[T]) pop() (T, bool) {
+	var zero T
+	slot := &r.slots[r.head&r.mask]
+	if slot.seq.Load() != r.head+1 {
+		return zero, false
+	}
+	value := slot.value
+	slot.value = zero
+	slot.seq.Store(r.head + r.mask + 1)
+	r.head++
+	return value, true
+}
+
+// An asyncOp is an item queued for an asyncApplier, such as a write queued by
+// AsyncInsert, or a marker queued by Flush, with a channel to close once it is
+// reached, or by Close.
+type asyncOp[T any] struct {
+	item  T
+	flush chan struct{}
+	stop  bool
+}
+
+// An asyncApplier applies the items queued in its ring from a goroutine of its own.
+type asyncApplier[T any] struct {
+	ring  *ring[asyncOp[T]]
+	apply func([]T)
+	// sleeping is set while the applier waits on wake for writes to be queued.
+	sleeping atomic.Bool
+	wake     chan struct{}
+	// closed is set by Close, after which no more writes are queued; inflight
+	// counts the goroutines that may be queuing one nonetheless.
+	closed   atomic.Bool
+	inflight atomic.Int64
+	once     sync.Once
+	exited   chan struct{}
+}
+
+func startAsync[T any](apply func([]T)) *asyncApplier[T] {
+	a := &asyncApplier[T]{
+		ring:   newRing[asyncOp[T]](asyncRingSize),
+		apply:  apply,
+		wake:   make(chan struct{}, 1),
+		exited: make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// run applies queued items in batches until it pops the marker queued by Close.
+func (a *asyncApplier[T]) run() {
+	defer close(a.exited)
+	batch := make([]T, 0, asyncBatch)
+	for {
+		op, ok := a.ring.pop()
+		switch {
+		case !ok && len(batch) > 0:
+			// apply what has been queued so far rather than wait for a full batch
+			a.apply(batch)
+			batch = batch[:0]
+		case !ok:
+			a.sleep()
+		case op.flush != nil || op.stop:
+			if len(batch) > 0 {
+				a.apply(batch)
+				batch = batch[:0]
+			}
+			if op.stop {
+				return
+			}
+			close(op.flush)
+		default:
+			batch = append(batch, op.item)
+			if len(batch) == cap(batch) {
+				a.apply(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// sleep waits until an item may have been queued.
+func (a *asyncApplier[T]) sleep() {
+	a.sleeping.Store(true)
+	// a write queued before sleeping was set would not wake the applier
+	if a.ring.ready() {
+		a.sleeping.Store(false)
+		return
+	}
+	<-a.wake
+	a.sleeping.Store(false)
+}
+
+// notify wakes the applier if it sleeps.
+func (a *asyncApplier[T]) notify() {
+	if a.sleeping.Load() && a.sleeping.CompareAndSwap(true, false) {
+		select {
+		case a.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// enqueue queues op, reporting false if Close has been called.
+func (a *asyncApplier[T]) enqueue(op asyncOp[T]) bool {
+	a.inflight.Add(1)
+	defer a.inflight.Add(-1)
+	if a.closed.Load() {
+		return false
+	}
+	a.ring.push(op, a.notify)
+	a.notify()
+	return true
+}
+
+// queue queues item, or reports false if Close has been called.
+func (a *asyncApplier[T]) queue(item T) bool {
+	return a.enqueue(asyncOp[T]{item: item})
+}
+
+// flush waits until every item queued before it has been applied.
+func (a *asyncApplier[T]) flush() {
+	done := make(chan struct{})
+	if a.enqueue(asyncOp[T]{flush: done}) {
+		<-done
+	}
+}
+
+// close stops queuing items and waits until those already queued are applied.
+func (a *asyncApplier[T]) close() {
+	a.once.Do(func() {
+		a.closed.Store(true)
+		for a.inflight.Load() > 0 {
+			runtime.Gosched()
+		}
+		a.ring.push(asyncOp[T]{stop: true}, a.notify)
+		a.notify()
+	})
+	<-a.exited
+}
+
+// asyncApplier returns the applier of s, starting it on first use, or nil if Close
+// was called before any use.
+func (s *SyncHashTable[K, V]) asyncApplier() *asyncApplier[Entry[K, V]] {
+	s.asyncOnce.Do(func() {
+		s.async.Store(startAsync(func(entries []Entry[K, V]) {
+			s.lock()
+			defer s.mu.Unlock()
+			for _, e := range entries {
+				s.ht.Insert(e.Key, e.Value)
+				s.touch(e.Key)
+			}
+		}))
+	})
+	return s.async.Load()
+}
+
+// AsyncInsert queues an insert of value for key and returns without waiting for it,
+// for workloads such as telemetry where producers must not wait for the lock of the
+// table. The queued writes are applied in order, in batches, by a goroutine started
+// on the first call, so the table reflects a write shortly after AsyncInsert
+// returns. Reads see it for certain after Flush. AsyncInsert waits only while 4096
+// writes are already queued. After Close it inserts value right away.
+func (s *SyncHashTable[K, V]) AsyncInsert(key K, value V) {
+	if a := s.asyncApplier(); a == nil || !a.queue(Entry[K, V]{Key: key, Value: value}) {
+		s.Insert(key, value)
+	}
+}
+
+// Flush waits until every write queued by AsyncInsert before Flush was called has
+// been applied, and every change queued by WithWriteBehind has been written.
+func (s *SyncHashTable[K, V]) Flush() {
+	if a := s.async.Load(); a != nil {
+		a.flush()
+	}
+	s.flushWrites()
+}
+
+// Close applies every write queued by AsyncInsert and stops the goroutine that
+// applies them, and likewise for the changes queued by WithWriteBehind. s remains
+// usable; later calls of AsyncInsert insert right away, and later changes are
+// written through.
+func (s *SyncHashTable[K, V]) Close() {
+	// without an applier yet, keep AsyncInsert from starting one
+	s.asyncOnce.Do(func() {})
+	if a := s.async.Load(); a != nil {
+		a.close()
+	}
+	s.closeWrites()
+}
+
+// asyncApplier returns the applier of s, starting it on first use, or nil if Close
+// was called before any use.
+func (s *ShardedHashTable[K, V]) asyncApplier() *asyncApplier[Entry[K, V]] {
+	s.asyncOnce.Do(func() {
+		s.async.Store(startAsync(func(entries []Entry[K, V]) {
+			for _, e := range entries {
+				s.Insert(e.Key, e.Value)
+			}
+		}))
+	})
+	return s.async.Load()
+}
+
+// AsyncInsert is like SyncHashTable.AsyncInsert. One goroutine applies the queued
+// writes of every shard.
+func (s *ShardedHashTable[K, V]) AsyncInsert(key K, value V) {
+	if a := s.asyncApplier(); a == nil || !a.queue(Entry[K, V]{Key: key, Value: value}) {
+		s.Insert(key, value)
+	}
+}
+
+// Flush is like SyncHashTable.Flush.
+func (s *ShardedHashTable[K, V]) Flush() {
+	if a := s.async.Load(); a != nil {
+		a.flush()
+	}
+	for _, shard := range s.shards {
+		shard.flushWrites()
+	}
+}
+
+// Close is like SyncHashTable.Close.
+func (s *ShardedHashTable[K, V]) Close() {
+	s.asyncOnce.Do(func() {})
+	if a := s.async.Load(); a != nil {
+		a.close()
+	}
+	for _, shard := range s.shards {
+		shard.closeWrites()
+	}
+}
@@ -0,0 +1,83 @@
+package hashtable
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAsyncInsert(t *testing.T) {
+	const producers, perProducer = 8, 3000
+	for name, table := range map[string]interface {
+		AsyncInsert(int, int)
+		Flush()
+		Close()
+		Search(int) (int, bool)
+		Contains(int) bool
+		Len() int
+	}{
+		"sync":    NewSync[int, int](16),
+		"sharded": NewSharded[int, int](4, 16),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Flush before any AsyncInsert has nothing to wait for
+			table.Flush()
+
+			var wg sync.WaitGroup
+			for p := 0; p < producers; p++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < perProducer; i++ {
+						table.AsyncInsert(p*perProducer+i, i)
+						// the writes of one producer are applied in order
+						table.AsyncInsert(-1-p, i)
+					}
+				}()
+			}
+			wg.Wait()
+			table.Flush()
+
+			if n := table.Len(); n != producers*perProducer+producers {
+				t.Fatalf("Len() after Flush = %d, want %d", n, producers*perProducer+producers)
+			}
+			for p := 0; p < producers; p++ {
+				if v, _ := table.Search(-1 - p); v != perProducer-1 {
+					t.Fatalf("last write of producer %d = %d, want %d", p, v, perProducer-1)
+				}
+			}
+
+			table.AsyncInsert(-100, 1)
+			table.Close()
+			if v, ok := table.Search(-100); !ok || v != 1 {
+				t.Fatal("Close did not apply a queued write")
+			}
+			table.AsyncInsert(-101, 1)
+			if !table.Contains(-101) {
+				t.Fatal("AsyncInsert after Close did not insert right away")
+			}
+			table.Close()
+			table.Flush()
+		})
+	}
+}
+
+func TestAsyncInsert_CloseUnused(t *testing.T) {
+	s := NewSync[int, int](4)
+	sharded := NewSharded[int, int](4, 16)
+	// Close before any AsyncInsert starts no goroutine
+	s.Close()
+	sharded.Close()
+	if s.async.Load() != nil || sharded.async.Load() != nil {
+		t.Fatal("Close started an applier")
+	}
+	s.AsyncInsert(1, 1)
+	sharded.AsyncInsert(1, 1)
+	if !s.Contains(1) || !sharded.Contains(1) {
+		t.Fatal("AsyncInsert after Close did not insert right away")
+	}
+	if s.async.Load() != nil || sharded.async.Load() != nil {
+		t.Fatal("AsyncInsert after Close started an applier")
+	}
+	s.Flush()
+	sharded.Flush()
+}
@@ -0,0 +1,23 @@
+package hashtable
+
+import (
+	"bytes"
+	"math/rand/v2"
+
+	"github.com/jkittell/hashtable/hashers"
+)
+
+// A BytesTable is a HashTable keyed by byte slices, as created by NewBytes.
+type BytesTable[V any] = HashTable[[]byte, V]
+
+// NewBytes creates a table with n number of internal buckets keyed by byte slices.
+// Keys are compared by content and hashed in place with a randomly seeded
+// hashers.Bytes, so lookups such as Search and Contains never convert a key to a
+// string or copy it.
+//
+// The table keeps the slices passed to Insert and the other storing methods as its
+// keys, the same way a map keeps a pointer: a key must not be modified while it is
+// stored. Copy buffers that will be reused, for example with bytes.Clone.
+func NewBytes[V any](numberOfBuckets int, opts ...Option[[]byte, V]) *BytesTable[V] {
+	return newTable(numberOfBuckets, Hasher[[]byte](hashers.Bytes{Seed: rand.Uint64()}), bytes.Equal, opts)
+}
@@ -0,0 +1,35 @@
+package hashtable
+
+import "testing"
+
+func TestNewBytes(t *testing.T) {
+	ht := NewBytes[int](8)
+	ht.Insert([]byte("alpha"), 1)
+	ht.Insert([]byte("beta"), 2)
+	ht.Insert([]byte("alpha"), 3)
+
+	if ht.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", ht.Len())
+	}
+
+	// a lookup key built in a different buffer must still match
+	buf := append(make([]byte, 0, 16), "alpha"...)
+	if v, ok := ht.Search(buf); !ok || v != 3 {
+		t.Fatalf("Search(alpha) = %d, %v, want 3, true", v, ok)
+	}
+	if ht.Contains([]byte("gamma")) {
+		t.Fatal("Contains(gamma) = true")
+	}
+}
+
+func BenchmarkBytesTable_Search(b *testing.B) {
+	ht := NewBytes[int](1024)
+	key := []byte("GET /api/v1/users/12345 HTTP/1.1")
+	ht.Insert(key, 1)
+	lookup := append([]byte(nil), key...)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		ht.Search(lookup)
+	}
+}
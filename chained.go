@@ -0,0 +1,392 @@
+package hashtable
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sort"
+)
+
+// Incremental rehashing moves at most this many non-empty buckets, visiting at
+// most rehashVisits buckets in all, per lookup or modification of the table.
+const (
+	rehashStepBuckets = 4
+	rehashVisits      = 10 * rehashStepBuckets
+)
+
+// chained is the default storage: an array of buckets, each a slice of the entries
+// whose hash reduces to it.
+type chained[K any, V any] struct {
+	equal func(a, b K) bool
+	table [][]kv[K, V]
+	// size is the number of keys currently stored across all buckets.
+	size int
+	// longest is an upper bound on the length of any bucket. It grows as buckets
+	// grow and is only lowered when the whole table is cleared or compacted.
+	longest     int
+	maxLoad     float64
+	incremental bool
+	// old holds the buckets of an incremental rehash in progress, or nil. Buckets
+	// before migrated, and any set to nil, have been moved into table. oldLongest
+	// is longest as it was when the rehash started.
+	old        [][]kv[K, V]
+	migrated   int
+	oldLongest int
+	// compare orders keys for the trees that index long buckets, or is nil, in
+	// which case trees is nil too. Otherwise trees holds, for each bucket of table,
+	// the root of its index, or nil.
+	compare func(a, b K) int
+	trees   []*treeNode
+	// sorted keeps the entries of each bucket in order of hash so lookups can
+	// binary-search them. Sorted buckets are never indexed by trees.
+	sorted bool
+	// recycle keeps the backing arrays of emptied and outgrown buckets on a
+	// freelist for buckets that need to grow. free holds the arrays by capacity
+	// class: those in free[i] have room for at least 1<<i entries, and freeSlots
+	// their total capacity.
+	recycle   bool
+	free      [][][]kv[K, V]
+	freeSlots int
+}
+
+func newChained[K any, V any](l layout[K], numberOfBuckets int) *chained[K, V] {
+	c := &chained[K, V]{
+		equal:       l.equal,
+		table:       make([][]kv[K, V], max(numberOfBuckets, 1)),
+		maxLoad:     l.maxLoad,
+		incremental: l.incremental,
+		compare:     l.compare,
+		sorted:      l.sorted,
+		recycle:     l.recycle,
+	}
+	c.resetTrees()
+	return c
+}
+
+func (c *chained[K, V]) find(key K, h uint64) *kv[K, V] {
+	b, n := c.locate(key, h)
+	if n < 0 {
+		return nil
+	}
+	return &c.table[b][n]
+}
+
+// locate returns the bucket for key and the index of key within that bucket, or -1
+// as the index when key is not stored. It advances any incremental rehash first.
+func (c *chained[K, V]) locate(key K, h uint64) (int, int) {
+	if c.old != nil {
+		c.migrate(int(h % uint64(len(c.old))))
+		c.rehashStep()
+	}
+	b := int(h % uint64(len(c.table)))
+	if c.trees != nil && c.trees[b] != nil {
+		if node := treeFind(c.trees[b], c.orderOf(c.table[b], key, h)); node != nil {
+			return b, node.index
+		}
+		return b, -1
+	}
+	if c.sorted {
+		bucket := c.table[b]
+		first := sort.Search(len(bucket), func(n int) bool { return bucket[n].hash >= h })
+		for n := first; n < len(bucket) && bucket[n].hash == h; n++ {
+			if c.equal(key, bucket[n].Key) {
+				return b, n
+			}
+		}
+		return b, -1
+	}
+	for n, data := range c.table[b] {
+		if data.hash == h && c.equal(key, data.Key) {
+			return b, n
+		}
+	}
+	return b, -1
+}
+
+func (c *chained[K, V]) add(key K, h uint64, value V) int {
+	length := c.place(kv[K, V]{Key: key, Value: value, hash: h})
+	c.size++
+	if c.maxLoad > 0 && float64(c.size) > c.maxLoad*float64(len(c.table)) {
+		c.grow(2 * len(c.table))
+	}
+	return length
+}
+
+// place appends data to its bucket, or inserts it in order of hash if buckets are
+// sorted, and returns the new length of the bucket.
+func (c *chained[K, V]) place(data kv[K, V]) int {
+	b := data.hash % uint64(len(c.table))
+	if c.sorted {
+		bucket := c.room(c.table[b])
+		// entries with the same hash stay in the order they were added
+		n := sort.Search(len(bucket), func(n int) bool { return bucket[n].hash > data.hash })
+		c.table[b] = slices.Insert(bucket, n, data)
+		c.longest = max(c.longest, len(c.table[b]))
+		return len(c.table[b])
+	}
+	c.table[b] = append(c.room(c.table[b]), data)
+	c.longest = max(c.longest, len(c.table[b]))
+	if c.trees != nil {
+		if c.trees[b] != nil {
+			c.trees[b] = treeInsert(c.trees[b], len(c.table[b])-1, c.orderOf(c.table[b], data.Key, data.hash))
+		} else {
+			c.treeify(int(b))
+		}
+	}
+	return len(c.table[b])
+}
+
+func (c *chained[K, V]) remove(key K, h uint64) (kv[K, V], bool) {
+	b, n := c.locate(key, h)
+	if n < 0 {
+		return kv[K, V]{}, false
+	}
+	data := c.table[b][n]
+	c.removeAt(b, n)
+	return data, true
+}
+
+// removeAt removes the entry at index n of bucket. Entry order within an unsorted
+// bucket is not significant, so the last entry is moved into the vacated slot.
+func (c *chained[K, V]) removeAt(bucket, n int) {
+	entries := c.table[bucket]
+	last := len(entries) - 1
+	if c.sorted {
+		// Delete zeroes the vacated slot so the garbage collector can reclaim what
+		// it references
+		c.table[bucket] = slices.Delete(entries, n, n+1)
+		c.size--
+		c.releaseEmpty(bucket)
+		return
+	}
+	if c.trees != nil && c.trees[bucket] != nil {
+		c.untrack(bucket, n, last)
+	}
+	entries[n] = entries[last]
+	// zero the vacated slot so the garbage collector can reclaim what it references
+	entries[last] = kv[K, V]{}
+	c.table[bucket] = entries[:last]
+	c.size--
+	c.releaseEmpty(bucket)
+}
+
+func (c *chained[K, V]) len() int {
+	return c.size
+}
+
+func (c *chained[K, V]) clear() {
+	// buckets still awaiting an incremental rehash are dropped rather than migrated
+	c.old = nil
+	c.migrated = 0
+	for n, bucket := range c.table {
+		// zero the entries so the garbage collector can reclaim what they reference
+		clear(bucket)
+		c.table[n] = bucket[:0]
+	}
+	clear(c.trees)
+	c.size = 0
+	c.longest = 0
+}
+
+func (c *chained[K, V]) all(yield func(*kv[K, V]) bool) {
+	for _, bucket := range c.buckets() {
+		for n := range bucket {
+			if !yield(&bucket[n]) {
+				return
+			}
+		}
+	}
+}
+
+func (c *chained[K, V]) deleteFunc(del func(*kv[K, V]) bool) int {
+	removed := 0
+	for n, bucket := range c.buckets() {
+		kept := bucket[:0]
+		for i := range bucket {
+			if !del(&bucket[i]) {
+				kept = append(kept, bucket[i])
+			}
+		}
+		// zero the tail so the garbage collector can reclaim what it references
+		clear(bucket[len(kept):])
+		removed += len(bucket) - len(kept)
+		c.table[n] = kept
+		c.releaseEmpty(n)
+		if c.trees != nil {
+			c.trees[n] = nil
+			c.treeify(n)
+		}
+	}
+	c.size -= removed
+	return removed
+}
+
+func (c *chained[K, V]) slots() (int, int) {
+	return len(c.buckets()), c.longest
+}
+
+func (c *chained[K, V]) at(p, d int) *kv[K, V] {
+	if d >= len(c.table[p]) {
+		return nil
+	}
+	return &c.table[p][d]
+}
+
+// reserve raises the number of buckets to what n entries need under the maximum
+// load factor and gives every bucket room for its share of n, carved out of a
+// single allocation.
+func (c *chained[K, V]) reserve(n int) {
+	numberOfBuckets := len(c.buckets())
+	if c.maxLoad > 0 {
+		for float64(n) > c.maxLoad*float64(numberOfBuckets) {
+			numberOfBuckets *= 2
+		}
+	}
+	if numberOfBuckets != len(c.table) {
+		c.rebuild(numberOfBuckets)
+	}
+
+	per := (n + numberOfBuckets - 1) / numberOfBuckets
+	slab := make([]kv[K, V], per*numberOfBuckets)
+	for i, bucket := range c.table {
+		if cap(bucket) >= per {
+			continue
+		}
+		// the full slice expression stops appends from spilling into the next bucket
+		reserved := slab[i*per : i*per : (i+1)*per]
+		c.table[i] = append(reserved, bucket...)
+	}
+}
+
+// compact reallocates every bucket whose backing array is larger than its entries
+// need.
+func (c *chained[K, V]) compact() {
+	c.dropFreelist()
+	for n, bucket := range c.buckets() {
+		switch {
+		case len(bucket) == 0:
+			c.table[n] = nil
+		case cap(bucket) > len(bucket):
+			c.table[n] = slices.Clone(bucket)
+		}
+	}
+	c.longest = 0
+	for _, bucket := range c.table {
+		c.longest = max(c.longest, len(bucket))
+	}
+}
+
+// shrink halves the number of buckets for as long as the table would still hold at
+// most half the maximum load factor.
+func (c *chained[K, V]) shrink() {
+	numberOfBuckets := len(c.buckets())
+	if c.maxLoad > 0 {
+		for numberOfBuckets > 1 && float64(c.size) <= c.maxLoad*float64(numberOfBuckets/2)/2 {
+			numberOfBuckets /= 2
+		}
+	}
+	if numberOfBuckets != len(c.table) {
+		c.rebuild(numberOfBuckets)
+	}
+	c.compact()
+}
+
+// rebuild redistributes every entry across numberOfBuckets new buckets.
+func (c *chained[K, V]) rebuild(numberOfBuckets int) {
+	old := c.buckets()
+	c.table = make([][]kv[K, V], numberOfBuckets)
+	c.resetTrees()
+	c.longest = 0
+	for _, bucket := range old {
+		for _, data := range bucket {
+			c.place(data)
+		}
+		if c.recycle {
+			clear(bucket)
+			c.release(bucket[:0])
+		}
+	}
+}
+
+// grow enlarges the table to numberOfBuckets buckets. Tables created with
+// WithIncrementalRehash keep the current buckets and migrate them a few at a time.
+func (c *chained[K, V]) grow(numberOfBuckets int) {
+	if !c.incremental {
+		c.rebuild(numberOfBuckets)
+		return
+	}
+	c.finishRehash()
+	c.old = c.table
+	c.migrated = 0
+	c.oldLongest = c.longest
+	c.table = make([][]kv[K, V], numberOfBuckets)
+	c.resetTrees()
+	c.longest = 0
+}
+
+// sample returns the entry at a random index of a random bucket, or nil if there
+// is none, choosing among the buckets yet to be migrated by a rehash in progress
+// as well as the current ones, so that sampling does not finish the rehash as
+// slots does. Entries in shorter buckets are somewhat more likely to be chosen.
+func (c *chained[K, V]) sample() *kv[K, V] {
+	old := c.old[c.migrated:]
+	bucket, depth := c.table, c.longest
+	p := rand.IntN(len(old) + len(c.table))
+	if p < len(old) {
+		bucket, depth = old, c.oldLongest
+	} else {
+		p -= len(old)
+	}
+	d := rand.IntN(max(depth, 1))
+	if d >= len(bucket[p]) {
+		return nil
+	}
+	return &bucket[p][d]
+}
+
+// migrate moves the entries of old bucket i into the current buckets.
+func (c *chained[K, V]) migrate(i int) {
+	for _, data := range c.old[i] {
+		c.place(data)
+	}
+	if c.recycle {
+		clear(c.old[i])
+		c.release(c.old[i][:0])
+	}
+	c.old[i] = nil
+}
+
+// rehashStep migrates the next few old buckets, ending the rehash once every old
+// bucket has been moved.
+func (c *chained[K, V]) rehashStep() {
+	for moved, visited := 0, 0; c.migrated < len(c.old); c.migrated++ {
+		if moved == rehashStepBuckets || visited == rehashVisits {
+			return
+		}
+		if len(c.old[c.migrated]) > 0 {
+			c.migrate(c.migrated)
+			moved++
+		}
+		visited++
+	}
+	c.old = nil
+	c.migrated = 0
+}
+
+// finishRehash migrates every remaining old bucket.
+func (c *chained[K, V]) finishRehash() {
+	if c.old == nil {
+		return
+	}
+	for ; c.migrated < len(c.old); c.migrated++ {
+		c.migrate(c.migrated)
+	}
+	c.old = nil
+	c.migrated = 0
+}
+
+// buckets finishes any incremental rehash and returns the buckets, for operations
+// that visit every entry.
+func (c *chained[K, V]) buckets() [][]kv[K, V] {
+	c.finishRehash()
+	return c.table
+}
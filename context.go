@@ -0,0 +1,60 @@
+package hashtable
+
+import "context"
+
+// ctxCheckInterval is the number of entries the bulk methods taking a context
+// process between checks for its cancellation.
+const ctxCheckInterval = 256
+
+// canceled reports whether done, the channel returned by the Done method of a
+// context, has been closed. A nil done is never closed.
+func canceled(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// EntriesContext is like Entries, but stops copying entries once ctx is canceled.
+// It returns those copied so far and ctx.Err() if it stopped before copying them
+// all.
+func (ht *HashTable[K, V]) EntriesContext(ctx context.Context) ([]Entry[K, V], error) {
+	done := ctx.Done()
+	entries := make([]Entry[K, V], 0, ht.Len())
+	stopped := false
+	ht.store.all(func(data *kv[K, V]) bool {
+		if len(entries)%ctxCheckInterval == 0 && canceled(done) {
+			stopped = true
+			return false
+		}
+		entries = append(entries, Entry[K, V]{Key: data.Key, Value: data.Value})
+		return true
+	})
+	if stopped {
+		return entries, ctx.Err()
+	}
+	return entries, nil
+}
+
+// EntriesContext is like HashTable.EntriesContext. The lock is released as soon as
+// ctx is canceled.
+func (s *SyncHashTable[K, V]) EntriesContext(ctx context.Context) ([]Entry[K, V], error) {
+	defer s.rlock()()
+	return s.ht.EntriesContext(ctx)
+}
+
+// EntriesContext is like HashTable.EntriesContext, copying the entries shard by
+// shard as Entries does.
+func (s *ShardedHashTable[K, V]) EntriesContext(ctx context.Context) ([]Entry[K, V], error) {
+	var entries []Entry[K, V]
+	for _, shard := range s.shards {
+		copied, err := shard.EntriesContext(ctx)
+		entries = append(entries, copied...)
+		if err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
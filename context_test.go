@@ -0,0 +1,80 @@
+package hashtable
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	entries := make([]Entry[int, int], 10_000)
+	for i := range entries {
+		entries[i] = Entry[int, int]{Key: i, Value: i}
+	}
+
+	s := NewSharded[int, int](4, 16)
+	if n, err := s.InsertAllParallelContext(ctx, entries); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("InsertAllParallelContext with a canceled context = %d, %v; want 0, %v", n, err, context.Canceled)
+	}
+	if n := s.insertAllParallel(ctx.Done(), entries, 3); n != 0 || s.Len() != 0 {
+		t.Fatalf("insertAllParallel with a canceled context inserted %d entries, table holds %d", n, s.Len())
+	}
+	if n, err := s.InsertAllParallelContext(context.Background(), entries); n != len(entries) || err != nil {
+		t.Fatalf("InsertAllParallelContext = %d, %v; want %d, nil", n, err, len(entries))
+	}
+
+	ht := New[int, int](16)
+	for _, e := range entries {
+		ht.Insert(e.Key, e.Value)
+	}
+	locked := NewSync[int, int](16)
+	locked.Insert(1, 1)
+	for name, export := range map[string]func(context.Context) ([]Entry[int, int], error){
+		"table":   ht.EntriesContext,
+		"sync":    locked.EntriesContext,
+		"sharded": s.EntriesContext,
+	} {
+		if got, err := export(ctx); len(got) != 0 || !errors.Is(err, context.Canceled) {
+			t.Errorf("%s EntriesContext with a canceled context returned %d entries, %v", name, len(got), err)
+		}
+		if got, err := export(context.Background()); len(got) == 0 || err != nil {
+			t.Errorf("%s EntriesContext returned %d entries, %v", name, len(got), err)
+		}
+	}
+
+	for name, rangeParallel := range map[string]func(context.Context, int, func(int, int)) (int, error){
+		"table":   ht.RangeParallelContext,
+		"sync":    locked.RangeParallelContext,
+		"sharded": s.RangeParallelContext,
+	} {
+		if n, err := rangeParallel(ctx, 2, func(int, int) {}); n != 0 || !errors.Is(err, context.Canceled) {
+			t.Errorf("%s RangeParallelContext with a canceled context = %d, %v", name, n, err)
+		}
+	}
+}
+
+func TestRangeParallelContext(t *testing.T) {
+	ht := New[int, int](16)
+	for k := 0; k < 1000; k++ {
+		ht.Insert(k, k)
+	}
+	// a single worker stops right after the call that cancels the context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	n, err := ht.RangeParallelContext(ctx, 1, func(int, int) {
+		if calls++; calls == 100 {
+			cancel()
+		}
+	})
+	if n != 100 || calls != 100 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("RangeParallelContext canceled after 100 calls = %d, %v after %d calls", n, err, calls)
+	}
+
+	n, err = ht.RangeParallelContext(context.Background(), 3, func(int, int) {})
+	if n != ht.Len() || err != nil {
+		t.Fatalf("RangeParallelContext = %d, %v; want %d, nil", n, err, ht.Len())
+	}
+}
@@ -0,0 +1,260 @@
+package hashtable
+
+import (
+	"math/rand/v2"
+
+	"github.com/jkittell/hashtable/hashers"
+)
+
+const (
+	// maxKicks bounds the number of entries one cuckoo insert may displace before
+	// the entry left over is moved to the stash.
+	maxKicks = 128
+	// stashLimit is the number of entries a cuckoo table keeps in its stash before
+	// rehashing under new seeds.
+	stashLimit = 4
+)
+
+// cuckoo is a storage with two-table cuckoo hashing: every key has exactly one slot
+// in each table, and an insert that finds both taken evicts one occupant, which
+// moves to its slot in the other table, possibly evicting another in turn. A
+// lookup therefore reads at most two slots plus a stash of at most a few entries
+// that could not be placed. When the stash overflows, which is how a cycle of
+// evictions shows up, the table is rebuilt under new seeds, and doubled if that
+// does not help. Both tables together are kept at most half full.
+//
+// Slots are derived from the stored 64-bit hash of a key, so keys with identical
+// hashes can only share the stash. A Hasher that returns many identical hashes
+// makes the stash grow and lookups cost time proportional to it.
+type cuckoo[K any, V any] struct {
+	equal  func(a, b K) bool
+	tables [2][]kv[K, V]
+	used   [2][]bool
+	seeds  [2]uint64
+	stash  []kv[K, V]
+	// limit is the stash length that triggers a rebuild. It is raised when
+	// rebuilding cannot empty the stash because of identical hashes.
+	limit int
+	size  int
+}
+
+func newCuckoo[K any, V any](l layout[K], n int) *cuckoo[K, V] {
+	t := &cuckoo[K, V]{equal: l.equal, limit: stashLimit}
+	t.allocate(max(minSlots, powerOfTwo(n)))
+	return t
+}
+
+// allocate replaces both tables with n empty slots each and picks new seeds.
+func (t *cuckoo[K, V]) allocate(n int) {
+	for side := range t.tables {
+		t.tables[side] = make([]kv[K, V], n)
+		t.used[side] = make([]bool, n)
+		t.seeds[side] = rand.Uint64()
+	}
+}
+
+// index returns the slot of an entry with hash h in the table for side.
+func (t *cuckoo[K, V]) index(side int, h uint64) int {
+	return int(hashers.Mix64(h^t.seeds[side]) & uint64(len(t.tables[side])-1))
+}
+
+func (t *cuckoo[K, V]) find(key K, h uint64) *kv[K, V] {
+	for side := range t.tables {
+		i := t.index(side, h)
+		if t.used[side][i] && t.tables[side][i].hash == h && t.equal(key, t.tables[side][i].Key) {
+			return &t.tables[side][i]
+		}
+	}
+	for i := range t.stash {
+		if t.stash[i].hash == h && t.equal(key, t.stash[i].Key) {
+			return &t.stash[i]
+		}
+	}
+	return nil
+}
+
+func (t *cuckoo[K, V]) add(key K, h uint64, value V) int {
+	if t.size+1 > len(t.tables[0]) {
+		t.rebuild(2 * len(t.tables[0]))
+	}
+	t.size++
+	homeless, ok := t.place(kv[K, V]{Key: key, Value: value, hash: h})
+	if ok {
+		return 1
+	}
+	t.stash = append(t.stash, homeless)
+	if len(t.stash) > t.limit {
+		t.rebuild(len(t.tables[0]))
+		if len(t.stash) > t.limit {
+			t.rebuild(2 * len(t.tables[0]))
+		}
+		if len(t.stash) > t.limit {
+			// the stashed keys share their hashes, so no seed can separate them
+			t.limit = 2 * len(t.stash)
+		}
+	}
+	return 2 + len(t.stash)
+}
+
+// place puts data in one of its two slots, evicting occupants as needed, and
+// reports whether every entry found a slot. If not, it returns the entry left
+// without one.
+func (t *cuckoo[K, V]) place(data kv[K, V]) (kv[K, V], bool) {
+	for kick := 0; kick < maxKicks; kick++ {
+		for side := range t.tables {
+			if i := t.index(side, data.hash); !t.used[side][i] {
+				t.tables[side][i], t.used[side][i] = data, true
+				return kv[K, V]{}, true
+			}
+		}
+		side := kick % 2
+		i := t.index(side, data.hash)
+		t.tables[side][i], data = data, t.tables[side][i]
+	}
+	return data, false
+}
+
+func (t *cuckoo[K, V]) remove(key K, h uint64) (kv[K, V], bool) {
+	e := t.find(key, h)
+	if e == nil {
+		return kv[K, V]{}, false
+	}
+	data := *e
+	t.removeEntry(e)
+	return data, true
+}
+
+// removeEntry frees the slot or stash entry e points to.
+func (t *cuckoo[K, V]) removeEntry(e *kv[K, V]) {
+	t.size--
+	for side := range t.tables {
+		if i := t.index(side, e.hash); &t.tables[side][i] == e {
+			// zero the slot so the garbage collector can reclaim what it references
+			t.tables[side][i], t.used[side][i] = kv[K, V]{}, false
+			return
+		}
+	}
+	for i := range t.stash {
+		if &t.stash[i] == e {
+			last := len(t.stash) - 1
+			t.stash[i] = t.stash[last]
+			t.stash[last] = kv[K, V]{}
+			t.stash = t.stash[:last]
+			return
+		}
+	}
+}
+
+func (t *cuckoo[K, V]) len() int {
+	return t.size
+}
+
+func (t *cuckoo[K, V]) clear() {
+	for side := range t.tables {
+		clear(t.tables[side])
+		clear(t.used[side])
+	}
+	clear(t.stash)
+	t.stash = t.stash[:0]
+	t.limit = stashLimit
+	t.size = 0
+}
+
+func (t *cuckoo[K, V]) all(yield func(*kv[K, V]) bool) {
+	for side := range t.tables {
+		for i := range t.tables[side] {
+			if t.used[side][i] && !yield(&t.tables[side][i]) {
+				return
+			}
+		}
+	}
+	for i := range t.stash {
+		if !yield(&t.stash[i]) {
+			return
+		}
+	}
+}
+
+func (t *cuckoo[K, V]) deleteFunc(del func(*kv[K, V]) bool) int {
+	removed := 0
+	for side := range t.tables {
+		for i := range t.tables[side] {
+			if t.used[side][i] && del(&t.tables[side][i]) {
+				t.tables[side][i], t.used[side][i] = kv[K, V]{}, false
+				removed++
+			}
+		}
+	}
+	kept := t.stash[:0]
+	for i := range t.stash {
+		if !del(&t.stash[i]) {
+			kept = append(kept, t.stash[i])
+		}
+	}
+	clear(t.stash[len(kept):])
+	removed += len(t.stash) - len(kept)
+	t.stash = kept
+	t.size -= removed
+	return removed
+}
+
+// slots numbers the slots of the first table, then the second, then the stash.
+func (t *cuckoo[K, V]) slots() (int, int) {
+	return 2*len(t.tables[0]) + len(t.stash), 1
+}
+
+func (t *cuckoo[K, V]) at(p, d int) *kv[K, V] {
+	n := len(t.tables[0])
+	switch {
+	case d > 0:
+		return nil
+	case p < 2*n:
+		if side, i := p/n, p%n; t.used[side][i] {
+			return &t.tables[side][i]
+		}
+	case p-2*n < len(t.stash):
+		return &t.stash[p-2*n]
+	}
+	return nil
+}
+
+func (t *cuckoo[K, V]) reserve(n int) {
+	if slots := max(minSlots, powerOfTwo(n)); slots > len(t.tables[0]) {
+		t.rebuild(slots)
+	}
+}
+
+// compact does nothing: removal frees slots immediately.
+func (t *cuckoo[K, V]) compact() {}
+
+// shrink lowers the number of slots to what the entries need at half the maximum
+// load.
+func (t *cuckoo[K, V]) shrink() {
+	if slots := max(minSlots, powerOfTwo(2*t.size)); slots < len(t.tables[0]) {
+		t.rebuild(slots)
+	}
+}
+
+// rebuild moves every entry into new tables of n slots each under new seeds.
+func (t *cuckoo[K, V]) rebuild(n int) {
+	tables, used, stash := t.tables, t.used, t.stash
+	t.allocate(n)
+	t.stash = nil
+	for side := range tables {
+		for i := range tables[side] {
+			if used[side][i] {
+				t.reinsert(tables[side][i])
+			}
+		}
+	}
+	for _, data := range stash {
+		t.reinsert(data)
+	}
+}
+
+// reinsert places data during a rebuild, stashing whatever cannot be placed.
+func (t *cuckoo[K, V]) reinsert(data kv[K, V]) {
+	if homeless, ok := t.place(data); !ok {
+		t.stash = append(t.stash, homeless)
+	}
+}
@@ -0,0 +1,142 @@
+package hashtable
+
+import (
+	"math"
+	"strconv"
+)
+
+// An EvictReason tells a callback set with WithOnEvict why an entry left the table.
+type EvictReason int
+
+const (
+	// EvictCapacity is an entry removed to make room for a new key in a table
+	// bounded by WithMaxEntries.
+	EvictCapacity EvictReason = iota
+	// EvictExpired is an entry whose TTL ran out, reclaimed by a lookup, a write of
+	// its key, DeleteExpired or, in a bounded table, to make room.
+	EvictExpired
+	// EvictDeleted is an entry removed by a method such as Delete or Clear.
+	EvictDeleted
+)
+
+var evictReasonNames = [...]string{"Capacity", "Expired", "Deleted"}
+
+func (r EvictReason) String() string {
+	if r < 0 || int(r) >= len(evictReasonNames) {
+		return "EvictReason(" + strconv.Itoa(int(r)) + ")"
+	}
+	return evictReasonNames[r]
+}
+
+// evictionSamples is the number of entries a bounded table samples to choose the
+// one it evicts.
+const evictionSamples = 5
+
+// WithMaxEntries bounds the HashTable to n entries: adding a key to a full table
+// first evicts another entry, chosen among a few sampled at random as the one that
+// expires first, so that expired entries go before live ones and entries without a
+// TTL go last, as with the volatile-ttl policy of Redis. Replacing the value of a key
+// evicts nothing. Every shard of a ShardedHashTable is bounded to n on its own. An n
+// that is not positive leaves the table unbounded. A ReadMostlyHashTable ignores
+// this option.
+func WithMaxEntries[K any, V any](n int) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.maxEntries = n
+	}
+}
+
+// WithOnEvict makes the HashTable call fn with every entry that leaves it, and why,
+// so a bounded table can persist or log what it drops. Entries moved out by Freeze,
+// or yielded by Drain, are not passed to fn. fn runs during the method removing the
+// entry, with the lock of a SyncHashTable held, and must not use the table. A
+// ReadMostlyHashTable ignores this option.
+func WithOnEvict[K any, V any](fn func(key K, value V, reason EvictReason)) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.onEvict = fn
+	}
+}
+
+// evicted passes an entry that has left ht to the callback set with WithOnEvict.
+func (ht *HashTable[K, V]) evicted(key K, value V, reason EvictReason) {
+	if ht.onEvict != nil {
+		ht.onEvict(key, value, reason)
+	}
+}
+
+// evictAll passes every entry of ht, which is about to be cleared, to the callback
+// set with WithOnEvict.
+func (ht *HashTable[K, V]) evictAll() {
+	if ht.onEvict == nil {
+		return
+	}
+	ht.store.all(func(data *kv[K, V]) bool {
+		ht.onEvict(data.Key, data.Value, EvictDeleted)
+		return true
+	})
+}
+
+// makeRoom evicts entries until the table has room for one more under
+// WithMaxEntries.
+func (ht *HashTable[K, V]) makeRoom() {
+	for ht.store.len() >= ht.maxEntries {
+		t := now()
+		var victim K
+		first := int64(math.MaxInt64)
+		for i, e := range ht.evictionSample() {
+			at := int64(math.MaxInt64)
+			if ht.deadlines != nil {
+				if x, ok := ht.deadlines.Search(e.Key); ok {
+					at = x.deadline
+				}
+			}
+			if i == 0 || at < first {
+				victim, first = e.Key, at
+			}
+		}
+		data, _ := ht.store.remove(ht.hash(victim))
+		reason := EvictCapacity
+		if ht.deadlines != nil {
+			ht.deadlines.Delete(victim)
+			if t >= first {
+				reason = EvictExpired
+			}
+		}
+		ht.evicted(data.Key, data.Value, reason)
+	}
+}
+
+// evictionSample returns the entries makeRoom chooses among: a few distinct ones
+// picked at random, like RandomSample, but without finishing an incremental rehash
+// in progress, which would stall the insert making room for the whole rehash.
+func (ht *HashTable[K, V]) evictionSample() []Entry[K, V] {
+	c, ok := ht.store.(*chained[K, V])
+	if !ok || c.old == nil || ht.store.len() <= evictionSamples {
+		return ht.RandomSample(evictionSamples)
+	}
+	chosen := make(map[*kv[K, V]]struct{}, evictionSamples)
+	sample := make([]Entry[K, V], 0, evictionSamples)
+	for attempts := 0; len(sample) < evictionSamples; attempts++ {
+		if attempts >= 32*evictionSamples+64 {
+			// too sparse to find entries by chance
+			return ht.RandomSample(evictionSamples)
+		}
+		data := c.sample()
+		if data == nil {
+			continue
+		}
+		if _, ok := chosen[data]; ok {
+			continue
+		}
+		chosen[data] = struct{}{}
+		sample = append(sample, Entry[K, V]{Key: data.Key, Value: data.Value})
+	}
+	return sample
+}
+
+// inheritLimits makes ht bound, expire and report its entries as from does, for a
+// table taking over or copying the entries of from.
+func (ht *HashTable[K, V]) inheritLimits(from *HashTable[K, V]) {
+	ht.defaultTTL = from.defaultTTL
+	ht.maxEntries = from.maxEntries
+	ht.onEvict = from.onEvict
+}
@@ -0,0 +1,152 @@
+package hashtable
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// an eviction is an entry passed to the callback set with WithOnEvict.
+type eviction struct {
+	key, value int
+	reason     EvictReason
+}
+
+// recordEvictions returns an option collecting the entries evicted from a table
+// into the slice it points to.
+func recordEvictions(evicted *[]eviction) Option[int, int] {
+	return WithOnEvict(func(key, value int, reason EvictReason) {
+		*evicted = append(*evicted, eviction{key, value, reason})
+	})
+}
+
+func TestWithMaxEntries(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			var evicted []eviction
+			ht := New(4, append(slices.Clone(b.opts), WithMaxEntries[int, int](10), recordEvictions(&evicted))...)
+			for k := 0; k < 100; k++ {
+				ht.Insert(k, k)
+				// replacing a value evicts nothing
+				ht.Insert(k, -k)
+			}
+			if ht.Len() != 10 || len(evicted) != 90 {
+				t.Fatalf("Len() = %d with %d evictions, want 10 and 90", ht.Len(), len(evicted))
+			}
+			seen := make(map[int]bool)
+			for _, e := range evicted {
+				if e.reason != EvictCapacity || e.value != -e.key || ht.Contains(e.key) || seen[e.key] {
+					t.Fatalf("evicted %+v, which is wrong or still stored", e)
+				}
+				seen[e.key] = true
+			}
+			for k, v := range ht.All() {
+				if seen[k] || v != -k {
+					t.Errorf("stored %d: %d, which was evicted or has the wrong value", k, v)
+				}
+			}
+		})
+	}
+}
+
+func TestWithMaxEntries_TTL(t *testing.T) {
+	advance := fakeClock(t)
+	// with no more entries than are sampled, the entry expiring first is evicted
+	var evicted []eviction
+	ht := New(4, WithMaxEntries[int, int](evictionSamples), recordEvictions(&evicted))
+	for k := 0; k < evictionSamples-2; k++ {
+		ht.Insert(k, k)
+	}
+	ht.InsertWithTTL(10, 10, time.Hour)
+	ht.InsertWithTTL(11, 11, time.Minute)
+	ht.Insert(12, 12)
+	advance(time.Hour)
+	defer advance(-time.Hour)
+	ht.Insert(13, 13)
+	want := []eviction{{11, 11, EvictCapacity}, {10, 10, EvictExpired}}
+	if !slices.Equal(evicted, want) {
+		t.Fatalf("evicted %v, want %v", evicted, want)
+	}
+	ht.Insert(14, 14)
+	if len(evicted) != 3 || evicted[2].reason != EvictCapacity || ht.Len() != evictionSamples {
+		t.Errorf("evicted %v and holds %d entries, want an entry without a TTL evicted", evicted, ht.Len())
+	}
+}
+
+func TestWithMaxEntries_IncrementalRehash(t *testing.T) {
+	ht := New(4, WithIncrementalRehash[int, int](), WithMaxEntries[int, int](math.MaxInt))
+	c := chainedStore(ht)
+	k := 0
+	for c.old == nil || len(c.old) < 1024 {
+		ht.Insert(k, k)
+		k++
+	}
+	// making room in a full table evicts from a sample of the old and new buckets,
+	// leaving the rehash in progress
+	ht.maxEntries = ht.Len()
+	for i := 0; i < 10; i++ {
+		ht.Insert(-1-i, i)
+		if ht.Len() != ht.maxEntries || !ht.Contains(-1-i) {
+			t.Fatalf("Len() = %d after inserting %d into a table bounded to %d", ht.Len(), -1-i, ht.maxEntries)
+		}
+	}
+	if c.old == nil {
+		t.Fatal("making room finished the incremental rehash")
+	}
+}
+
+func TestWithOnEvict(t *testing.T) {
+	advance := fakeClock(t)
+	var evicted []eviction
+	ht := New(4, recordEvictions(&evicted))
+	for k := 0; k < 10; k++ {
+		ht.Insert(k, k)
+	}
+	ht.InsertWithTTL(10, 10, time.Second)
+	ht.InsertWithTTL(11, 11, time.Second)
+	advance(time.Second)
+	defer advance(-time.Second)
+
+	ht.Delete(0)
+	ht.Delete(0)
+	CompareAndDelete(ht, 1, 1)
+	CompareAndDelete(ht, 2, -1)
+	ht.DeleteFunc(func(k, v int) bool { return k == 3 })
+	ht.Contains(10)
+	ht.DeleteExpired()
+	want := []eviction{
+		{0, 0, EvictDeleted},
+		{1, 1, EvictDeleted},
+		{3, 3, EvictDeleted},
+		{10, 10, EvictExpired},
+		{11, 11, EvictExpired},
+	}
+	if !slices.Equal(evicted, want) {
+		t.Fatalf("evicted %v, want %v", evicted, want)
+	}
+	evicted = nil
+	ht.Clear()
+	if len(evicted) != 7 {
+		t.Errorf("Clear evicted %d entries, want 7", len(evicted))
+	}
+
+	// a clone sharing the entries of a SyncHashTable keeps its bound and callback
+	evicted = nil
+	s := NewSync(4, WithMaxEntries[int, int](3), recordEvictions(&evicted))
+	for k := 0; k < 3; k++ {
+		s.Insert(k, k)
+	}
+	clone := s.Clone()
+	clone.Insert(3, 3)
+	s.Clear()
+	if clone.Len() != 3 || len(evicted) != 4 {
+		t.Errorf("clone holds %d entries after %d evictions, want 3 and 4", clone.Len(), len(evicted))
+	}
+}
+
+func TestEvictReason_String(t *testing.T) {
+	if EvictExpired.String() != "Expired" || EvictReason(7).String() != "EvictReason(7)" {
+		t.Errorf("String() = %q, %q", EvictExpired, EvictReason(7))
+	}
+}
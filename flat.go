@@ -0,0 +1,234 @@
+package hashtable
+
+import (
+	"reflect"
+	"slices"
+)
+
+// flat is a chained storage that links entries by index instead of keeping a slice
+// per bucket: every entry lives in one dense array, each bucket holds the index of
+// its first entry and each entry the index of the next entry in its bucket. The
+// storage consists of three allocations, none of which holds a pointer when K and V
+// hold none, so the garbage collector never scans the entries. Growing only relinks
+// the buckets; the entries themselves never move except to fill the hole left by a
+// removed entry.
+type flat[K any, V any] struct {
+	equal func(a, b K) bool
+	// heads holds, for each bucket, one more than the index of its first entry, or
+	// 0 when the bucket is empty.
+	heads   []int32
+	entries []kv[K, V]
+	// next holds, for each entry, one more than the index of the next entry in its
+	// bucket, or 0 for the last one.
+	next []int32
+	// longest is an upper bound on the length of any bucket, as for chained.
+	longest int
+	maxLoad float64
+}
+
+func newFlat[K any, V any](l layout[K], numberOfBuckets int) *flat[K, V] {
+	return &flat[K, V]{
+		equal:   l.equal,
+		heads:   make([]int32, max(numberOfBuckets, 1)),
+		maxLoad: l.maxLoad,
+	}
+}
+
+// bucket returns the bucket of an entry with hash h.
+func (f *flat[K, V]) bucket(h uint64) int {
+	return int(h % uint64(len(f.heads)))
+}
+
+// locate returns the index of the entry for key and the link that points to it, or
+// -1 and nil.
+func (f *flat[K, V]) locate(key K, h uint64) (int, *int32) {
+	link := &f.heads[f.bucket(h)]
+	for *link != 0 {
+		i := int(*link - 1)
+		if f.entries[i].hash == h && f.equal(key, f.entries[i].Key) {
+			return i, link
+		}
+		link = &f.next[i]
+	}
+	return -1, nil
+}
+
+func (f *flat[K, V]) find(key K, h uint64) *kv[K, V] {
+	i, _ := f.locate(key, h)
+	if i < 0 {
+		return nil
+	}
+	return &f.entries[i]
+}
+
+func (f *flat[K, V]) add(key K, h uint64, value V) int {
+	b := f.bucket(h)
+	f.entries = append(f.entries, kv[K, V]{Key: key, Value: value, hash: h})
+	f.next = append(f.next, f.heads[b])
+	f.heads[b] = int32(len(f.entries))
+	length := 0
+	for i := f.heads[b]; i != 0; i = f.next[i-1] {
+		length++
+	}
+	f.longest = max(f.longest, length)
+	if f.maxLoad > 0 && float64(len(f.entries)) > f.maxLoad*float64(len(f.heads)) {
+		f.relink(2 * len(f.heads))
+	}
+	return length
+}
+
+func (f *flat[K, V]) remove(key K, h uint64) (kv[K, V], bool) {
+	i, link := f.locate(key, h)
+	if i < 0 {
+		return kv[K, V]{}, false
+	}
+	data := f.entries[i]
+	*link = f.next[i]
+	f.fill(i)
+	return data, true
+}
+
+// fill moves the last entry into index i, whose entry has been unlinked, and
+// shortens the array by one.
+func (f *flat[K, V]) fill(i int) {
+	last := len(f.entries) - 1
+	if i != last {
+		link := &f.heads[f.bucket(f.entries[last].hash)]
+		for int(*link-1) != last {
+			link = &f.next[*link-1]
+		}
+		*link = int32(i + 1)
+		f.entries[i], f.next[i] = f.entries[last], f.next[last]
+	}
+	// zero the vacated slot so the garbage collector can reclaim what it references
+	f.entries[last] = kv[K, V]{}
+	f.entries, f.next = f.entries[:last], f.next[:last]
+}
+
+func (f *flat[K, V]) len() int {
+	return len(f.entries)
+}
+
+func (f *flat[K, V]) clear() {
+	clear(f.heads)
+	clear(f.entries)
+	f.entries, f.next = f.entries[:0], f.next[:0]
+	f.longest = 0
+}
+
+func (f *flat[K, V]) all(yield func(*kv[K, V]) bool) {
+	for i := range f.entries {
+		if !yield(&f.entries[i]) {
+			return
+		}
+	}
+}
+
+func (f *flat[K, V]) deleteFunc(del func(*kv[K, V]) bool) int {
+	kept := f.entries[:0]
+	for i := range f.entries {
+		if !del(&f.entries[i]) {
+			kept = append(kept, f.entries[i])
+		}
+	}
+	// zero the tail so the garbage collector can reclaim what it references
+	clear(f.entries[len(kept):])
+	removed := len(f.entries) - len(kept)
+	f.entries, f.next = kept, f.next[:len(kept)]
+	if removed > 0 {
+		f.relink(len(f.heads))
+	}
+	return removed
+}
+
+func (f *flat[K, V]) slots() (int, int) {
+	return len(f.heads), f.longest
+}
+
+func (f *flat[K, V]) at(p, d int) *kv[K, V] {
+	i := f.heads[p]
+	for ; i != 0 && d > 0; d-- {
+		i = f.next[i-1]
+	}
+	if i == 0 {
+		return nil
+	}
+	return &f.entries[i-1]
+}
+
+// reserve raises the number of buckets to what n entries need under the maximum
+// load factor and makes room for n entries in the array.
+func (f *flat[K, V]) reserve(n int) {
+	numberOfBuckets := len(f.heads)
+	if f.maxLoad > 0 {
+		for float64(n) > f.maxLoad*float64(numberOfBuckets) {
+			numberOfBuckets *= 2
+		}
+	}
+	if numberOfBuckets != len(f.heads) {
+		f.relink(numberOfBuckets)
+	}
+	if extra := n - len(f.entries); extra > 0 {
+		f.entries = slices.Grow(f.entries, extra)
+		f.next = slices.Grow(f.next, extra)
+	}
+}
+
+// compact reallocates the arrays if they are larger than the entries need.
+func (f *flat[K, V]) compact() {
+	if cap(f.entries) > len(f.entries) {
+		f.entries = slices.Clip(slices.Clone(f.entries))
+		f.next = slices.Clip(slices.Clone(f.next))
+	}
+}
+
+// shrink halves the number of buckets for as long as the table would still hold at
+// most half the maximum load factor.
+func (f *flat[K, V]) shrink() {
+	numberOfBuckets := len(f.heads)
+	if f.maxLoad > 0 {
+		for numberOfBuckets > 1 && float64(len(f.entries)) <= f.maxLoad*float64(numberOfBuckets/2)/2 {
+			numberOfBuckets /= 2
+		}
+	}
+	if numberOfBuckets != len(f.heads) {
+		f.relink(numberOfBuckets)
+	}
+	f.compact()
+}
+
+// relink distributes the entries across numberOfBuckets new buckets without moving
+// them.
+func (f *flat[K, V]) relink(numberOfBuckets int) {
+	f.heads = make([]int32, numberOfBuckets)
+	lengths := make([]int32, numberOfBuckets)
+	f.longest = 0
+	for i := range f.entries {
+		b := f.bucket(f.entries[i].hash)
+		f.next[i] = f.heads[b]
+		f.heads[b] = int32(i + 1)
+		lengths[b]++
+		f.longest = max(f.longest, int(lengths[b]))
+	}
+}
+
+// pointerFree reports whether values of type t contain no pointers, so that the
+// garbage collector has no reason to scan them.
+func pointerFree(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return t.Len() == 0 || pointerFree(t.Elem())
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if !pointerFree(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
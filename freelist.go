@@ -0,0 +1,61 @@
+package hashtable
+
+import "math/bits"
+
+// minFreeSlots is the number of free entry slots a chained freelist may hold even
+// when the table holds fewer entries.
+const minFreeSlots = 64
+
+// room returns bucket, or a copy of it in a recycled backing array, with room to
+// append one more entry. The outgrown array goes onto the freelist.
+func (c *chained[K, V]) room(bucket []kv[K, V]) []kv[K, V] {
+	if !c.recycle || len(bucket) < cap(bucket) {
+		return bucket
+	}
+	class := bits.Len(uint(max(1, 2*cap(bucket)) - 1))
+	var grown []kv[K, V]
+	if class < len(c.free) && len(c.free[class]) > 0 {
+		n := len(c.free[class])
+		grown = c.free[class][n-1]
+		c.free[class][n-1] = nil
+		c.free[class] = c.free[class][:n-1]
+		c.freeSlots -= cap(grown)
+	} else {
+		grown = make([]kv[K, V], 0, 1<<class)
+	}
+	grown = append(grown, bucket...)
+	clear(bucket)
+	c.release(bucket[:0])
+	return grown
+}
+
+// release puts the backing array of bucket, whose entries must already be zeroed,
+// onto the freelist, unless the freelist already holds as many free slots as the
+// table holds entries.
+func (c *chained[K, V]) release(bucket []kv[K, V]) {
+	if !c.recycle || cap(bucket) == 0 || c.freeSlots+cap(bucket) > max(c.size, minFreeSlots) {
+		return
+	}
+	// an array is filed under the largest class it can serve
+	class := bits.Len(uint(cap(bucket))) - 1
+	for len(c.free) <= class {
+		c.free = append(c.free, nil)
+	}
+	c.free[class] = append(c.free[class], bucket[:0])
+	c.freeSlots += cap(bucket)
+}
+
+// releaseEmpty moves the backing array of bucket b onto the freelist if the bucket
+// has become empty.
+func (c *chained[K, V]) releaseEmpty(b int) {
+	if c.recycle && len(c.table[b]) == 0 {
+		c.release(c.table[b])
+		c.table[b] = nil
+	}
+}
+
+// dropFreelist releases every array held by the freelist to the garbage collector.
+func (c *chained[K, V]) dropFreelist() {
+	c.free = nil
+	c.freeSlots = 0
+}
@@ -0,0 +1,89 @@
+package hashtable
+
+// A flight is a call of the loader of GetOrCompute in progress, which other callers
+// missing the same key wait for instead of calling their own loader.
+type flight[V any] struct {
+	// done is closed once the loader has returned or panicked.
+	done  chan struct{}
+	value V
+	err   error
+	// finished reports that the loader returned, setting value and err.
+	finished bool
+}
+
+// GetOrCompute returns the value of key, calling load to compute and insert it when
+// key is not stored. However many goroutines miss key at once, load runs for it in
+// only one of them, without the lock of s held, while the others wait for its
+// result, so a cold key does not send a herd of identical requests to the service
+// load fetches it from. If load returns an error, nothing is inserted and every
+// waiting caller receives the error; the next call tries again. If load panics, the
+// panic propagates in its goroutine and one of the waiting callers calls its own
+// load in turn.
+func (s *SyncHashTable[K, V]) GetOrCompute(key K, load func(K) (V, error)) (V, error) {
+	for {
+		if value, ok := s.Search(key); ok {
+			return value, nil
+		}
+		f, leader, value, ok := s.joinFlight(key)
+		if ok {
+			return value, nil
+		}
+		if leader {
+			return s.fly(key, f, load)
+		}
+		<-f.done
+		if f.finished {
+			return f.value, f.err
+		}
+		// the loader panicked; try again
+	}
+}
+
+// joinFlight returns the flight in progress for key, or registers a new flight and
+// reports that the caller leads it and must carry it out with fly. If key has been
+// stored since the caller's lookup, it returns its value instead.
+func (s *SyncHashTable[K, V]) joinFlight(key K) (f *flight[V], leader bool, value V, ok bool) {
+	s.flightMu.Lock()
+	defer s.flightMu.Unlock()
+	if s.flights == nil {
+		unlock := s.rlock()
+		s.flights = newScratch[K, V, *flight[V]](s.ht)
+		unlock()
+	}
+	if f, ok = s.flights.Search(key); ok {
+		return f, false, value, false
+	}
+	// a flight for key may have landed between the caller's lookup and now, so look
+	// again while no flight can start or finish
+	if value, ok = s.Search(key); ok {
+		return nil, false, value, true
+	}
+	f = &flight[V]{done: make(chan struct{})}
+	s.flights.Insert(key, f)
+	return f, true, value, false
+}
+
+// fly calls load for key, stores its result and hands it to the callers waiting for
+// flight f.
+func (s *SyncHashTable[K, V]) fly(key K, f *flight[V], load func(K) (V, error)) (V, error) {
+	defer func() {
+		s.flightMu.Lock()
+		s.flights.Delete(key)
+		s.flightMu.Unlock()
+		close(f.done)
+	}()
+	value, err := load(key)
+	if err == nil {
+		// a value stored by another method meanwhile takes precedence, as with
+		// GetOrInsert
+		value, _ = s.GetOrInsert(key, value)
+	}
+	f.value, f.err, f.finished = value, err, true
+	return value, err
+}
+
+// GetOrCompute is like SyncHashTable.GetOrCompute, with each shard deduplicating
+// the loaders of its own keys.
+func (s *ShardedHashTable[K, V]) GetOrCompute(key K, load func(K) (V, error)) (V, error) {
+	return s.shard(key).GetOrCompute(key, load)
+}
@@ -0,0 +1,60 @@
+package hashtable
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrCompute(t *testing.T) {
+	const goroutines = 16
+	s := NewSharded[string, int](4, 16)
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(key string) (int, error) {
+		loads.Add(1)
+		<-release
+		return len(key), nil
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := s.GetOrCompute("cold", load); v != 4 || err != nil {
+				t.Errorf(`GetOrCompute("cold") = %d, %v`, v, err)
+			}
+		}()
+	}
+	// give the callers time to pile up behind the first loader
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("the loader ran %d times for one key", n)
+	}
+	if v, _ := s.GetOrCompute("cold", load); v != 4 || loads.Load() != 1 {
+		t.Fatal("GetOrCompute loaded a stored key again")
+	}
+
+	// errors reach every caller and are not stored
+	errDown := errors.New("down")
+	if _, err := s.GetOrCompute("x", func(string) (int, error) { return 0, errDown }); err != errDown {
+		t.Fatalf("GetOrCompute returned %v, want %v", err, errDown)
+	}
+	if s.Contains("x") {
+		t.Fatal("a failed load stored a value")
+	}
+
+	// a panicking loader lets the next caller load
+	func() {
+		defer func() { recover() }()
+		s.GetOrCompute("p", func(string) (int, error) { panic("boom") })
+	}()
+	if v, err := s.GetOrCompute("p", func(string) (int, error) { return 7, nil }); v != 7 || err != nil {
+		t.Fatalf("GetOrCompute after a panic = %d, %v", v, err)
+	}
+}
@@ -1,6 +1,12 @@
 package hashtable
 
-import "github.com/jkittell/array"
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/jkittell/array"
+	"github.com/jkittell/hashtable/hashers"
+)
 
 // HashTable stores key/value pairs where the number of hashtable buckets is n number of buckets.
 //
@@ -10,88 +16,770 @@ import "github.com/jkittell/array"
 //
 // A larger number of buckets means more buckets will be created, so each key stored in the Table has a lower
 // likelihood of having to share a bucket with other keys, thus speeding up lookups.
-type HashTable[K comparable, V any] struct {
-	// hash is a function which can hash a key of type K and return the bucket containing the key/value.
-	hash            func(K, int) int
-	numberOfBuckets int
-	table           [][]kv[K, V]
+//
+// The number of buckets is only a starting point: when the table holds more than
+// DefaultMaxLoadFactor entries per bucket it doubles its buckets, so lookups stay
+// fast as it grows. WithMaxLoadFactor changes the threshold or fixes the number.
+type HashTable[K any, V any] struct {
+	// hasher hashes a key of type K. The hash is reduced to the bucket containing the key/value.
+	hasher Hasher[K]
+	// normalize, if set, maps a key onto its canonical form before it is hashed,
+	// compared or stored.
+	normalize func(K) K
+	// layout records how entries are stored, so that tables derived from this one
+	// store theirs the same way.
+	layout layout[K]
+	store  storage[K, V]
+	// small is set while store is the array of a table created with WithSmallTable,
+	// whose keys are not hashed.
+	small bool
+	// skew, if set, watches for buckets that grow suspiciously long.
+	skew *skewDetector[K]
+	// deadlines holds the TTL of each key given one and the time it expires, or nil
+	// before the first InsertWithTTL. It may hold deadlines for keys no longer
+	// stored, which add discards when the key is added again.
+	deadlines *HashTable[K, expiry]
+	// defaultTTL, if positive, is the TTL add gives every key, as set by
+	// WithDefaultTTL.
+	defaultTTL time.Duration
+	// maxEntries, if positive, is the number of entries above which add evicts one,
+	// and onEvict is called with every entry that leaves the table, as set by
+	// WithMaxEntries and WithOnEvict.
+	maxEntries int
+	onEvict    func(key K, value V, reason EvictReason)
+	// writes holds the settings of WithWriteThrough or WithWriteBehind until a
+	// SyncHashTable created with them takes them over.
+	writes *writeOptions[K, V]
 }
 
-// A kv stores generic key/value data in a HashTable.
-type kv[K comparable, V any] struct {
+// A kv stores generic key/value data in a HashTable, along with the hash of the key
+// so that entries can be moved without hashing their keys again.
+type kv[K any, V any] struct {
 	Key   K
 	Value V
+	hash  uint64
 }
 
-type hash[K comparable] func(K, int) int
+// An Entry is a key/value pair returned from a HashTable.
+type Entry[K any, V any] struct {
+	Key   K
+	Value V
+}
 
-// New creates a table with n number of internal buckets which uses the specified hash
-// function for an input type K.
-func New[K comparable, V any](numberOfBuckets int, hash hash[K]) *HashTable[K, V] {
-	return &HashTable[K, V]{
-		hash:            hash,
-		numberOfBuckets: numberOfBuckets,
-		table:           make([][]kv[K, V], numberOfBuckets),
+// A Hasher hashes keys of type K to 64-bit values. The HashTable reduces the hash to
+// a bucket itself, so a Hasher does not need to know how many buckets there are.
+//
+// If keys may be chosen by an attacker, the Hasher should be seeded with a secret
+// value, so that colliding keys cannot be precomputed.
+type Hasher[K any] interface {
+	Hash(key K) uint64
+}
+
+// The HasherFunc type is an adapter to allow the use of ordinary functions as a Hasher.
+type HasherFunc[K any] func(key K) uint64
+
+// Hash returns f(key).
+func (f HasherFunc[K]) Hash(key K) uint64 {
+	return f(key)
+}
+
+// New creates a table with n number of internal buckets. Unless a Hasher is supplied
+// with WithHasher, a default seeded Hasher from the hashers package is chosen: FNV-1a
+// for strings, bit mixing for integers and hash/maphash for any other comparable type.
+// Each table draws its own random seed, so the buckets keys fall into cannot be
+// predicted from outside the process.
+func New[K comparable, V any](numberOfBuckets int, opts ...Option[K, V]) *HashTable[K, V] {
+	ht := newTable[K, V](numberOfBuckets, nil, equal[K], opts)
+	if ht.hasher == nil {
+		ht.hasher = defaultHasher[K]()
+	}
+	if ht.skew != nil && ht.skew.newHasher == nil {
+		ht.skew.newHasher = defaultHasher[K]
 	}
+	return ht
 }
 
-// Insert a new key/value pair.
-func (ht *HashTable[K, V]) Insert(key K, value V) {
-	bucket := ht.hash(key, ht.numberOfBuckets)
+// NewFunc creates a table with n number of internal buckets for keys that are not
+// comparable with ==, such as slices or structs containing slices. Keys are hashed
+// with hash and compared with equal; keys that are equal must have the same hash.
+func NewFunc[K any, V any](numberOfBuckets int, hash func(K) uint64, equal func(a, b K) bool, opts ...Option[K, V]) *HashTable[K, V] {
+	return newTable(numberOfBuckets, HasherFunc[K](hash), equal, opts)
+}
 
-	for n, data := range ht.table[bucket] {
-		if key == data.Key {
-			// overwrite previous value for the same key
-			ht.table[bucket][n].Value = value
-			return
+// newTable creates an empty table, applies opts to it and then allocates its storage.
+func newTable[K any, V any](numberOfBuckets int, hasher Hasher[K], equal func(a, b K) bool, opts []Option[K, V]) *HashTable[K, V] {
+	ht := &HashTable[K, V]{
+		hasher: hasher,
+		layout: layout[K]{
+			equal:   equal,
+			maxLoad: DefaultMaxLoadFactor,
+		},
+	}
+	for _, opt := range opts {
+		opt(ht)
+	}
+	if ht.small {
+		ht.store = newSmall[K, V](ht.layout, numberOfBuckets)
+	} else {
+		ht.store = newStorage[K, V](ht.layout, numberOfBuckets)
+	}
+	return ht
+}
+
+// defaultHasher returns a randomly seeded Hasher for K from the hashers package.
+func defaultHasher[K comparable]() Hasher[K] {
+	seed := rand.Uint64()
+	var h any
+	switch k := any(*new(K)).(type) {
+	case string:
+		h = hashers.String{Seed: seed}
+	case int:
+		h = hashers.Int[int]{Seed: seed}
+	case int32:
+		h = hashers.Int[int32]{Seed: seed}
+	case int64:
+		h = hashers.Int[int64]{Seed: seed}
+	case uint:
+		h = hashers.Int[uint]{Seed: seed}
+	case uint32:
+		h = hashers.Int[uint32]{Seed: seed}
+	case uint64:
+		h = hashers.Int[uint64]{Seed: seed}
+	case compositeKey:
+		h = k.defaultHasher()
+	default:
+		return hashers.NewComparable[K]()
+	}
+	return h.(Hasher[K])
+}
+
+// NewFromMap creates a table holding every key/value pair of m. The number of buckets
+// is derived from len(m) so that each bucket holds about one key.
+func NewFromMap[K comparable, V any](m map[K]V, opts ...Option[K, V]) *HashTable[K, V] {
+	ht := New(max(len(m), 1), opts...)
+	for key, value := range m {
+		if ht.normalize != nil {
+			// normalization may map distinct keys of m onto the same key
+			ht.Insert(key, value)
+			continue
 		}
+		// keys of a map are unique, so there is no need to search the bucket
+		ht.add(key, ht.hasher.Hash(key), value)
+	}
+	return ht
+}
+
+// Insert a new key/value pair.
+func (ht *HashTable[K, V]) Insert(key K, value V) {
+	key, h, e := ht.find(key)
+	if e != nil {
+		// overwrite previous value for the same key
+		e.Value = value
+		return
 	}
 
 	// add a new value to the table
-	ht.table[bucket] = append(ht.table[bucket], kv[K, V]{
-		Key:   key,
-		Value: value,
-	})
+	ht.add(key, h, value)
 }
 
-func (ht *HashTable[K, V]) Delete(key K) {
-	var value V
-	bucket := ht.hash(key, ht.numberOfBuckets)
-	for n, data := range ht.table[bucket] {
-		if key == data.Key {
-			// overwrite previous value for the same key
-			ht.table[bucket][n].Value = value
-			return
+// GetOrInsert returns the existing value for key if present. Otherwise it inserts
+// value and returns it. The boolean is true if the value was loaded, false if inserted.
+func (ht *HashTable[K, V]) GetOrInsert(key K, value V) (V, bool) {
+	key, h, e := ht.find(key)
+	if e != nil {
+		return e.Value, true
+	}
+
+	ht.add(key, h, value)
+	return value, false
+}
+
+// Upsert inserts value for key, or if key is already stored replaces its value
+// with merge(old, value). It returns the value stored afterwards.
+func (ht *HashTable[K, V]) Upsert(key K, value V, merge func(old, new V) V) V {
+	key, h, e := ht.find(key)
+	if e == nil {
+		ht.add(key, h, value)
+		return value
+	}
+
+	merged := merge(e.Value, value)
+	e.Value = merged
+	return merged
+}
+
+// Update replaces the value for key with fn(current) and returns the result.
+// If key is not stored, fn is called with the zero value and the result is inserted.
+func (ht *HashTable[K, V]) Update(key K, fn func(V) V) V {
+	key, h, e := ht.find(key)
+	if e == nil {
+		var zero V
+		value := fn(zero)
+		ht.add(key, h, value)
+		return value
+	}
+
+	value := fn(e.Value)
+	e.Value = value
+	return value
+}
+
+// ComputeIfAbsent returns the value for key, calling fn to construct and insert
+// it only when key is not already stored.
+func (ht *HashTable[K, V]) ComputeIfAbsent(key K, fn func(K) V) V {
+	key, h, e := ht.find(key)
+	if e != nil {
+		return e.Value
+	}
+
+	value := fn(key)
+	ht.add(key, h, value)
+	return value
+}
+
+// Swap stores value for key and returns the previous value. The boolean reports
+// whether key was already stored.
+func (ht *HashTable[K, V]) Swap(key K, value V) (V, bool) {
+	key, h, e := ht.find(key)
+	if e == nil {
+		ht.add(key, h, value)
+		var previous V
+		return previous, false
+	}
+
+	previous := e.Value
+	e.Value = value
+	return previous, true
+}
+
+// CompareAndSwapFunc stores value for key only if key is stored and eq reports
+// its current value equal to old. It reports whether the swap happened.
+func (ht *HashTable[K, V]) CompareAndSwapFunc(key K, old, value V, eq func(a, b V) bool) bool {
+	_, _, e := ht.find(key)
+	if e == nil || !eq(e.Value, old) {
+		return false
+	}
+
+	e.Value = value
+	return true
+}
+
+// CompareAndDeleteFunc removes key only if it is stored and eq reports its
+// current value equal to old. It reports whether the entry was removed.
+func (ht *HashTable[K, V]) CompareAndDeleteFunc(key K, old V, eq func(a, b V) bool) bool {
+	key, h, e := ht.find(key)
+	if e == nil || !eq(e.Value, old) {
+		return false
+	}
+
+	data, _ := ht.store.remove(key, h)
+	ht.evicted(data.Key, data.Value, EvictDeleted)
+	return true
+}
+
+// add stores a new key/value pair whose key hashes to h. The caller must have
+// checked that key is not already present.
+func (ht *HashTable[K, V]) add(key K, h uint64, value V) {
+	if ht.defaultTTL > 0 {
+		ht.setTTL(key, ht.defaultTTL)
+	} else if ht.deadlines != nil {
+		ht.deadlines.Delete(key)
+	}
+	if ht.maxEntries > 0 {
+		ht.makeRoom()
+	}
+	if ht.small && ht.store.len() == smallTableSize {
+		ht.promote()
+		h = ht.hasher.Hash(key)
+	}
+	length := ht.store.add(key, h, value)
+	if ht.skew != nil && length > ht.skew.maxChain {
+		ht.checkSkew(h, length)
+	}
+}
+
+// Delete removes key from the HashTable and returns the value it held.
+// The boolean reports whether the key was present.
+func (ht *HashTable[K, V]) Delete(key K) (V, bool) {
+	key, h := ht.hash(key)
+	data, ok := ht.store.remove(key, h)
+	if ok && ht.deadlines != nil {
+		if x, had := ht.deadlines.Delete(key); had && now() >= x.deadline {
+			// an expired entry was already absent
+			ht.evicted(data.Key, data.Value, EvictExpired)
+			var zero V
+			return zero, false
 		}
 	}
+	if ok {
+		ht.evicted(data.Key, data.Value, EvictDeleted)
+	}
+	return data.Value, ok
+}
+
+// Pop removes key from the HashTable and returns its value, so a caller can
+// claim an entry in a single call. It is equivalent to Delete.
+func (ht *HashTable[K, V]) Pop(key K) (V, bool) {
+	return ht.Delete(key)
 }
 
 func (ht *HashTable[K, V]) Search(key K) (V, bool) {
-	bucket := ht.hash(key, ht.numberOfBuckets)
+	_, _, e := ht.find(key)
+	if e == nil {
+		// no match
+		var value V
+		return value, false
+	}
 
-	for n, data := range ht.table[bucket] {
-		if key == data.Key {
-			// match found
-			return ht.table[bucket][n].Value, true
-		}
+	// match found
+	return e.Value, true
+}
+
+// GetOrDefault returns the value stored for key, or fallback if key is not stored.
+func (ht *HashTable[K, V]) GetOrDefault(key K, fallback V) V {
+	_, _, e := ht.find(key)
+	if e == nil {
+		return fallback
 	}
+	return e.Value
+}
 
-	// no match
-	var value V
-	return value, false
+// Contains reports whether key is stored in the HashTable without copying its value.
+func (ht *HashTable[K, V]) Contains(key K) bool {
+	_, _, e := ht.find(key)
+	return e != nil
+}
+
+// hash normalizes key and returns it together with its hash.
+func (ht *HashTable[K, V]) hash(key K) (K, uint64) {
+	if ht.normalize != nil {
+		key = ht.normalize(key)
+	}
+	if ht.small {
+		return key, 0
+	}
+	return key, ht.hasher.Hash(key)
+}
+
+// find normalizes key and returns it, together with its hash and the entry stored
+// for it, or nil when key is not stored. The entry may be modified in place until
+// the table is next modified. An entry whose TTL has run out is removed, so a
+// caller adding key finds no trace of it.
+func (ht *HashTable[K, V]) find(key K) (K, uint64, *kv[K, V]) {
+	key, h := ht.hash(key)
+	e := ht.store.find(key, h)
+	if e != nil && ht.expired(key) {
+		data, _ := ht.store.remove(key, h)
+		ht.deadlines.Delete(key)
+		ht.evicted(data.Key, data.Value, EvictExpired)
+		return key, h, nil
+	}
+	return key, h, e
+}
+
+// lookup returns the entry stored for key like find, but leaves an entry whose TTL
+// has run out in place, for readers that must not modify the table.
+func (ht *HashTable[K, V]) lookup(key K) *kv[K, V] {
+	key, h := ht.hash(key)
+	e := ht.store.find(key, h)
+	if e != nil && ht.expired(key) {
+		return nil
+	}
+	return e
+}
+
+// peek returns the value of key like Search, without modifying the table.
+func (ht *HashTable[K, V]) peek(key K) (V, bool) {
+	if e := ht.lookup(key); e != nil {
+		return e.Value, true
+	}
+	var zero V
+	return zero, false
 }
 
 func (ht *HashTable[K, V]) Keys() array.Array[K] {
 	var keys array.Array[K]
-	// Loop through the hash table buckets
-	for _, bucket := range ht.table {
-		// If the bucket has any keys
-		if len(bucket) > 0 {
-			for i := 0; i < len(bucket); i++ {
-				// put the key in the keys array
-				keys.Push(bucket[i].Key)
-			}
+	// Loop through the hash table entries
+	ht.store.all(func(data *kv[K, V]) bool {
+		// put the key in the keys array
+		keys.Push(data.Key)
+		return true
+	})
+	return keys
+}
+
+// Len returns the number of keys stored in the HashTable.
+func (ht *HashTable[K, V]) Len() int {
+	return ht.store.len()
+}
+
+// Values returns every value stored in the HashTable, in bucket order.
+func (ht *HashTable[K, V]) Values() array.Array[V] {
+	var values array.Array[V]
+	// Loop through the hash table entries
+	ht.store.all(func(data *kv[K, V]) bool {
+		// put the value in the values array
+		values.Push(data.Value)
+		return true
+	})
+	return values
+}
+
+// Entries returns every key/value pair stored in the HashTable, in bucket order.
+func (ht *HashTable[K, V]) Entries() []Entry[K, V] {
+	entries := make([]Entry[K, V], 0, ht.Len())
+	ht.store.all(func(data *kv[K, V]) bool {
+		entries = append(entries, Entry[K, V]{Key: data.Key, Value: data.Value})
+		return true
+	})
+	return entries
+}
+
+// Clear removes every key/value pair while keeping the allocated buckets,
+// so the HashTable can be reused without reallocating.
+func (ht *HashTable[K, V]) Clear() {
+	ht.evictAll()
+	ht.store.clear()
+	ht.deadlines = nil
+}
+
+// Rehash rebuilds every bucket under h and keeps using h for later operations, so a
+// table can move to a new Hasher, such as a seeded one replacing a weak one, without
+// being reconstructed. Every entry is kept.
+func (ht *HashTable[K, V]) Rehash(h Hasher[K]) {
+	ht.hasher = h
+	if ht.small {
+		// small tables store no hashes
+		return
+	}
+	old := ht.store
+	positions, _ := old.slots()
+	ht.store = newStorage[K, V](ht.layout, positions)
+	old.all(func(data *kv[K, V]) bool {
+		ht.store.add(data.Key, h.Hash(data.Key), data.Value)
+		return true
+	})
+}
+
+// Compact reallocates every bucket whose backing array is larger than its entries
+// need, returning the memory left behind by deletions to the garbage collector.
+// The number of buckets is unchanged.
+func (ht *HashTable[K, V]) Compact() {
+	ht.store.compact()
+}
+
+// Shrink halves the number of buckets for as long as the table would still hold at
+// most half the maximum load factor, then compacts the table like Compact. Tables
+// with a fixed number of buckets, from WithMaxLoadFactor(0), are only compacted.
+func (ht *HashTable[K, V]) Shrink() {
+	ht.store.shrink()
+}
+
+// Reserve prepares the table to hold n entries without growing: the number of
+// buckets is raised to what n entries need under the maximum load factor, and every
+// bucket is given room for its share of n, carved out of a single allocation. Bulk
+// loading a table of known size after Reserve avoids both repeated rehashing and
+// most per-bucket growth.
+func (ht *HashTable[K, V]) Reserve(n int) {
+	if ht.small && n > smallTableSize {
+		ht.promote()
+	}
+	if n > 0 {
+		ht.store.reserve(n)
+	}
+}
+
+// Number is a constraint matching the built-in integer and floating point types.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Increment adds delta to the value stored for key, treating a missing key as zero,
+// and returns the new value. It lets a HashTable be used as a frequency counter.
+func Increment[K any, V Number](ht *HashTable[K, V], key K, delta V) V {
+	return ht.Update(key, func(v V) V {
+		return v + delta
+	})
+}
+
+// AppendValue appends items to the slice stored for key, creating the slice if key
+// is not stored, and returns the resulting slice.
+func AppendValue[K any, T any](ht *HashTable[K, []T], key K, items ...T) []T {
+	return ht.Update(key, func(v []T) []T {
+		return append(v, items...)
+	})
+}
+
+// CompareAndSwap stores value for key only if its current value is old.
+// It reports whether the swap happened.
+func CompareAndSwap[K any, V comparable](ht *HashTable[K, V], key K, old, value V) bool {
+	return ht.CompareAndSwapFunc(key, old, value, equal[V])
+}
+
+// CompareAndDelete removes key only if its current value is old.
+// It reports whether the entry was removed.
+func CompareAndDelete[K any, V comparable](ht *HashTable[K, V], key K, old V) bool {
+	return ht.CompareAndDeleteFunc(key, old, equal[V])
+}
+
+func equal[T comparable](a, b T) bool {
+	return a == b
+}
+
+// Clone returns a copy of the HashTable using the same hash function and number of
+// buckets. Values are copied by assignment, so pointer values share their targets.
+func (ht *HashTable[K, V]) Clone() *HashTable[K, V] {
+	return ht.CloneFunc(func(v V) V { return v })
+}
+
+// CloneFunc returns a copy of the HashTable where every value is copied with
+// cloneValue, allowing deep copies of pointer or slice values.
+func (ht *HashTable[K, V]) CloneFunc(cloneValue func(V) V) *HashTable[K, V] {
+	clone := MapValues(ht, cloneValue)
+	clone.onEvict = ht.onEvict
+	return clone
+}
+
+// EqualFunc reports whether ht and other store the same keys with values that eq
+// reports equal. Bucket layout and hash functions do not need to match.
+func (ht *HashTable[K, V]) EqualFunc(other *HashTable[K, V], eq func(a, b V) bool) bool {
+	if ht.Len() != other.Len() {
+		return false
+	}
+	equal := true
+	ht.store.all(func(data *kv[K, V]) bool {
+		e := other.lookup(data.Key)
+		equal = e != nil && eq(data.Value, e.Value)
+		return equal
+	})
+	return equal
+}
+
+// Equal reports whether a and b store the same key/value pairs.
+func Equal[K any, V comparable](a, b *HashTable[K, V]) bool {
+	return a.EqualFunc(b, equal[V])
+}
+
+// Merge inserts every entry of other into ht. When a key is stored in both tables,
+// the stored value becomes resolve(key, current, incoming); a nil resolve keeps
+// the incoming value from other.
+func (ht *HashTable[K, V]) Merge(other *HashTable[K, V], resolve func(key K, a, b V) V) {
+	other.store.all(func(data *kv[K, V]) bool {
+		ht.merge(data.Key, data.Value, resolve)
+		return true
+	})
+}
+
+// merge stores value for key as Merge does for each entry of the other table.
+func (ht *HashTable[K, V]) merge(key K, value V, resolve func(key K, a, b V) V) {
+	original := key
+	key, h, e := ht.find(key)
+	switch {
+	case e == nil:
+		ht.add(key, h, value)
+	case resolve == nil:
+		e.Value = value
+	default:
+		e.Value = resolve(original, e.Value, value)
+	}
+}
+
+// A Difference lists the keys that differ between two tables, as returned by Diff.
+type Difference[K any] struct {
+	// Added holds keys stored only in the other table.
+	Added []K
+	// Removed holds keys stored only in the receiver.
+	Removed []K
+	// Changed holds keys stored in both tables with different values.
+	Changed []K
+}
+
+// DiffFunc reports how other differs from ht, using eq to compare values stored
+// under the same key. Expired entries count as absent, and are left in both tables.
+func (ht *HashTable[K, V]) DiffFunc(other *HashTable[K, V], eq func(a, b V) bool) Difference[K] {
+	var diff Difference[K]
+	shared := 0
+	ht.store.all(func(data *kv[K, V]) bool {
+		if ht.expired(data.Key) {
+			return true
+		}
+		e := other.lookup(data.Key)
+		if e == nil {
+			diff.Removed = append(diff.Removed, data.Key)
+			return true
 		}
+		shared++
+		if !eq(data.Value, e.Value) {
+			diff.Changed = append(diff.Changed, data.Key)
+		}
+		return true
+	})
+
+	// every key in other was matched above, so nothing can have been added
+	if shared == other.Len() {
+		return diff
 	}
-	return keys
+	other.store.all(func(data *kv[K, V]) bool {
+		if !other.expired(data.Key) && ht.lookup(data.Key) == nil {
+			diff.Added = append(diff.Added, data.Key)
+		}
+		return true
+	})
+	return diff
+}
+
+// Diff reports how b differs from a.
+func Diff[K any, V comparable](a, b *HashTable[K, V]) Difference[K] {
+	return a.DiffFunc(b, equal[V])
+}
+
+// ToMap copies every key/value pair of ht into a new built-in map.
+func ToMap[K comparable, V any](ht *HashTable[K, V]) map[K]V {
+	m := make(map[K]V, ht.Len())
+	ht.store.all(func(data *kv[K, V]) bool {
+		m[data.Key] = data.Value
+		return true
+	})
+	return m
+}
+
+// DeleteFunc removes every entry for which del returns true and reports how many
+// entries were removed. Buckets are swept directly, so no key is hashed.
+func (ht *HashTable[K, V]) DeleteFunc(del func(K, V) bool) int {
+	return ht.store.deleteFunc(func(data *kv[K, V]) bool {
+		if !del(data.Key, data.Value) {
+			return false
+		}
+		ht.evicted(data.Key, data.Value, EvictDeleted)
+		return true
+	})
+}
+
+// RetainFunc keeps only the entries for which keep returns true, rewriting buckets
+// in place, and reports how many entries were removed.
+func (ht *HashTable[K, V]) RetainFunc(keep func(K, V) bool) int {
+	return ht.DeleteFunc(func(key K, value V) bool {
+		return !keep(key, value)
+	})
+}
+
+// Range calls fn for each key/value pair in bucket order. If fn returns false,
+// Range stops the iteration. fn must not insert into or delete from the HashTable.
+func (ht *HashTable[K, V]) Range(fn func(K, V) bool) {
+	for key, value := range ht.All() {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// Filter returns a new table, with the same hash function and number of buckets,
+// holding only the entries for which keep returns true. ht is not modified.
+func (ht *HashTable[K, V]) Filter(keep func(K, V) bool) *HashTable[K, V] {
+	filtered := newLike[K, V, V](ht)
+	ht.store.all(func(data *kv[K, V]) bool {
+		if keep(data.Key, data.Value) {
+			// the stored hash places the key without hashing it again
+			filtered.store.add(data.Key, data.hash, data.Value)
+		}
+		return true
+	})
+	filtered.deadlines = ht.cloneDeadlines()
+	filtered.inheritLimits(ht)
+	return filtered
+}
+
+// newLike returns an empty table with the same Hasher, key equality, normalizer and
+// number of buckets as ht.
+func newLike[K any, V, V2 any](ht *HashTable[K, V]) *HashTable[K, V2] {
+	like := &HashTable[K, V2]{
+		hasher:    ht.hasher,
+		normalize: ht.normalize,
+		layout:    ht.layout,
+		small:     ht.small,
+	}
+	if ht.small {
+		like.store = newSmall[K, V2](ht.layout, ht.store.(*small[K, V]).n)
+	} else {
+		positions, _ := ht.store.slots()
+		like.store = newStorage[K, V2](ht.layout, positions)
+	}
+	if ht.skew != nil {
+		skew := *ht.skew
+		like.skew = &skew
+	}
+	return like
+}
+
+// newScratch returns an empty table that normalizes, hashes and compares keys as ht
+// does, with one bucket of the default storage to start with, for bookkeeping
+// keyed like ht.
+func newScratch[K any, V, V2 any](ht *HashTable[K, V]) *HashTable[K, V2] {
+	l := layout[K]{equal: ht.layout.equal, maxLoad: DefaultMaxLoadFactor}
+	return &HashTable[K, V2]{
+		hasher:    ht.hasher,
+		normalize: ht.normalize,
+		layout:    l,
+		store:     newStorage[K, V2](l, 1),
+	}
+}
+
+// MapValues returns a new table holding every key of ht with its value transformed
+// by fn. Keys are placed using their stored hashes, so nothing is rehashed.
+func MapValues[K any, V, V2 any](ht *HashTable[K, V], fn func(V) V2) *HashTable[K, V2] {
+	mapped := newLike[K, V, V2](ht)
+	mapped.store.reserve(ht.Len())
+	ht.store.all(func(data *kv[K, V]) bool {
+		mapped.store.add(data.Key, data.hash, fn(data.Value))
+		return true
+	})
+	// the callback of ht cannot take values of type V2
+	mapped.deadlines = ht.cloneDeadlines()
+	mapped.defaultTTL, mapped.maxEntries = ht.defaultTTL, ht.maxEntries
+	return mapped
+}
+
+// Reduce folds every key/value pair of ht into an accumulator, starting from initial,
+// and returns the final accumulator.
+func Reduce[K any, V, A any](ht *HashTable[K, V], initial A, fn func(A, K, V) A) A {
+	acc := initial
+	for key, value := range ht.All() {
+		acc = fn(acc, key, value)
+	}
+	return acc
+}
+
+// Any reports whether pred returns true for at least one entry. It stops at the
+// first match.
+func (ht *HashTable[K, V]) Any(pred func(K, V) bool) bool {
+	found := false
+	ht.Range(func(key K, value V) bool {
+		found = pred(key, value)
+		return !found
+	})
+	return found
+}
+
+// Every reports whether pred returns true for every entry. It stops at the first
+// entry for which pred returns false.
+func (ht *HashTable[K, V]) Every(pred func(K, V) bool) bool {
+	return !ht.Any(func(key K, value V) bool {
+		return !pred(key, value)
+	})
+}
+
+// Find returns the first entry, in bucket order, for which pred returns true.
+// The boolean reports whether such an entry exists.
+func (ht *HashTable[K, V]) Find(pred func(K, V) bool) (K, V, bool) {
+	for key, value := range ht.All() {
+		if pred(key, value) {
+			return key, value, true
+		}
+	}
+
+	var key K
+	var value V
+	return key, value, false
 }
@@ -1,6 +1,765 @@
 package hashtable
 
-import "testing"
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jkittell/array"
+	"github.com/jkittell/hashtable/hashers"
+)
 
 func TestHashTable_Example1(t *testing.T) {
 }
+
+// withHashInt makes an int-keyed table place key k in bucket k modulo the number of
+// buckets, so the tests control exactly which keys share a bucket.
+func withHashInt[V any]() Option[int, V] {
+	return WithHasher[int, V](HasherFunc[int](func(key int) uint64 {
+		return uint64(key)
+	}))
+}
+
+// chainedStore returns the default chained storage of ht, for tests that inspect
+// its buckets.
+func chainedStore[K any, V any](ht *HashTable[K, V]) *chained[K, V] {
+	return ht.store.(*chained[K, V])
+}
+
+func TestHashTable_Len(t *testing.T) {
+	ht := New[string, int](8)
+	if ht.Len() != 0 {
+		t.Fatalf("Len() = %d, want 0", ht.Len())
+	}
+
+	ht.Insert("a", 1)
+	ht.Insert("b", 2)
+	ht.Insert("a", 3)
+	if ht.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", ht.Len())
+	}
+}
+
+func TestHashTable_Values(t *testing.T) {
+	ht := New[string, int](4)
+	if got, want := ht.Values(), (array.Array[int]{}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values() of an empty table = %v, want none", got)
+	}
+
+	// a value stored for two keys is returned twice, a replaced value once, and the
+	// value of a deleted key not at all
+	ht.Insert("a", 1)
+	ht.Insert("b", 1)
+	ht.Insert("c", 2)
+	ht.Insert("c", 3)
+	ht.Insert("d", 4)
+	ht.Delete("d")
+	// Values follows the order of Entries
+	var want array.Array[int]
+	seen := map[int]int{}
+	for _, e := range ht.Entries() {
+		want.Push(e.Value)
+		seen[e.Value]++
+	}
+	if got := ht.Values(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(seen, map[int]int{1: 2, 3: 1}) {
+		t.Fatalf("Entries() hold the values %v, want 1 twice and 3 once", seen)
+	}
+}
+
+func TestHashTable_Entries(t *testing.T) {
+	ht := New[string, int](4)
+	want := map[string]int{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}
+	for k, v := range want {
+		ht.Insert(k, v)
+	}
+
+	entries := ht.Entries()
+	if len(entries) != len(want) {
+		t.Fatalf("len(Entries()) = %d, want %d", len(entries), len(want))
+	}
+	for _, e := range entries {
+		if want[e.Key] != e.Value {
+			t.Errorf("entry %q = %d, want %d", e.Key, e.Value, want[e.Key])
+		}
+	}
+}
+
+func TestHashTable_Clear(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("a", 1)
+	ht.Insert("b", 2)
+
+	ht.Clear()
+	if ht.Len() != 0 {
+		t.Fatalf("Len() = %d, want 0", ht.Len())
+	}
+	if _, ok := ht.Search("a"); ok {
+		t.Fatal("Search(\"a\") found a value after Clear")
+	}
+
+	ht.Insert("a", 3)
+	if v, ok := ht.Search("a"); !ok || v != 3 {
+		t.Fatalf("Search(\"a\") = %d, %v, want 3, true", v, ok)
+	}
+}
+
+func TestHashTable_Rehash(t *testing.T) {
+	// every key starts in bucket 0
+	ht := New(8, WithHasher[int, int](HasherFunc[int](func(int) uint64 { return 0 })))
+	for k := 0; k < 64; k++ {
+		ht.Insert(k, k*10)
+	}
+
+	ht.Rehash(hashers.Int[int]{Seed: 1})
+	if ht.Len() != 64 {
+		t.Fatalf("Len() = %d after Rehash, want 64", ht.Len())
+	}
+	for k := 0; k < 64; k++ {
+		if v, ok := ht.Search(k); !ok || v != k*10 {
+			t.Fatalf("Search(%d) = %d, %v after Rehash, want %d, true", k, v, ok, k*10)
+		}
+	}
+	if d := ht.Distribution(); d.Longest == 64 {
+		t.Fatal("Rehash left every key in a single bucket")
+	}
+
+	ht.Insert(100, 1000)
+	if v, _ := ht.Search(100); v != 1000 || ht.Len() != 65 {
+		t.Fatalf("Search(100) = %d, Len() = %d after inserting under the new Hasher", v, ht.Len())
+	}
+}
+
+func TestHashTable_Compact(t *testing.T) {
+	ht := New(4, withHashInt[int](), WithMaxLoadFactor[int, int](0))
+	for k := 0; k < 400; k++ {
+		ht.Insert(k, k)
+	}
+	ht.DeleteFunc(func(k, _ int) bool { return k >= 4 || k == 1 })
+
+	ht.Compact()
+	for n, bucket := range chainedStore(ht).table {
+		if cap(bucket) != len(bucket) {
+			t.Errorf("bucket %d has capacity %d for %d entries", n, cap(bucket), len(bucket))
+		}
+	}
+	if chainedStore(ht).table[1] != nil {
+		t.Error("empty bucket 1 still holds a backing array")
+	}
+	if ht.Len() != 3 || !ht.Contains(3) || ht.Contains(1) {
+		t.Fatalf("Compact changed the entries: %v", ht.Entries())
+	}
+}
+
+func TestHashTable_Shrink(t *testing.T) {
+	ht := New[int, int](4)
+	for k := 0; k < 1000; k++ {
+		ht.Insert(k, k)
+	}
+	ht.DeleteFunc(func(k, _ int) bool { return k >= 10 })
+
+	ht.Shrink()
+	// 10 entries fit in 16 buckets at half the default load factor of 2
+	if d := ht.Distribution(); d.Buckets != 16 {
+		t.Fatalf("Buckets = %d after Shrink, want 16", d.Buckets)
+	}
+	for k := 0; k < 10; k++ {
+		if v, ok := ht.Search(k); !ok || v != k {
+			t.Fatalf("Search(%d) = %d, %v after Shrink, want %d, true", k, v, ok, k)
+		}
+	}
+
+	fixed := New(8, WithMaxLoadFactor[int, int](0))
+	fixed.Insert(1, 1)
+	fixed.Shrink()
+	if d := fixed.Distribution(); d.Buckets != 8 {
+		t.Fatalf("Buckets = %d after Shrink with a fixed bucket count, want 8", d.Buckets)
+	}
+}
+
+func TestHashTable_Reserve(t *testing.T) {
+	ht := New(4, withHashInt[int]())
+	ht.Insert(1, 1)
+
+	ht.Reserve(1000)
+	// 1000 entries need 512 buckets at the default load factor of 2
+	if d := ht.Distribution(); d.Buckets != 512 {
+		t.Fatalf("Buckets = %d after Reserve(1000), want 512", d.Buckets)
+	}
+	for n, bucket := range chainedStore(ht).table {
+		if cap(bucket) < 2 {
+			t.Fatalf("bucket %d has capacity %d, want at least 2", n, cap(bucket))
+		}
+	}
+
+	for k := 0; k < 1000; k++ {
+		ht.Insert(k, k*10)
+	}
+	if d := ht.Distribution(); d.Buckets != 512 || ht.Len() != 1000 {
+		t.Fatalf("Buckets = %d, Len() = %d after filling the reservation, want 512, 1000", d.Buckets, ht.Len())
+	}
+
+	// bucket 0 outgrows its reservation, which must not overwrite bucket 1
+	fixed := New(2, withHashInt[int](), WithMaxLoadFactor[int, int](0))
+	fixed.Reserve(4)
+	for _, k := range []int{1, 0, 2, 4, 6} {
+		fixed.Insert(k, k*10)
+	}
+	for _, k := range []int{1, 0, 2, 4, 6} {
+		if v, ok := fixed.Search(k); !ok || v != k*10 {
+			t.Fatalf("Search(%d) = %d, %v, want %d, true", k, v, ok, k*10)
+		}
+	}
+}
+
+func TestHashTable_Contains(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("a", 1)
+
+	if !ht.Contains("a") {
+		t.Error("Contains(\"a\") = false, want true")
+	}
+	if ht.Contains("b") {
+		t.Error("Contains(\"b\") = true, want false")
+	}
+}
+
+func TestHashTable_Delete(t *testing.T) {
+	ht := New[string, int](2)
+	for i, k := range []string{"a", "b", "c", "d"} {
+		ht.Insert(k, i)
+	}
+
+	if v, ok := ht.Delete("b"); !ok || v != 1 {
+		t.Fatalf("Delete(\"b\") = %d, %v, want 1, true", v, ok)
+	}
+	if _, ok := ht.Delete("b"); ok {
+		t.Fatal("second Delete(\"b\") reported success")
+	}
+	if ht.Contains("b") {
+		t.Fatal("Contains(\"b\") = true after Delete")
+	}
+	if ht.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", ht.Len())
+	}
+	for i, k := range []string{"a", "c", "d"} {
+		if _, ok := ht.Search(k); !ok {
+			t.Errorf("Search(%q) #%d missing after deleting a neighbour", k, i)
+		}
+	}
+}
+
+func TestHashTable_GetOrInsert(t *testing.T) {
+	ht := New[string, int](4)
+
+	if v, loaded := ht.GetOrInsert("a", 1); loaded || v != 1 {
+		t.Fatalf("GetOrInsert(\"a\", 1) = %d, %v, want 1, false", v, loaded)
+	}
+	if v, loaded := ht.GetOrInsert("a", 2); !loaded || v != 1 {
+		t.Fatalf("GetOrInsert(\"a\", 2) = %d, %v, want 1, true", v, loaded)
+	}
+	if ht.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", ht.Len())
+	}
+}
+
+func TestHashTable_GetOrDefault(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("a", 1)
+
+	if v := ht.GetOrDefault("a", -1); v != 1 {
+		t.Errorf("GetOrDefault(\"a\", -1) = %d, want 1", v)
+	}
+	if v := ht.GetOrDefault("b", -1); v != -1 {
+		t.Errorf("GetOrDefault(\"b\", -1) = %d, want -1", v)
+	}
+}
+
+func TestHashTable_Pop(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("job", 7)
+
+	if v, ok := ht.Pop("job"); !ok || v != 7 {
+		t.Fatalf("Pop(\"job\") = %d, %v, want 7, true", v, ok)
+	}
+	if _, ok := ht.Pop("job"); ok {
+		t.Fatal("second Pop(\"job\") reported success")
+	}
+}
+
+func TestHashTable_Upsert(t *testing.T) {
+	ht := New[string, int](4)
+	sum := func(old, new int) int { return old + new }
+
+	if v := ht.Upsert("a", 2, sum); v != 2 {
+		t.Fatalf("first Upsert = %d, want 2", v)
+	}
+	if v := ht.Upsert("a", 3, sum); v != 5 {
+		t.Fatalf("second Upsert = %d, want 5", v)
+	}
+	if v, _ := ht.Search("a"); v != 5 {
+		t.Fatalf("Search(\"a\") = %d, want 5", v)
+	}
+}
+
+func TestHashTable_Update(t *testing.T) {
+	ht := New[string, int](4)
+	double := func(v int) int { return v*2 + 1 }
+
+	if v := ht.Update("a", double); v != 1 {
+		t.Fatalf("Update on missing key = %d, want 1", v)
+	}
+	if v := ht.Update("a", double); v != 3 {
+		t.Fatalf("Update on stored key = %d, want 3", v)
+	}
+}
+
+func TestHashTable_ComputeIfAbsent(t *testing.T) {
+	ht := New[string, int](4)
+	calls := 0
+	load := func(k string) int {
+		calls++
+		return len(k)
+	}
+
+	if v := ht.ComputeIfAbsent("abc", load); v != 3 {
+		t.Fatalf("ComputeIfAbsent = %d, want 3", v)
+	}
+	if v := ht.ComputeIfAbsent("abc", load); v != 3 {
+		t.Fatalf("ComputeIfAbsent = %d, want 3", v)
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times, want 1", calls)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	ht := New[string, int](4)
+	for _, word := range []string{"a", "b", "a", "a"} {
+		Increment(ht, word, 1)
+	}
+
+	if v, _ := ht.Search("a"); v != 3 {
+		t.Errorf("count of \"a\" = %d, want 3", v)
+	}
+	if v, _ := ht.Search("b"); v != 1 {
+		t.Errorf("count of \"b\" = %d, want 1", v)
+	}
+}
+
+func TestAppendValue(t *testing.T) {
+	ht := New[string, []int](4)
+	AppendValue(ht, "odd", 1)
+	AppendValue(ht, "even", 2)
+	AppendValue(ht, "odd", 3, 5)
+
+	odd, _ := ht.Search("odd")
+	if len(odd) != 3 || odd[0] != 1 || odd[1] != 3 || odd[2] != 5 {
+		t.Errorf("odd = %v, want [1 3 5]", odd)
+	}
+	if even, _ := ht.Search("even"); len(even) != 1 || even[0] != 2 {
+		t.Errorf("even = %v, want [2]", even)
+	}
+}
+
+func TestHashTable_Swap(t *testing.T) {
+	ht := New[string, int](4)
+
+	if old, existed := ht.Swap("a", 1); existed || old != 0 {
+		t.Fatalf("Swap on missing key = %d, %v, want 0, false", old, existed)
+	}
+	if old, existed := ht.Swap("a", 2); !existed || old != 1 {
+		t.Fatalf("Swap on stored key = %d, %v, want 1, true", old, existed)
+	}
+	if v, _ := ht.Search("a"); v != 2 {
+		t.Fatalf("Search(\"a\") = %d, want 2", v)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("a", 1)
+
+	if CompareAndSwap(ht, "a", 2, 3) {
+		t.Fatal("CompareAndSwap with stale old value succeeded")
+	}
+	if !CompareAndSwap(ht, "a", 1, 3) {
+		t.Fatal("CompareAndSwap with current old value failed")
+	}
+	if CompareAndSwap(ht, "b", 0, 1) {
+		t.Fatal("CompareAndSwap on missing key succeeded")
+	}
+	if v, _ := ht.Search("a"); v != 3 {
+		t.Fatalf("Search(\"a\") = %d, want 3", v)
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("a", 1)
+
+	if CompareAndDelete(ht, "a", 2) {
+		t.Fatal("CompareAndDelete with stale old value succeeded")
+	}
+	if !CompareAndDelete(ht, "a", 1) {
+		t.Fatal("CompareAndDelete with current old value failed")
+	}
+	if ht.Contains("a") {
+		t.Fatal("key still stored after CompareAndDelete")
+	}
+}
+
+func TestHashTable_Clone(t *testing.T) {
+	ht := New[string, []int](4)
+	ht.Insert("a", []int{1})
+	ht.Insert("b", []int{2})
+
+	clone := ht.Clone()
+	clone.Insert("c", []int{3})
+	if ht.Contains("c") {
+		t.Fatal("insert into clone is visible in the original")
+	}
+	if clone.Len() != 3 {
+		t.Fatalf("clone.Len() = %d, want 3", clone.Len())
+	}
+
+	deep := ht.CloneFunc(func(v []int) []int { return append([]int(nil), v...) })
+	a, _ := deep.Search("a")
+	a[0] = 100
+	if orig, _ := ht.Search("a"); orig[0] != 1 {
+		t.Fatalf("mutating a deep clone changed the original: %v", orig)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := New[string, int](2)
+	b := New[string, int](16)
+	for i, k := range []string{"a", "b", "c"} {
+		a.Insert(k, i)
+		b.Insert(k, i)
+	}
+	if !Equal(a, b) {
+		t.Fatal("tables with the same entries but different bucket counts are not Equal")
+	}
+
+	b.Insert("c", 10)
+	if Equal(a, b) {
+		t.Fatal("tables with different values are Equal")
+	}
+
+	b.Delete("c")
+	if Equal(a, b) {
+		t.Fatal("tables with different sizes are Equal")
+	}
+}
+
+func TestHashTable_Merge(t *testing.T) {
+	a := New[string, int](4)
+	a.Insert("x", 1)
+	a.Insert("y", 2)
+	b := New[string, int](8)
+	b.Insert("y", 10)
+	b.Insert("z", 20)
+
+	a.Merge(b, func(key string, current, incoming int) int { return current + incoming })
+
+	want := map[string]int{"x": 1, "y": 12, "z": 20}
+	if a.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", a.Len(), len(want))
+	}
+	for k, v := range want {
+		if got, _ := a.Search(k); got != v {
+			t.Errorf("Search(%q) = %d, want %d", k, got, v)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := New[string, int](4)
+	a.Insert("same", 1)
+	a.Insert("changed", 2)
+	a.Insert("removed", 3)
+	b := New[string, int](4)
+	b.Insert("same", 1)
+	b.Insert("changed", 20)
+	b.Insert("added", 4)
+
+	diff := Diff(a, b)
+	if len(diff.Added) != 1 || diff.Added[0] != "added" {
+		t.Errorf("Added = %v, want [added]", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != "removed" {
+		t.Errorf("Removed = %v, want [removed]", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0] != "changed" {
+		t.Errorf("Changed = %v, want [changed]", diff.Changed)
+	}
+}
+
+func TestHashTable_ToMap(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("a", 1)
+	ht.Insert("b", 2)
+
+	m := ToMap(ht)
+	if len(m) != 2 || m["a"] != 1 || m["b"] != 2 {
+		t.Fatalf("ToMap() = %v, want map[a:1 b:2]", m)
+	}
+}
+
+func TestNewFromMap(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2, "c": 3}
+	ht := NewFromMap(m)
+
+	if ht.Len() != len(m) {
+		t.Fatalf("Len() = %d, want %d", ht.Len(), len(m))
+	}
+	for k, v := range m {
+		if got, ok := ht.Search(k); !ok || got != v {
+			t.Errorf("Search(%q) = %d, %v, want %d, true", k, got, ok, v)
+		}
+	}
+
+	if empty := NewFromMap(map[string]int{}); empty.Len() != 0 {
+		t.Fatalf("NewFromMap(empty).Len() = %d, want 0", empty.Len())
+	}
+}
+
+func TestHashTable_DeleteFunc(t *testing.T) {
+	ht := New[int, int](3, withHashInt[int]())
+	for i := 0; i < 10; i++ {
+		ht.Insert(i, i*i)
+	}
+
+	removed := ht.DeleteFunc(func(k, v int) bool { return k%2 == 0 })
+	if removed != 5 {
+		t.Fatalf("DeleteFunc removed %d entries, want 5", removed)
+	}
+	if ht.Len() != 5 {
+		t.Fatalf("Len() = %d, want 5", ht.Len())
+	}
+	for i := 0; i < 10; i++ {
+		if ht.Contains(i) != (i%2 == 1) {
+			t.Errorf("Contains(%d) = %v", i, ht.Contains(i))
+		}
+	}
+}
+
+func TestHashTable_RetainFunc(t *testing.T) {
+	ht := New[int, int](3, withHashInt[int]())
+	for i := 0; i < 10; i++ {
+		ht.Insert(i, i)
+	}
+
+	if removed := ht.RetainFunc(func(k, v int) bool { return v < 3 }); removed != 7 {
+		t.Fatalf("RetainFunc removed %d entries, want 7", removed)
+	}
+	for i := 0; i < 10; i++ {
+		if ht.Contains(i) != (i < 3) {
+			t.Errorf("Contains(%d) = %v", i, ht.Contains(i))
+		}
+	}
+}
+
+func TestHashTable_Range(t *testing.T) {
+	ht := New[int, int](3, withHashInt[int]())
+	for i := 0; i < 10; i++ {
+		ht.Insert(i, i)
+	}
+
+	sum := 0
+	ht.Range(func(k, v int) bool {
+		sum += v
+		return true
+	})
+	if sum != 45 {
+		t.Fatalf("sum of values = %d, want 45", sum)
+	}
+
+	visited := 0
+	ht.Range(func(k, v int) bool {
+		visited++
+		return visited < 4
+	})
+	if visited != 4 {
+		t.Fatalf("Range visited %d entries after stopping, want 4", visited)
+	}
+}
+
+func TestHashTable_Filter(t *testing.T) {
+	ht := New[int, int](3, withHashInt[int]())
+	for i := 0; i < 10; i++ {
+		ht.Insert(i, i)
+	}
+
+	odd := ht.Filter(func(k, v int) bool { return v%2 == 1 })
+	if odd.Len() != 5 {
+		t.Fatalf("odd.Len() = %d, want 5", odd.Len())
+	}
+	if ht.Len() != 10 {
+		t.Fatalf("Filter modified the source table: Len() = %d", ht.Len())
+	}
+	for i := 1; i < 10; i += 2 {
+		if !odd.Contains(i) {
+			t.Errorf("odd.Contains(%d) = false", i)
+		}
+	}
+}
+
+func TestMapValues(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("a", 1)
+	ht.Insert("bb", 2)
+
+	labels := MapValues(ht, func(v int) string { return strings.Repeat("*", v) })
+	if labels.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", labels.Len())
+	}
+	if v, _ := labels.Search("bb"); v != "**" {
+		t.Fatalf("Search(\"bb\") = %q, want \"**\"", v)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("a", 3)
+	ht.Insert("b", 9)
+	ht.Insert("c", 4)
+
+	largest := Reduce(ht, 0, func(acc int, k string, v int) int { return max(acc, v) })
+	if largest != 9 {
+		t.Fatalf("Reduce max = %d, want 9", largest)
+	}
+}
+
+func TestHashTable_AnyEvery(t *testing.T) {
+	ht := New[int, int](3, withHashInt[int]())
+	for i := 0; i < 10; i++ {
+		ht.Insert(i, i)
+	}
+
+	if !ht.Any(func(k, v int) bool { return v == 7 }) {
+		t.Error("Any(v == 7) = false, want true")
+	}
+	if ht.Any(func(k, v int) bool { return v > 9 }) {
+		t.Error("Any(v > 9) = true, want false")
+	}
+	if !ht.Every(func(k, v int) bool { return v < 10 }) {
+		t.Error("Every(v < 10) = false, want true")
+	}
+
+	calls := 0
+	if ht.Every(func(k, v int) bool { calls++; return false }) {
+		t.Error("Every(false) = true, want false")
+	}
+	if calls != 1 {
+		t.Errorf("Every called pred %d times after a counterexample, want 1", calls)
+	}
+}
+
+func TestHashTable_Find(t *testing.T) {
+	ht := New[string, int](4)
+	ht.Insert("a", 1)
+	ht.Insert("b", 20)
+
+	k, v, ok := ht.Find(func(k string, v int) bool { return v > 10 })
+	if !ok || k != "b" || v != 20 {
+		t.Fatalf("Find(v > 10) = %q, %d, %v, want \"b\", 20, true", k, v, ok)
+	}
+	if _, _, ok := ht.Find(func(k string, v int) bool { return v > 100 }); ok {
+		t.Fatal("Find(v > 100) reported a match")
+	}
+}
+
+func TestNew_DefaultHash(t *testing.T) {
+	type point struct{ X, Y int }
+
+	strs := New[string, int](8)
+	ints := New[int, int](8)
+	points := New[point, int](8)
+	for i := 0; i < 100; i++ {
+		strs.Insert(strings.Repeat("x", i), i)
+		ints.Insert(i, i)
+		points.Insert(point{i, -i}, i)
+	}
+
+	for i := 0; i < 100; i++ {
+		if v, ok := strs.Search(strings.Repeat("x", i)); !ok || v != i {
+			t.Fatalf("strs.Search(%d x) = %d, %v", i, v, ok)
+		}
+		if v, ok := ints.Search(i); !ok || v != i {
+			t.Fatalf("ints.Search(%d) = %d, %v", i, v, ok)
+		}
+		if v, ok := points.Search(point{i, -i}); !ok || v != i {
+			t.Fatalf("points.Search(%d) = %d, %v", i, v, ok)
+		}
+	}
+}
+
+func TestNew_Seed(t *testing.T) {
+	seeds := make(map[uint64]bool)
+	for i := 0; i < 8; i++ {
+		seeds[New[string, int](8).hasher.(hashers.String).Seed] = true
+	}
+	if len(seeds) < 2 {
+		t.Fatal("every table was created with the same seed")
+	}
+
+	ht := New[string, int](8)
+	ht.Insert("a", 1)
+	if clone := ht.Clone(); clone.hasher != ht.hasher {
+		t.Fatal("Clone did not keep the Hasher its buckets were placed with")
+	}
+}
+
+func TestWithHasher(t *testing.T) {
+	calls := 0
+	h := HasherFunc[string](func(key string) uint64 {
+		calls++
+		return uint64(len(key))
+	})
+	ht := New(4, WithHasher[string, int](h))
+
+	ht.Insert("a", 1)
+	ht.Insert("bb", 2)
+	if v, ok := ht.Search("bb"); !ok || v != 2 {
+		t.Fatalf("Search(\"bb\") = %d, %v, want 2, true", v, ok)
+	}
+	if calls != 3 {
+		t.Fatalf("Hasher called %d times, want 3", calls)
+	}
+}
+
+func TestNewFunc(t *testing.T) {
+	type route struct {
+		Method string
+		Path   []string
+	}
+	hash := func(r route) uint64 {
+		return hashers.FNV1a(r.Method+" "+strings.Join(r.Path, "/"), 0)
+	}
+	equal := func(a, b route) bool {
+		return a.Method == b.Method && slices.Equal(a.Path, b.Path)
+	}
+	ht := NewFunc[route, string](8, hash, equal)
+
+	ht.Insert(route{"GET", []string{"users"}}, "list")
+	ht.Insert(route{"GET", []string{"users", "id"}}, "show")
+	ht.Insert(route{"GET", []string{"users"}}, "index")
+
+	if ht.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", ht.Len())
+	}
+	if v, ok := ht.Search(route{"GET", []string{"users"}}); !ok || v != "index" {
+		t.Fatalf("Search(GET users) = %q, %v, want \"index\", true", v, ok)
+	}
+	if _, ok := ht.Delete(route{"GET", []string{"users", "id"}}); !ok {
+		t.Fatal("Delete(GET users/id) found nothing")
+	}
+	if clone := ht.Clone(); !clone.Contains(route{"GET", []string{"users"}}) {
+		t.Fatal("clone lost its key equality")
+	}
+}
@@ -0,0 +1,113 @@
+package hashers
+
+import (
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+)
+
+// Reflect hashes keys of any type by walking them with reflection. Structs are
+// hashed by their exported fields, so two keys that differ only in unexported
+// fields hash the same. Pointers, channels and unsafe pointers hash by address,
+// matching ==. Functions hash the same regardless of value. The zero Reflect is
+// usable but unseeded; create a seeded one with Auto.
+//
+// Reflect is much slower than a hand-written Hasher. It is meant for getting a
+// prototype running with struct keys, not for hot paths.
+type Reflect[K any] struct {
+	Seed uint64
+}
+
+// Auto returns a Reflect with a random seed.
+func Auto[K any]() Reflect[K] {
+	return Reflect[K]{Seed: rand.Uint64()}
+}
+
+// Hash returns the hash of every value reachable from key, other than through
+// pointers and unexported struct fields.
+func (h Reflect[K]) Hash(key K) uint64 {
+	return Mix64(hashValue(reflect.ValueOf(&key).Elem(), h.Seed))
+}
+
+// exportedFields caches the indexes of the exported fields of each struct type.
+var exportedFields sync.Map // reflect.Type -> []int
+
+func fieldsOf(t reflect.Type) []int {
+	if f, ok := exportedFields.Load(t); ok {
+		return f.([]int)
+	}
+	var fields []int
+	for i := range t.NumField() {
+		if t.Field(i).IsExported() {
+			fields = append(fields, i)
+		}
+	}
+	exportedFields.Store(t, fields)
+	return fields
+}
+
+// hashValue folds the hash of v into the running hash h.
+func hashValue(v reflect.Value, h uint64) uint64 {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return Combine(h, 1)
+		}
+		return Combine(h, 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Combine(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Combine(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return Combine(h, floatBits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return Combine(Combine(h, floatBits(real(c))), floatBits(imag(c)))
+	case reflect.String:
+		return Combine(h, Wyhash(v.String(), h))
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return Combine(h, uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return Combine(h, 0)
+		}
+		e := v.Elem()
+		return hashValue(e, Combine(h, Wyhash(e.Type().String(), 0)))
+	case reflect.Array:
+		for i := range v.Len() {
+			h = hashValue(v.Index(i), h)
+		}
+		return h
+	case reflect.Slice:
+		h = Combine(h, uint64(v.Len()))
+		for i := range v.Len() {
+			h = hashValue(v.Index(i), h)
+		}
+		return h
+	case reflect.Map:
+		// entries are combined with a sum so that iteration order does not matter
+		var sum uint64
+		iter := v.MapRange()
+		for iter.Next() {
+			sum += hashValue(iter.Value(), hashValue(iter.Key(), 0))
+		}
+		return Combine(Combine(h, uint64(v.Len())), sum)
+	case reflect.Struct:
+		for _, i := range fieldsOf(v.Type()) {
+			h = hashValue(v.Field(i), h)
+		}
+		return h
+	default:
+		// functions can only be compared with nil
+		return h
+	}
+}
+
+// floatBits returns the bits of f, with -0 and +0, which are ==, giving the same bits.
+func floatBits(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	return math.Float64bits(f)
+}
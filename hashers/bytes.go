@@ -0,0 +1,38 @@
+package hashers
+
+import "unsafe"
+
+// Bytes hashes []byte keys with wyhash. It hashes the bytes in place, without
+// converting the key to a string.
+type Bytes struct {
+	Seed uint64
+}
+
+// Hash returns the seeded wyhash of key.
+func (h Bytes) Hash(key []byte) uint64 {
+	return Wyhash(bytesToString(key), h.Seed)
+}
+
+// SipHashBytes hashes []byte keys with SipHash-2-4, for keys from untrusted sources.
+// See SipHashString.
+type SipHashBytes struct {
+	K0, K1 uint64
+}
+
+// NewSipHashBytes returns a SipHashBytes keyed with 128 bits from crypto/rand.
+func NewSipHashBytes() SipHashBytes {
+	h := NewSipHashString()
+	return SipHashBytes{K0: h.K0, K1: h.K1}
+}
+
+// Hash returns the SipHash-2-4 of key.
+func (h SipHashBytes) Hash(key []byte) uint64 {
+	return SipHash24(bytesToString(key), h.K0, h.K1)
+}
+
+// bytesToString returns a string sharing b's memory. The string hash functions only
+// read their input during the call, so b is never observed through the string after
+// it could change.
+func bytesToString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
@@ -0,0 +1,96 @@
+// Package hashers provides ready-made Hashers for use with hashtable.WithHasher.
+//
+// Every Hasher in this package is seeded: two Hashers with different seeds send the
+// same keys to unrelated buckets, so a set of colliding keys cannot be precomputed.
+// hashtable.New seeds its default Hasher randomly for each table. The seeding makes
+// deliberate collisions impractical for casual attackers, but these Hashers are not
+// cryptographic; use SipHashString for keys supplied by untrusted clients.
+//
+// Four Hashers are provided for string keys. String (FNV-1a) is the default and
+// is simple and adequate for short keys. XXH3String and WyhashString read eight
+// bytes at a time and are much faster for longer keys; WyhashString is the fastest
+// at every key length. SipHashString is a keyed pseudorandom function that resists
+// hash flooding even by attackers who can observe the table, at some cost in
+// speed. BenchmarkStringHashers compares them.
+//
+// Reflect, created with Auto, hashes keys of any type, including structs with
+// slice or map fields, so that prototypes can use such keys with hashtable.NewFunc
+// before a hand-written Hasher is worth the effort.
+package hashers
+
+import "hash/maphash"
+
+// Integer is a constraint matching the built-in integer types.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// FNV1a returns the 64-bit FNV-1a hash of s, starting from a state perturbed by seed.
+func FNV1a(s string, seed uint64) uint64 {
+	h := fnvOffset64 ^ seed
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	// FNV leaves the high bits poorly mixed for short keys
+	return Mix64(h)
+}
+
+// Mix64 scrambles the bits of x with the SplitMix64 finalizer, so that keys which
+// differ in a few low bits, such as sequential integers, spread across every bucket.
+func Mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Combine mixes the hashes of two fields into one hash for the pair. The result
+// depends on the order of its arguments, so (a, b) and (b, a) hash differently.
+func Combine(h1, h2 uint64) uint64 {
+	return Mix64(h1 ^ (h2 + 0x9e3779b97f4a7c15 + h1<<6 + h1>>2))
+}
+
+// String hashes string keys with FNV-1a.
+type String struct {
+	Seed uint64
+}
+
+// Hash returns the seeded FNV-1a hash of key.
+func (h String) Hash(key string) uint64 {
+	return FNV1a(key, h.Seed)
+}
+
+// Int hashes integer keys by mixing their bits with the seed.
+type Int[T Integer] struct {
+	Seed uint64
+}
+
+// Hash returns the mixed bits of key.
+func (h Int[T]) Hash(key T) uint64 {
+	return Mix64(uint64(key) ^ h.Seed)
+}
+
+// Comparable hashes any comparable key type with hash/maphash. The zero Comparable
+// is not usable; create one with NewComparable.
+type Comparable[K comparable] struct {
+	seed maphash.Seed
+}
+
+// NewComparable returns a Comparable with a random seed.
+func NewComparable[K comparable]() Comparable[K] {
+	return Comparable[K]{seed: maphash.MakeSeed()}
+}
+
+// Hash returns the maphash of key.
+func (h Comparable[K]) Hash(key K) uint64 {
+	return maphash.Comparable(h.seed, key)
+}
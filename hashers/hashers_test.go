@@ -0,0 +1,87 @@
+package hashers
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	const numberOfBuckets, keys = 16, 16000
+	hashes := map[string]func(i int) uint64{
+		"String":     func(i int) uint64 { return String{Seed: 42}.Hash("key-" + strconv.Itoa(i)) },
+		"Int":        func(i int) uint64 { return Int[int]{Seed: 42}.Hash(i) },
+		"Comparable": NewComparable[int]().Hash,
+	}
+	for name, hash := range hashes {
+		counts := make([]int, numberOfBuckets)
+		for i := 0; i < keys; i++ {
+			counts[hash(i)%numberOfBuckets]++
+		}
+		for b, c := range counts {
+			if c < keys/numberOfBuckets/2 || c > keys/numberOfBuckets*2 {
+				t.Errorf("%s: bucket %d holds %d of %d keys", name, b, c, keys)
+			}
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	// keys sharing a bucket under one seed should mostly be split up under another
+	const numberOfBuckets = 64
+	var colliding []string
+	for i := 0; len(colliding) < 32; i++ {
+		key := "key-" + strconv.Itoa(i)
+		if (String{Seed: 1}).Hash(key)%numberOfBuckets == 0 {
+			colliding = append(colliding, key)
+		}
+	}
+
+	stillColliding := 0
+	for _, key := range colliding {
+		if (String{Seed: 2}).Hash(key)%numberOfBuckets == 0 {
+			stillColliding++
+		}
+	}
+	if stillColliding > len(colliding)/2 {
+		t.Fatalf("%d of %d keys still share a bucket after reseeding", stillColliding, len(colliding))
+	}
+}
+
+func TestReflect(t *testing.T) {
+	type point struct {
+		X, Y  float64
+		Tags  []string
+		Attrs map[string]int
+		cache int
+	}
+	h := Auto[point]()
+	a := point{X: 1, Y: 2, Tags: []string{"a", "b"}, Attrs: map[string]int{"x": 1, "y": 2}, cache: 1}
+	b := point{X: 1, Y: 2, Tags: []string{"a", "b"}, Attrs: map[string]int{"y": 2, "x": 1}, cache: 2}
+	if h.Hash(a) != h.Hash(b) {
+		t.Error("points differing only in unexported fields hash differently")
+	}
+	for _, c := range []point{
+		{X: 2, Y: 1, Tags: a.Tags, Attrs: a.Attrs},
+		{X: 1, Y: 2, Tags: []string{"ab"}, Attrs: a.Attrs},
+		{X: 1, Y: 2, Tags: []string{"a", "b"}, Attrs: map[string]int{"x": 2, "y": 1}},
+	} {
+		if h.Hash(a) == h.Hash(c) {
+			t.Errorf("%+v and %+v hash the same", a, c)
+		}
+	}
+	if h.Hash(point{X: math.Copysign(0, -1)}) != h.Hash(point{}) {
+		t.Error("-0 and +0 hash differently")
+	}
+
+	var counts [16]int
+	ints := Auto[[2]int]()
+	for i := 0; i < 16000; i++ {
+		counts[ints.Hash([2]int{i / 100, i % 100})%16]++
+	}
+	for b, c := range counts {
+		if c < 500 || c > 2000 {
+			t.Errorf("bucket %d holds %d of 16000 keys", b, c)
+		}
+	}
+}
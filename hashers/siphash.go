@@ -0,0 +1,88 @@
+package hashers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/bits"
+)
+
+// SipHashString hashes string keys with SipHash-2-4 keyed by K0 and K1.
+//
+// SipHash is a keyed pseudorandom function designed for hash tables fed with
+// untrusted input: without the key, an attacker cannot find keys that collide
+// any faster than by brute force, so hash-flooding attacks are impractical even
+// against an attacker who can observe the table's behaviour. It is slower than
+// the other string Hashers, so prefer it only where keys come from untrusted
+// sources. The key must be kept secret; NewSipHashString chooses one at random.
+type SipHashString struct {
+	K0, K1 uint64
+}
+
+// NewSipHashString returns a SipHashString keyed with 128 bits from crypto/rand.
+func NewSipHashString() SipHashString {
+	var key [16]byte
+	rand.Read(key[:])
+	return SipHashString{
+		K0: binary.LittleEndian.Uint64(key[0:]),
+		K1: binary.LittleEndian.Uint64(key[8:]),
+	}
+}
+
+// Hash returns the SipHash-2-4 of key.
+func (h SipHashString) Hash(key string) uint64 {
+	return SipHash24(key, h.K0, h.K1)
+}
+
+// SipHash24 returns the SipHash-2-4 of s under the 128-bit key (k0, k1), where k0
+// holds the first eight key bytes in little-endian order.
+func SipHash24(s string, k0, k1 uint64) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	n := len(s)
+	p := s
+	for len(p) >= 8 {
+		m := str64(p, 0)
+		v3 ^= m
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0 ^= m
+		p = p[8:]
+	}
+
+	// the final block holds the remaining bytes and the low byte of the length
+	m := uint64(n) << 56
+	for i := len(p) - 1; i >= 0; i-- {
+		m |= uint64(p[i]) << (8 * i)
+	}
+	v3 ^= m
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0 ^= m
+
+	v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13)
+	v1 ^= v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17)
+	v1 ^= v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
@@ -0,0 +1,183 @@
+package hashers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testInput returns n bytes of deterministic, non-repeating test data.
+func testInput(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte((i*7 + 13) % 251)
+	}
+	return string(b)
+}
+
+func TestXXH3(t *testing.T) {
+	// reference values from XXH3_64bits_withSeed, covering every length class
+	tests := []struct {
+		n    int
+		seed uint64
+		want uint64
+	}{
+		{0, 0x0, 0x2d06800538d394c2},
+		{1, 0x0, 0x8a21d78b1538b1c0},
+		{2, 0x0, 0x51a687ed1e9ceea7},
+		{3, 0x0, 0xb7e23e9c1ad24e4b},
+		{4, 0x0, 0x3fc0c554cc1bfd24},
+		{7, 0x0, 0xc3f8d792dd3799d8},
+		{8, 0x0, 0x8e9d87b2621686c2},
+		{9, 0x0, 0xd78b2e5e6eee1a29},
+		{15, 0x0, 0x707855fafaad9bd1},
+		{16, 0x0, 0x2255ac040382fb28},
+		{17, 0x0, 0x80297144ea363493},
+		{31, 0x0, 0x98b746a97b9dd07a},
+		{32, 0x0, 0x16fee2662e59e552},
+		{33, 0x0, 0xb3448912bbd87087},
+		{64, 0x0, 0xa30892f885012cd6},
+		{65, 0x0, 0x88a8f2d8ab2b51fe},
+		{96, 0x0, 0xc09593b1d1e39150},
+		{97, 0x0, 0xcc8fef7a15cc64a2},
+		{128, 0x0, 0x4cfcc6eff3270579},
+		{129, 0x0, 0xf931d64d80256742},
+		{200, 0x0, 0x91e24791fb1625c2},
+		{240, 0x0, 0x9fe62f1f36ec688b},
+		{241, 0x0, 0xc14583623d7bbd8a},
+		{255, 0x0, 0xcc5c71083b4e9763},
+		{256, 0x0, 0xb744a230d1b3d10b},
+		{500, 0x0, 0x3b7fb002413359fb},
+		{1023, 0x0, 0xdb4d93423f2741ad},
+		{1024, 0x0, 0x402ba5a8445d05db},
+		{1025, 0x0, 0xdc43903f189399b4},
+		{2000, 0x0, 0x7ae5226b725fdc87},
+		{5000, 0x0, 0x9518e47bea00d69b},
+		{0, 0x9e3779b97f4a7c15, 0x602b0e2cd6662c8b},
+		{1, 0x9e3779b97f4a7c15, 0x30e3e5af4b171b5b},
+		{2, 0x9e3779b97f4a7c15, 0x6f51161e1a76f12c},
+		{3, 0x9e3779b97f4a7c15, 0x3fec8b5afc0b6938},
+		{4, 0x9e3779b97f4a7c15, 0x18138666502e18da},
+		{7, 0x9e3779b97f4a7c15, 0x807663f0a7143402},
+		{8, 0x9e3779b97f4a7c15, 0x5bd9aef540099c40},
+		{9, 0x9e3779b97f4a7c15, 0xe0941413093e1110},
+		{15, 0x9e3779b97f4a7c15, 0x1e8a944602f1f486},
+		{16, 0x9e3779b97f4a7c15, 0xae3166cef3da7aa2},
+		{17, 0x9e3779b97f4a7c15, 0xeb676bac156e641a},
+		{31, 0x9e3779b97f4a7c15, 0x23084881ce7c5fba},
+		{32, 0x9e3779b97f4a7c15, 0xf64a201c4210479e},
+		{33, 0x9e3779b97f4a7c15, 0xad8fa4d48d1f249},
+		{64, 0x9e3779b97f4a7c15, 0x3554494a03fd7e51},
+		{65, 0x9e3779b97f4a7c15, 0x6eddbff1c08b36d4},
+		{96, 0x9e3779b97f4a7c15, 0xaa677fbacefd317d},
+		{97, 0x9e3779b97f4a7c15, 0xede1eac34338d383},
+		{128, 0x9e3779b97f4a7c15, 0x7deac0f98533fc69},
+		{129, 0x9e3779b97f4a7c15, 0xca2925f4a531b01c},
+		{200, 0x9e3779b97f4a7c15, 0x4e98bf1cb508e5bb},
+		{240, 0x9e3779b97f4a7c15, 0x7711425468a9b3e4},
+		{241, 0x9e3779b97f4a7c15, 0xcdfe1baef9f6786},
+		{255, 0x9e3779b97f4a7c15, 0x47ed302920ea25d7},
+		{256, 0x9e3779b97f4a7c15, 0xac31dc4acbe43f92},
+		{500, 0x9e3779b97f4a7c15, 0xbc001410d35ffce},
+		{1023, 0x9e3779b97f4a7c15, 0x16f6cdd9e59f9ce2},
+		{1024, 0x9e3779b97f4a7c15, 0x46f7d467eaff834e},
+		{1025, 0x9e3779b97f4a7c15, 0x6c958d342bd9d09e},
+		{2000, 0x9e3779b97f4a7c15, 0xb0810fd5f16f1002},
+		{5000, 0x9e3779b97f4a7c15, 0x5a924029777963cd},
+	}
+	for _, tt := range tests {
+		if got := XXH3(testInput(tt.n), tt.seed); got != tt.want {
+			t.Errorf("XXH3(%d bytes, %#x) = %#x, want %#x", tt.n, tt.seed, got, tt.want)
+		}
+	}
+}
+
+func TestWyhash(t *testing.T) {
+	// reference test vectors of wyhash final version 4, where the seed is the index
+	tests := []struct {
+		s    string
+		want uint64
+	}{
+		{"", 0x93228a4de0eec5a2},
+		{"a", 0xc5bac3db178713c4},
+		{"abc", 0xa97f2f7b1d9b3314},
+		{"message digest", 0x786d1f1df3801df4},
+		{"abcdefghijklmnopqrstuvwxyz", 0xdca5a8138ad37c87},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", 0xb9e734f117cfaf70},
+		{strings.Repeat("1234567890", 8), 0x6cc5eab49a92d617},
+	}
+	for i, tt := range tests {
+		if got := Wyhash(tt.s, uint64(i)); got != tt.want {
+			t.Errorf("Wyhash(%q, %d) = %#x, want %#x", tt.s, i, got, tt.want)
+		}
+	}
+}
+
+func TestSipHash24(t *testing.T) {
+	// reference vectors: key bytes 0..15 and a message of bytes 0..n-1
+	tests := []struct {
+		n    int
+		want uint64
+	}{
+		{0, 0x726fdb47dd0e0e31},
+		{1, 0x74f839c593dc67fd},
+		{7, 0xab0200f58b01d137},
+		{8, 0x93f5f5799a932462},
+		{9, 0x9e0082df0ba9e4b0},
+		{15, 0xa129ca6149be45e5},
+		{16, 0x3f2acc7f57c29bdb},
+		{17, 0x699ae9f52cbe4794},
+		{63, 0x958a324ceb064572},
+		{64, 0xacd2c40b8502cad8},
+		{100, 0x96f3fec85c52a7e},
+	}
+	const k0, k1 = 0x0706050403020100, 0x0f0e0d0c0b0a0908
+	for _, tt := range tests {
+		msg := make([]byte, tt.n)
+		for i := range msg {
+			msg[i] = byte(i)
+		}
+		if got := SipHash24(string(msg), k0, k1); got != tt.want {
+			t.Errorf("SipHash24(%d bytes) = %#x, want %#x", tt.n, got, tt.want)
+		}
+	}
+
+	if a, b := NewSipHashString(), NewSipHashString(); a == b {
+		t.Error("NewSipHashString returned the same key twice")
+	}
+}
+
+func BenchmarkStringHashers(b *testing.B) {
+	hashers := []struct {
+		name string
+		hash func(string) uint64
+	}{
+		{"FNV1a", String{}.Hash},
+		{"XXH3", XXH3String{}.Hash},
+		{"Wyhash", WyhashString{}.Hash},
+		{"SipHash", SipHashString{}.Hash},
+	}
+	for _, size := range []int{8, 64, 1024} {
+		key := testInput(size)
+		for _, h := range hashers {
+			b.Run(h.name+"/"+strconv.Itoa(size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for b.Loop() {
+					h.hash(key)
+				}
+			})
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	for _, n := range []int{0, 3, 16, 100} {
+		s := testInput(n)
+		if got, want := (Bytes{Seed: 7}).Hash([]byte(s)), Wyhash(s, 7); got != want {
+			t.Errorf("Bytes.Hash(%d bytes) = %#x, want %#x", n, got, want)
+		}
+		if got, want := (SipHashBytes{K0: 1, K1: 2}).Hash([]byte(s)), SipHash24(s, 1, 2); got != want {
+			t.Errorf("SipHashBytes.Hash(%d bytes) = %#x, want %#x", n, got, want)
+		}
+	}
+}
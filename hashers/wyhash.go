@@ -0,0 +1,68 @@
+package hashers
+
+import "math/bits"
+
+// WyhashString hashes string keys with wyhash (final version 4). Its output
+// matches the reference wyhash with the default secret.
+//
+// wyhash consumes 16 or 48 bytes per round with 64-bit multiplies and has very
+// little setup, so it is the fastest string Hasher in this package: several
+// times faster than FNV-1a for keys of 64 bytes and an order of magnitude faster
+// for keys of a kilobyte.
+type WyhashString struct {
+	Seed uint64
+}
+
+// Hash returns the seeded wyhash of key.
+func (h WyhashString) Hash(key string) uint64 {
+	return Wyhash(key, h.Seed)
+}
+
+// wyp is the default secret of the reference implementation.
+var wyp = [4]uint64{0x2d358dccaa6c78a5, 0x8bb84b93962eacc9, 0x4b33a62ed433d4a3, 0x4d5a2da51de1aa47}
+
+// Wyhash returns the wyhash of s with the given seed.
+func Wyhash(s string, seed uint64) uint64 {
+	n := len(s)
+	seed ^= wymix(seed^wyp[0], wyp[1])
+
+	var a, b uint64
+	switch {
+	case n <= 16:
+		if n >= 4 {
+			a = uint64(str32(s, 0))<<32 | uint64(str32(s, (n>>3)<<2))
+			b = uint64(str32(s, n-4))<<32 | uint64(str32(s, n-4-(n>>3)<<2))
+		} else if n > 0 {
+			a = uint64(s[0])<<16 | uint64(s[n>>1])<<8 | uint64(s[n-1])
+		}
+	default:
+		p := s
+		if len(p) >= 48 {
+			see1, see2 := seed, seed
+			for len(p) >= 48 {
+				seed = wymix(str64(p, 0)^wyp[1], str64(p, 8)^seed)
+				see1 = wymix(str64(p, 16)^wyp[2], str64(p, 24)^see1)
+				see2 = wymix(str64(p, 32)^wyp[3], str64(p, 40)^see2)
+				p = p[48:]
+			}
+			seed ^= see1 ^ see2
+		}
+		for len(p) > 16 {
+			seed = wymix(str64(p, 0)^wyp[1], str64(p, 8)^seed)
+			p = p[16:]
+		}
+		// the last 16 bytes of s, which may overlap bytes already consumed
+		a = str64(s, n-16)
+		b = str64(s, n-8)
+	}
+
+	a ^= wyp[1]
+	b ^= seed
+	b, a = bits.Mul64(a, b)
+	return wymix(a^wyp[0]^uint64(n), b^wyp[1])
+}
+
+// wymix returns the xor of the high and low halves of the 128-bit product a*b.
+func wymix(a, b uint64) uint64 {
+	return mulFold64(a, b)
+}
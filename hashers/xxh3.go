@@ -0,0 +1,249 @@
+package hashers
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// XXH3String hashes string keys with the 64-bit variant of XXH3. Its output
+// matches the reference XXH3_64bits_withSeed, so hashes can be shared with
+// other implementations.
+//
+// This pure Go port has no SIMD, so it runs at roughly twice the speed of FNV-1a
+// for keys of 64 bytes and more, and about the same for very short keys.
+type XXH3String struct {
+	Seed uint64
+}
+
+// Hash returns the seeded XXH3 hash of key.
+func (h XXH3String) Hash(key string) uint64 {
+	return XXH3(key, h.Seed)
+}
+
+const (
+	xxhPrime32_1 = 0x9E3779B1
+	xxhPrime32_2 = 0x85EBCA77
+	xxhPrime32_3 = 0xC2B2AE3D
+
+	xxhPrime64_1 = 0x9E3779B185EBCA87
+	xxhPrime64_2 = 0xC2B2AE3D27D4EB4F
+	xxhPrime64_3 = 0x165667B19E3779F9
+	xxhPrime64_4 = 0x85EBCA77C2B2AE63
+	xxhPrime64_5 = 0x27D4EB2F165667C5
+
+	xxh3SecretSize      = 192
+	xxh3StripeLen       = 64
+	xxh3ConsumeRate     = 8
+	xxh3MidSizeMax      = 240
+	xxh3MergeAccsStart  = 11
+	xxh3LastAccStart    = 7
+	xxh3MidSecretOffset = 3
+	xxh3MidLastOffset   = 136 - 17
+)
+
+// xxh3Secret is the default secret of the reference implementation.
+var xxh3Secret = [xxh3SecretSize]byte{
+	0xb8, 0xfe, 0x6c, 0x39, 0x23, 0xa4, 0x4b, 0xbe, 0x7c, 0x01, 0x81, 0x2c, 0xf7, 0x21, 0xad, 0x1c,
+	0xde, 0xd4, 0x6d, 0xe9, 0x83, 0x90, 0x97, 0xdb, 0x72, 0x40, 0xa4, 0xa4, 0xb7, 0xb3, 0x67, 0x1f,
+	0xcb, 0x79, 0xe6, 0x4e, 0xcc, 0xc0, 0xe5, 0x78, 0x82, 0x5a, 0xd0, 0x7d, 0xcc, 0xff, 0x72, 0x21,
+	0xb8, 0x08, 0x46, 0x74, 0xf7, 0x43, 0x24, 0x8e, 0xe0, 0x35, 0x90, 0xe6, 0x81, 0x3a, 0x26, 0x4c,
+	0x3c, 0x28, 0x52, 0xbb, 0x91, 0xc3, 0x00, 0xcb, 0x88, 0xd0, 0x65, 0x8b, 0x1b, 0x53, 0x2e, 0xa3,
+	0x71, 0x64, 0x48, 0x97, 0xa2, 0x0d, 0xf9, 0x4e, 0x38, 0x19, 0xef, 0x46, 0xa9, 0xde, 0xac, 0xd8,
+	0xa8, 0xfa, 0x76, 0x3f, 0xe3, 0x9c, 0x34, 0x3f, 0xf9, 0xdc, 0xbb, 0xc7, 0xc7, 0x0b, 0x4f, 0x1d,
+	0x8a, 0x51, 0xe0, 0x4b, 0xcd, 0xb4, 0x59, 0x31, 0xc8, 0x9f, 0x7e, 0xc9, 0xd9, 0x78, 0x73, 0x64,
+	0xea, 0xc5, 0xac, 0x83, 0x34, 0xd3, 0xeb, 0xc3, 0xc5, 0x81, 0xa0, 0xff, 0xfa, 0x13, 0x63, 0xeb,
+	0x17, 0x0d, 0xdd, 0x51, 0xb7, 0xf0, 0xda, 0x49, 0xd3, 0x16, 0x55, 0x26, 0x29, 0xd4, 0x68, 0x9e,
+	0x2b, 0x16, 0xbe, 0x58, 0x7d, 0x47, 0xa1, 0xfc, 0x8f, 0xf8, 0xb8, 0xd1, 0x7a, 0xd0, 0x31, 0xce,
+	0x45, 0xcb, 0x3a, 0x8f, 0x95, 0x16, 0x04, 0x28, 0xaf, 0xd7, 0xfb, 0xca, 0xbb, 0x4b, 0x40, 0x7e,
+}
+
+// XXH3 returns the 64-bit XXH3 hash of s with the given seed.
+func XXH3(s string, seed uint64) uint64 {
+	switch n := len(s); {
+	case n <= 16:
+		return xxh3Len0To16(s, seed)
+	case n <= 128:
+		return xxh3Len17To128(s, seed)
+	case n <= xxh3MidSizeMax:
+		return xxh3Len129To240(s, seed)
+	}
+
+	secret := &xxh3Secret
+	if seed != 0 {
+		secret = xxh3DeriveSecret(seed)
+	}
+	return xxh3Long(s, secret)
+}
+
+func xxh3Len0To16(s string, seed uint64) uint64 {
+	secret := xxh3Secret[:]
+	n := len(s)
+	switch {
+	case n > 8:
+		flip1 := (le64(secret, 24) ^ le64(secret, 32)) + seed
+		flip2 := (le64(secret, 40) ^ le64(secret, 48)) - seed
+		lo := str64(s, 0) ^ flip1
+		hi := str64(s, n-8) ^ flip2
+		acc := uint64(n) + bits.ReverseBytes64(lo) + hi + mulFold64(lo, hi)
+		return xxh3Avalanche(acc)
+	case n >= 4:
+		seed ^= uint64(bits.ReverseBytes32(uint32(seed))) << 32
+		in1 := str32(s, 0)
+		in2 := str32(s, n-4)
+		flip := (le64(secret, 8) ^ le64(secret, 16)) - seed
+		keyed := (uint64(in2) + uint64(in1)<<32) ^ flip
+		return xxh3StrongAvalanche(keyed, uint64(n))
+	case n > 0:
+		combo := uint32(s[0])<<16 | uint32(s[n>>1])<<24 | uint32(s[n-1]) | uint32(n)<<8
+		flip := uint64(binary.LittleEndian.Uint32(secret[0:])^binary.LittleEndian.Uint32(secret[4:])) + seed
+		return xxh64Avalanche(uint64(combo) ^ flip)
+	}
+	return xxh64Avalanche(seed ^ le64(secret, 56) ^ le64(secret, 64))
+}
+
+func xxh3Len17To128(s string, seed uint64) uint64 {
+	n := len(s)
+	acc := uint64(n) * xxhPrime64_1
+	if n > 32 {
+		if n > 64 {
+			if n > 96 {
+				acc += xxh3Mix16(s, 48, 96, seed)
+				acc += xxh3Mix16(s, n-64, 112, seed)
+			}
+			acc += xxh3Mix16(s, 32, 64, seed)
+			acc += xxh3Mix16(s, n-48, 80, seed)
+		}
+		acc += xxh3Mix16(s, 16, 32, seed)
+		acc += xxh3Mix16(s, n-32, 48, seed)
+	}
+	acc += xxh3Mix16(s, 0, 0, seed)
+	acc += xxh3Mix16(s, n-16, 16, seed)
+	return xxh3Avalanche(acc)
+}
+
+func xxh3Len129To240(s string, seed uint64) uint64 {
+	n := len(s)
+	acc := uint64(n) * xxhPrime64_1
+	rounds := n / 16
+	for i := 0; i < 8; i++ {
+		acc += xxh3Mix16(s, 16*i, 16*i, seed)
+	}
+	acc = xxh3Avalanche(acc)
+	for i := 8; i < rounds; i++ {
+		acc += xxh3Mix16(s, 16*i, 16*(i-8)+xxh3MidSecretOffset, seed)
+	}
+	acc += xxh3Mix16(s, n-16, xxh3MidLastOffset, seed)
+	return xxh3Avalanche(acc)
+}
+
+func xxh3Long(s string, secret *[xxh3SecretSize]byte) uint64 {
+	acc := [8]uint64{
+		xxhPrime32_3, xxhPrime64_1, xxhPrime64_2, xxhPrime64_3,
+		xxhPrime64_4, xxhPrime32_2, xxhPrime64_5, xxhPrime32_1,
+	}
+
+	n := len(s)
+	stripesPerBlock := (xxh3SecretSize - xxh3StripeLen) / xxh3ConsumeRate
+	blockLen := xxh3StripeLen * stripesPerBlock
+	blocks := (n - 1) / blockLen
+	for b := 0; b < blocks; b++ {
+		for i := 0; i < stripesPerBlock; i++ {
+			xxh3Accumulate512(&acc, s, b*blockLen+i*xxh3StripeLen, secret, i*xxh3ConsumeRate)
+		}
+		xxh3Scramble(&acc, secret)
+	}
+
+	// the last partial block, then the final stripe which may overlap it
+	stripes := ((n - 1) - blockLen*blocks) / xxh3StripeLen
+	for i := 0; i < stripes; i++ {
+		xxh3Accumulate512(&acc, s, blocks*blockLen+i*xxh3StripeLen, secret, i*xxh3ConsumeRate)
+	}
+	xxh3Accumulate512(&acc, s, n-xxh3StripeLen, secret, xxh3SecretSize-xxh3StripeLen-xxh3LastAccStart)
+
+	result := uint64(n) * xxhPrime64_1
+	for i := 0; i < 4; i++ {
+		off := xxh3MergeAccsStart + 16*i
+		result += mulFold64(acc[2*i]^le64(secret[:], off), acc[2*i+1]^le64(secret[:], off+8))
+	}
+	return xxh3Avalanche(result)
+}
+
+func xxh3Accumulate512(acc *[8]uint64, s string, offset int, secret *[xxh3SecretSize]byte, secretOffset int) {
+	for i := 0; i < 8; i++ {
+		value := str64(s, offset+8*i)
+		key := value ^ le64(secret[:], secretOffset+8*i)
+		acc[i^1] += value
+		acc[i] += uint64(uint32(key)) * (key >> 32)
+	}
+}
+
+func xxh3Scramble(acc *[8]uint64, secret *[xxh3SecretSize]byte) {
+	for i := 0; i < 8; i++ {
+		a := acc[i]
+		a ^= a >> 47
+		a ^= le64(secret[:], xxh3SecretSize-xxh3StripeLen+8*i)
+		acc[i] = a * xxhPrime32_1
+	}
+}
+
+// xxh3DeriveSecret mixes seed into the default secret, as the reference
+// implementation does for seeded hashes of long inputs.
+func xxh3DeriveSecret(seed uint64) *[xxh3SecretSize]byte {
+	var secret [xxh3SecretSize]byte
+	for i := 0; i < xxh3SecretSize; i += 16 {
+		binary.LittleEndian.PutUint64(secret[i:], le64(xxh3Secret[:], i)+seed)
+		binary.LittleEndian.PutUint64(secret[i+8:], le64(xxh3Secret[:], i+8)-seed)
+	}
+	return &secret
+}
+
+func xxh3Mix16(s string, offset, secretOffset int, seed uint64) uint64 {
+	lo := str64(s, offset) ^ (le64(xxh3Secret[:], secretOffset) + seed)
+	hi := str64(s, offset+8) ^ (le64(xxh3Secret[:], secretOffset+8) - seed)
+	return mulFold64(lo, hi)
+}
+
+func xxh3Avalanche(x uint64) uint64 {
+	x ^= x >> 37
+	x *= 0x165667919E3779F9
+	return x ^ x>>32
+}
+
+func xxh3StrongAvalanche(x, n uint64) uint64 {
+	x ^= bits.RotateLeft64(x, 49) ^ bits.RotateLeft64(x, 24)
+	x *= 0x9FB21C651E98DF25
+	x ^= (x >> 35) + n
+	x *= 0x9FB21C651E98DF25
+	return x ^ x>>28
+}
+
+func xxh64Avalanche(x uint64) uint64 {
+	x ^= x >> 33
+	x *= xxhPrime64_2
+	x ^= x >> 29
+	x *= xxhPrime64_3
+	return x ^ x>>32
+}
+
+// mulFold64 returns the xor of the high and low halves of the 128-bit product a*b.
+func mulFold64(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+func le64(b []byte, i int) uint64 {
+	return binary.LittleEndian.Uint64(b[i:])
+}
+
+// str64 reads a little-endian uint64 from s at i without converting s to a []byte.
+func str64(s string, i int) uint64 {
+	_ = s[i+7]
+	return uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+		uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+}
+
+// str32 reads a little-endian uint32 from s at i.
+func str32(s string, i int) uint32 {
+	_ = s[i+3]
+	return uint32(s[i]) | uint32(s[i+1])<<8 | uint32(s[i+2])<<16 | uint32(s[i+3])<<24
+}
@@ -0,0 +1,289 @@
+package hashtable
+
+import "math/bits"
+
+// neighborhood is the number of slots, starting at its home slot, that a hopscotch
+// table keeps each entry within.
+const neighborhood = 32
+
+// hopscotch is an open-addressing storage with hopscotch hashing: every entry lives
+// within the first 32 slots from its home slot, and each home slot keeps a bitmap
+// of which of those slots hold its entries. A lookup reads only the slots its
+// bitmap names, which usually share a cache line or two. An insert takes the
+// nearest free slot and, while that is outside the neighborhood, moves an entry
+// that can stay within its own neighborhood into it, hopping the free slot back
+// towards home. The table is kept at most seven eighths full.
+//
+// An insert that finds no entry to move grows the table, unless the table is less
+// than half full, which means many keys share few home slots. The entry then
+// spills into an overflow list that lookups for its home slot also scan.
+type hopscotch[K any, V any] struct {
+	equal   func(a, b K) bool
+	entries []kv[K, V]
+	used    []bool
+	// hops holds, for each home slot, a bitmap whose bit i is set when slot home+i
+	// holds an entry whose home is that slot.
+	hops     []uint32
+	overflow []kv[K, V]
+	// spilled counts, for each home slot, the entries of overflow it is home to.
+	spilled []uint32
+	size    int
+}
+
+func newHopscotch[K any, V any](l layout[K], n int) *hopscotch[K, V] {
+	t := &hopscotch[K, V]{equal: l.equal}
+	t.allocate(max(minSlots, powerOfTwo(n)))
+	return t
+}
+
+// allocate replaces the slots with n empty ones.
+func (t *hopscotch[K, V]) allocate(n int) {
+	t.entries = make([]kv[K, V], n)
+	t.used = make([]bool, n)
+	t.hops = make([]uint32, n)
+	t.spilled = make([]uint32, n)
+	t.overflow = nil
+}
+
+// home returns the home slot of an entry with hash h.
+func (t *hopscotch[K, V]) home(h uint64) int {
+	return int(h & uint64(len(t.entries)-1))
+}
+
+// locate returns the slot holding key, or -1, and the index of key in overflow, or
+// -1.
+func (t *hopscotch[K, V]) locate(key K, h uint64) (int, int) {
+	mask := len(t.entries) - 1
+	home := t.home(h)
+	for hop := t.hops[home]; hop != 0; hop &= hop - 1 {
+		i := (home + bits.TrailingZeros32(hop)) & mask
+		if t.entries[i].hash == h && t.equal(key, t.entries[i].Key) {
+			return i, -1
+		}
+	}
+	if t.spilled[home] > 0 {
+		for j := range t.overflow {
+			if t.overflow[j].hash == h && t.equal(key, t.overflow[j].Key) {
+				return -1, j
+			}
+		}
+	}
+	return -1, -1
+}
+
+func (t *hopscotch[K, V]) find(key K, h uint64) *kv[K, V] {
+	i, j := t.locate(key, h)
+	switch {
+	case i >= 0:
+		return &t.entries[i]
+	case j >= 0:
+		return &t.overflow[j]
+	}
+	return nil
+}
+
+func (t *hopscotch[K, V]) add(key K, h uint64, value V) int {
+	if 8*(t.size+1) > 7*len(t.entries) {
+		t.resize(2 * len(t.entries))
+	}
+	t.size++
+	data := kv[K, V]{Key: key, Value: value, hash: h}
+	for !t.place(data) {
+		if 2*t.size < len(t.entries) {
+			t.spill(data)
+			break
+		}
+		t.resize(2 * len(t.entries))
+	}
+	home := t.home(h)
+	return bits.OnesCount32(t.hops[home]) + int(t.spilled[home])
+}
+
+// place puts data within the neighborhood of its home slot, moving other entries
+// to make room, and reports whether it found a slot. The table must have a free
+// slot.
+func (t *hopscotch[K, V]) place(data kv[K, V]) bool {
+	mask := len(t.entries) - 1
+	home := t.home(data.hash)
+	free := home
+	for t.used[free] {
+		free = (free + 1) & mask
+	}
+	for (free-home)&mask >= neighborhood {
+		if free = t.hop(free); free < 0 {
+			return false
+		}
+	}
+	t.entries[free], t.used[free] = data, true
+	t.hops[home] |= 1 << ((free - home) & mask)
+	return true
+}
+
+// hop moves into the free slot the furthest entry before it that stays within its
+// own neighborhood there, and returns the slot that entry left, or -1 if no entry
+// can move.
+func (t *hopscotch[K, V]) hop(free int) int {
+	mask := len(t.entries) - 1
+	for d := neighborhood - 1; d > 0; d-- {
+		home := (free - d) & mask
+		hop := t.hops[home]
+		if hop == 0 {
+			continue
+		}
+		// the first entry of home is the furthest from free
+		if offset := bits.TrailingZeros32(hop); offset < d {
+			from := (home + offset) & mask
+			t.entries[free], t.used[free] = t.entries[from], true
+			t.entries[from], t.used[from] = kv[K, V]{}, false
+			t.hops[home] = hop&^(1<<offset) | 1<<d
+			return from
+		}
+	}
+	return -1
+}
+
+// spill adds data to the overflow list.
+func (t *hopscotch[K, V]) spill(data kv[K, V]) {
+	t.overflow = append(t.overflow, data)
+	t.spilled[t.home(data.hash)]++
+}
+
+func (t *hopscotch[K, V]) remove(key K, h uint64) (kv[K, V], bool) {
+	i, j := t.locate(key, h)
+	switch {
+	case i >= 0:
+		data := t.entries[i]
+		t.removeAt(i)
+		t.size--
+		return data, true
+	case j >= 0:
+		data := t.overflow[j]
+		t.unspill(j)
+		t.size--
+		return data, true
+	}
+	return kv[K, V]{}, false
+}
+
+// removeAt frees slot i.
+func (t *hopscotch[K, V]) removeAt(i int) {
+	home := t.home(t.entries[i].hash)
+	t.hops[home] &^= 1 << ((i - home) & (len(t.entries) - 1))
+	// zero the slot so the garbage collector can reclaim what it references
+	t.entries[i], t.used[i] = kv[K, V]{}, false
+}
+
+// unspill removes entry j of the overflow list, moving the last entry into its
+// place.
+func (t *hopscotch[K, V]) unspill(j int) {
+	t.spilled[t.home(t.overflow[j].hash)]--
+	last := len(t.overflow) - 1
+	t.overflow[j] = t.overflow[last]
+	t.overflow[last] = kv[K, V]{}
+	t.overflow = t.overflow[:last]
+}
+
+func (t *hopscotch[K, V]) len() int {
+	return t.size
+}
+
+func (t *hopscotch[K, V]) clear() {
+	clear(t.entries)
+	clear(t.used)
+	clear(t.hops)
+	clear(t.spilled)
+	clear(t.overflow)
+	t.overflow = t.overflow[:0]
+	t.size = 0
+}
+
+func (t *hopscotch[K, V]) all(yield func(*kv[K, V]) bool) {
+	for i := range t.entries {
+		if t.used[i] && !yield(&t.entries[i]) {
+			return
+		}
+	}
+	for j := range t.overflow {
+		if !yield(&t.overflow[j]) {
+			return
+		}
+	}
+}
+
+func (t *hopscotch[K, V]) deleteFunc(del func(*kv[K, V]) bool) int {
+	removed := 0
+	for i := range t.entries {
+		if t.used[i] && del(&t.entries[i]) {
+			t.removeAt(i)
+			removed++
+		}
+	}
+	for j := 0; j < len(t.overflow); {
+		if del(&t.overflow[j]) {
+			t.unspill(j)
+			removed++
+			continue
+		}
+		j++
+	}
+	t.size -= removed
+	return removed
+}
+
+// slots numbers the slots of the table, then the overflow list.
+func (t *hopscotch[K, V]) slots() (int, int) {
+	return len(t.entries) + len(t.overflow), 1
+}
+
+func (t *hopscotch[K, V]) at(p, d int) *kv[K, V] {
+	switch {
+	case d > 0:
+		return nil
+	case p < len(t.entries):
+		if t.used[p] {
+			return &t.entries[p]
+		}
+	case p-len(t.entries) < len(t.overflow):
+		return &t.overflow[p-len(t.entries)]
+	}
+	return nil
+}
+
+func (t *hopscotch[K, V]) reserve(n int) {
+	if slots := slotsFor(n, 7, 8); slots > len(t.entries) {
+		t.resize(slots)
+	}
+}
+
+// compact moves any spilled entries back into the table if there is now room for
+// them in their neighborhoods.
+func (t *hopscotch[K, V]) compact() {
+	if len(t.overflow) > 0 {
+		t.resize(len(t.entries))
+	}
+}
+
+// shrink lowers the number of slots to what the entries need at half the maximum
+// load.
+func (t *hopscotch[K, V]) shrink() {
+	if slots := slotsFor(2*t.size, 7, 8); slots < len(t.entries) {
+		t.resize(slots)
+	}
+}
+
+// resize moves every entry into a new array of n slots, spilling those that find no
+// room in their neighborhoods.
+func (t *hopscotch[K, V]) resize(n int) {
+	entries, used, overflow := t.entries, t.used, t.overflow
+	t.allocate(n)
+	for i := range entries {
+		if used[i] && !t.place(entries[i]) {
+			t.spill(entries[i])
+		}
+	}
+	for _, data := range overflow {
+		if !t.place(data) {
+			t.spill(data)
+		}
+	}
+}
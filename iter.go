@@ -0,0 +1,272 @@
+package hashtable
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+)
+
+// All returns an iterator over every key/value pair in bucket order, for use with
+// range-over-func:
+//
+//	for key, value := range ht.All() {
+//		...
+//	}
+//
+// The loop body must not insert into or delete from the HashTable.
+func (ht *HashTable[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		ht.store.all(func(data *kv[K, V]) bool {
+			return yield(data.Key, data.Value)
+		})
+	}
+}
+
+// KeysSeq returns an iterator over every key in bucket order. Unlike Keys, it does
+// not materialize the keys into a collection.
+func (ht *HashTable[K, V]) KeysSeq() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for key := range ht.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// ValuesSeq returns an iterator over every value in bucket order. Unlike Values,
+// it does not materialize the values into a collection.
+func (ht *HashTable[K, V]) ValuesSeq() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, value := range ht.All() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// SnapshotIter returns an iterator over a copy of the entries taken when SnapshotIter
+// is called. The iterator yields exactly the key/value pairs present at that moment:
+// later inserts, updates and deletes are not observed, including those made by the
+// loop body, so it is safe to modify the HashTable while iterating.
+//
+// Taking the snapshot reads the whole table, so callers sharing the HashTable
+// between goroutines must hold their lock for the SnapshotIter call only; the
+// returned iterator can then be ranged over without it. The iterator can be
+// ranged over more than once and always yields the same snapshot.
+func (ht *HashTable[K, V]) SnapshotIter() iter.Seq2[K, V] {
+	entries := ht.Entries()
+	return func(yield func(K, V) bool) {
+		for _, e := range entries {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// A Cursor records where Iterate resumes. The zero Cursor starts at the beginning of
+// the HashTable, and Iterate returns the zero Cursor once every bucket has been
+// visited. Cursors are plain integers, so they can be handed to API clients and
+// passed back on a later request.
+type Cursor uint64
+
+// Iterate returns the next page of entries starting at cursor, holding at least
+// limit entries unless the end of the table is reached, plus the Cursor to pass to
+// the next call. Pages always contain whole buckets, so a page may hold more than
+// limit entries. A limit below one is treated as one.
+//
+// As long as the table is not rehashed between calls, every entry stored for the
+// whole pagination is returned exactly once. Entries inserted or deleted while
+// paginating may or may not be returned. This holds for the chained storages and
+// for WithSmallTable, WithPointerFreeStorage, WithOpenAddressing and
+// WithSwissTable, which keep an entry in place until it is deleted or the table
+// is rebuilt. WithRobinHood, WithCuckoo and WithHopscotch move entries between
+// slots when other keys are inserted or deleted, so with them an entry may be
+// skipped or returned twice unless the table is not modified at all between calls.
+func (ht *HashTable[K, V]) Iterate(cursor Cursor, limit int) ([]Entry[K, V], Cursor) {
+	limit = max(limit, 1)
+	var page []Entry[K, V]
+	positions, _ := ht.store.slots()
+	bucket := int(cursor)
+	for ; bucket < positions && len(page) < limit; bucket++ {
+		for d := 0; ; d++ {
+			data := ht.store.at(bucket, d)
+			if data == nil {
+				break
+			}
+			page = append(page, Entry[K, V]{Key: data.Key, Value: data.Value})
+		}
+	}
+
+	if bucket >= positions {
+		// every bucket has been visited
+		return page, 0
+	}
+	return page, Cursor(bucket)
+}
+
+// Drain returns an iterator that removes each key/value pair from the HashTable as
+// it is yielded. Ranging to completion leaves the table empty; stopping early
+// leaves the entries that have not been yielded yet. The loop body must not
+// insert into or delete from the HashTable.
+func (ht *HashTable[K, V]) Drain() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		positions, _ := ht.store.slots()
+		for p := range positions {
+			// removal may move another entry into position p, so take the first entry
+			// at p until there is none
+			for data := ht.store.at(p, 0); data != nil; data = ht.store.at(p, 0) {
+				removed, _ := ht.store.remove(data.Key, data.hash)
+				if !yield(removed.Key, removed.Value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// DeterministicAll returns an iterator over every key/value pair in an order that
+// depends only on the keys, not on the hash function or number of buckets, which
+// makes it suitable for golden tests and reproducible output.
+//
+// Keys of the basic kinds (integers, floats, strings and booleans) are yielded in
+// ascending order. Other keys are ordered by their Go-syntax representation (%#v),
+// which for pointers is their address and therefore only stable within a run.
+// The entries are sorted up front, so the loop body may modify the HashTable.
+func (ht *HashTable[K, V]) DeterministicAll() iter.Seq2[K, V] {
+	return ht.SortedRangeFunc(compareDeterministic[K])
+}
+
+// sortedEntries returns every entry sorted by key using compare.
+func (ht *HashTable[K, V]) sortedEntries(compare func(a, b K) int) []Entry[K, V] {
+	entries := ht.Entries()
+	slices.SortFunc(entries, func(a, b Entry[K, V]) int {
+		return compare(a.Key, b.Key)
+	})
+	return entries
+}
+
+// compareDeterministic orders keys by value for the basic kinds and by their Go-syntax
+// representation otherwise. Keys of different dynamic types, possible when K is an
+// interface type, are ordered by type name first.
+func compareDeterministic[K any](a, b K) int {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		// nil interface keys come first
+		return cmp.Compare(boolToInt(va.IsValid()), boolToInt(vb.IsValid()))
+	}
+	if va.Type() != vb.Type() {
+		return cmp.Compare(va.Type().String(), vb.Type().String())
+	}
+
+	switch va.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(va.Int(), vb.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(va.Uint(), vb.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(va.Float(), vb.Float())
+	case reflect.String:
+		return cmp.Compare(va.String(), vb.String())
+	case reflect.Bool:
+		return cmp.Compare(boolToInt(va.Bool()), boolToInt(vb.Bool()))
+	}
+	return cmp.Compare(fmt.Sprintf("%#v", a), fmt.Sprintf("%#v", b))
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// SortedKeysFunc returns every key sorted by compare, which follows the
+// slices.SortFunc convention.
+func (ht *HashTable[K, V]) SortedKeysFunc(compare func(a, b K) int) []K {
+	keys := make([]K, 0, ht.Len())
+	for key := range ht.All() {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, compare)
+	return keys
+}
+
+// SortedRangeFunc returns an iterator over every key/value pair in the key order
+// defined by compare. The entries are sorted up front, so the loop body may modify
+// the HashTable.
+func (ht *HashTable[K, V]) SortedRangeFunc(compare func(a, b K) int) iter.Seq2[K, V] {
+	entries := ht.sortedEntries(compare)
+	return func(yield func(K, V) bool) {
+		for _, e := range entries {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// SortedKeys returns every key of ht in ascending order.
+func SortedKeys[K cmp.Ordered, V any](ht *HashTable[K, V]) []K {
+	return ht.SortedKeysFunc(cmp.Compare[K])
+}
+
+// SortedRange returns an iterator over every key/value pair of ht in ascending key order.
+func SortedRange[K cmp.Ordered, V any](ht *HashTable[K, V]) iter.Seq2[K, V] {
+	return ht.SortedRangeFunc(cmp.Compare[K])
+}
+
+// Chunks splits the buckets into at most n contiguous partitions holding roughly
+// the same number of entries and returns an iterator for each, so that the
+// partitions can be processed by separate goroutines. Together the iterators yield
+// every entry exactly once. The iterators only read the table, so they may run
+// concurrently with each other but not with any modification of the HashTable.
+func (ht *HashTable[K, V]) Chunks(n int) []iter.Seq2[K, V] {
+	positions, _ := ht.store.slots()
+	n = max(min(n, positions), 1)
+	chunks := make([]iter.Seq2[K, V], 0, n)
+
+	start, seen := 0, 0
+	for bucket := range positions {
+		// start the next partition before this bucket once the bucket's midpoint lies
+		// past the current partition's share of the entries
+		share := 2 * (len(chunks) + 1) * ht.Len()
+		length := ht.lengthAt(bucket)
+		if len(chunks) < n-1 && bucket > start && n*(2*seen+length) > share {
+			chunks = append(chunks, ht.bucketRange(start, bucket))
+			start = bucket
+		}
+		seen += length
+	}
+	return append(chunks, ht.bucketRange(start, positions))
+}
+
+// lengthAt returns the number of entries at storage position p.
+func (ht *HashTable[K, V]) lengthAt(p int) int {
+	d := 0
+	for ht.store.at(p, d) != nil {
+		d++
+	}
+	return d
+}
+
+// bucketRange returns an iterator over the entries in buckets [from, to).
+func (ht *HashTable[K, V]) bucketRange(from, to int) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for bucket := from; bucket < to; bucket++ {
+			for d := 0; ; d++ {
+				data := ht.store.at(bucket, d)
+				if data == nil {
+					break
+				}
+				if !yield(data.Key, data.Value) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package hashtable
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestHashTable_All(t *testing.T) {
+	ht := New[int, int](3, withHashInt[int]())
+	for i := 0; i < 10; i++ {
+		ht.Insert(i, i*10)
+	}
+
+	seen := make(map[int]int)
+	for k, v := range ht.All() {
+		seen[k] = v
+	}
+	if len(seen) != 10 {
+		t.Fatalf("All yielded %d entries, want 10", len(seen))
+	}
+	for k, v := range seen {
+		if v != k*10 {
+			t.Errorf("All yielded %d: %d, want %d", k, v, k*10)
+		}
+	}
+
+	n := 0
+	for range ht.All() {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Fatalf("break after 3 entries counted %d", n)
+	}
+}
+
+func TestHashTable_KeysSeqValuesSeq(t *testing.T) {
+	ht := New[int, int](3, withHashInt[int]())
+	for i := 0; i < 5; i++ {
+		ht.Insert(i, i*10)
+	}
+
+	keys, values := 0, 0
+	for k := range ht.KeysSeq() {
+		keys += k
+	}
+	for v := range ht.ValuesSeq() {
+		values += v
+	}
+	if keys != 10 || values != 100 {
+		t.Fatalf("sum of keys = %d, values = %d, want 10, 100", keys, values)
+	}
+}
+
+func TestHashTable_SnapshotIter(t *testing.T) {
+	ht := New[int, int](3, withHashInt[int]())
+	for i := 0; i < 10; i++ {
+		ht.Insert(i, i)
+	}
+
+	snapshot := ht.SnapshotIter()
+	ht.Insert(100, 100)
+
+	n := 0
+	for k := range snapshot {
+		// deleting while iterating must not disturb the snapshot
+		ht.Delete(k)
+		if k == 100 {
+			t.Fatal("snapshot yielded a key inserted after it was taken")
+		}
+		n++
+	}
+	if n != 10 {
+		t.Fatalf("snapshot yielded %d entries, want 10", n)
+	}
+	if ht.Len() != 1 {
+		t.Fatalf("Len() = %d after deleting every snapshot key, want 1", ht.Len())
+	}
+}
+
+func TestHashTable_Iterate(t *testing.T) {
+	ht := New[int, int](16, withHashInt[int]())
+	for i := 0; i < 100; i++ {
+		ht.Insert(i, i)
+	}
+
+	seen := make(map[int]bool)
+	var cursor Cursor
+	pages := 0
+	for {
+		var page []Entry[int, int]
+		page, cursor = ht.Iterate(cursor, 10)
+		pages++
+		for _, e := range page {
+			if seen[e.Key] {
+				t.Fatalf("key %d returned twice", e.Key)
+			}
+			seen[e.Key] = true
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	if len(seen) != 100 {
+		t.Fatalf("pagination returned %d keys, want 100", len(seen))
+	}
+	if pages < 2 {
+		t.Fatalf("pagination used %d pages, want several", pages)
+	}
+}
+
+func TestHashTable_Drain(t *testing.T) {
+	ht := New[int, int](3, withHashInt[int]())
+	for i := 0; i < 10; i++ {
+		ht.Insert(i, i)
+	}
+
+	n := 0
+	for range ht.Drain() {
+		n++
+		if n == 4 {
+			break
+		}
+	}
+	if ht.Len() != 6 {
+		t.Fatalf("Len() = %d after draining 4 entries, want 6", ht.Len())
+	}
+
+	for k := range ht.Drain() {
+		if ht.Contains(k) {
+			t.Fatalf("key %d still stored after being yielded", k)
+		}
+		n++
+	}
+	if n != 10 || ht.Len() != 0 {
+		t.Fatalf("drained %d entries leaving %d, want 10 leaving 0", n, ht.Len())
+	}
+}
+
+func TestHashTable_DeterministicAll(t *testing.T) {
+	type point struct{ X, Y int }
+	small := New[point, int](2, WithHasher[point, int](HasherFunc[point](func(p point) uint64 { return uint64(p.X + p.Y) })))
+	large := New[point, int](64, WithHasher[point, int](HasherFunc[point](func(p point) uint64 { return uint64(p.X * 31) })))
+	for _, p := range []point{{3, 1}, {1, 2}, {2, 2}, {1, 1}} {
+		small.Insert(p, p.X)
+		large.Insert(p, p.X)
+	}
+
+	var a, b []point
+	for k := range small.DeterministicAll() {
+		a = append(a, k)
+	}
+	for k := range large.DeterministicAll() {
+		b = append(b, k)
+	}
+	if len(a) != 4 || len(b) != 4 {
+		t.Fatalf("DeterministicAll yielded %d and %d keys, want 4", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("orders differ between layouts: %v vs %v", a, b)
+		}
+	}
+
+	ints := New[int, int](3, withHashInt[int]())
+	for _, k := range []int{10, 9, -1, 100} {
+		ints.Insert(k, k)
+	}
+	var got []int
+	for k := range ints.DeterministicAll() {
+		got = append(got, k)
+	}
+	if want := []int{-1, 9, 10, 100}; !slices.Equal(got, want) {
+		t.Fatalf("int keys yielded as %v, want %v", got, want)
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	ht := New[string, int](4)
+	for i, k := range []string{"pear", "apple", "fig", "banana"} {
+		ht.Insert(k, i)
+	}
+
+	if got, want := SortedKeys(ht), []string{"apple", "banana", "fig", "pear"}; !slices.Equal(got, want) {
+		t.Fatalf("SortedKeys() = %v, want %v", got, want)
+	}
+
+	byLength := func(a, b string) int { return cmp.Or(cmp.Compare(len(a), len(b)), cmp.Compare(a, b)) }
+	if got, want := ht.SortedKeysFunc(byLength), []string{"fig", "pear", "apple", "banana"}; !slices.Equal(got, want) {
+		t.Fatalf("SortedKeysFunc(byLength) = %v, want %v", got, want)
+	}
+}
+
+func TestSortedRange(t *testing.T) {
+	ht := New[int, string](3, withHashInt[string]())
+	for _, k := range []int{5, 3, 8, 1} {
+		ht.Insert(k, strconv.Itoa(k))
+	}
+
+	var keys []int
+	for k, v := range SortedRange(ht) {
+		if v != strconv.Itoa(k) {
+			t.Fatalf("SortedRange yielded %d: %q", k, v)
+		}
+		keys = append(keys, k)
+	}
+	if want := []int{1, 3, 5, 8}; !slices.Equal(keys, want) {
+		t.Fatalf("SortedRange yielded keys %v, want %v", keys, want)
+	}
+}
+
+func TestHashTable_Chunks(t *testing.T) {
+	ht := New[int, int](32, withHashInt[int]())
+	for i := 0; i < 1000; i++ {
+		ht.Insert(i, i)
+	}
+
+	chunks := ht.Chunks(4)
+	if len(chunks) != 4 {
+		t.Fatalf("len(Chunks(4)) = %d, want 4", len(chunks))
+	}
+
+	var wg sync.WaitGroup
+	counts := make([]int, len(chunks))
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range chunk {
+				counts[i]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i, c := range counts {
+		if c < 200 || c > 300 {
+			t.Errorf("chunk %d holds %d entries, want about 250", i, c)
+		}
+		total += c
+	}
+	if total != 1000 {
+		t.Fatalf("chunks yielded %d entries, want 1000", total)
+	}
+
+	if empty := New[int, int](8, withHashInt[int]()).Chunks(3); len(empty) == 0 {
+		t.Fatal("Chunks on an empty table returned no iterators")
+	}
+}
@@ -0,0 +1,94 @@
+package hashtable
+
+import (
+	"sync"
+	"time"
+)
+
+// A janitor calls a sweep function at a fixed interval from a goroutine of its own
+// until halted.
+type janitor struct {
+	stop, done chan struct{}
+}
+
+// startJanitor starts a janitor sweeping every interval, which must be positive.
+func startJanitor(interval time.Duration, sweep func()) *janitor {
+	j := &janitor{stop: make(chan struct{}), done: make(chan struct{})}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer close(j.done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sweep()
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+	return j
+}
+
+// halt stops the janitor and waits for a sweep in progress to finish.
+func (j *janitor) halt() {
+	close(j.stop)
+	<-j.done
+}
+
+// janitorSlot holds the janitor of a table, if one is running.
+type janitorSlot struct {
+	mu      sync.Mutex
+	janitor *janitor
+}
+
+// start replaces the running janitor, if any, with one sweeping every interval, or
+// with none if interval is not positive.
+func (slot *janitorSlot) start(interval time.Duration, sweep func()) {
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if slot.janitor != nil {
+		slot.janitor.halt()
+		slot.janitor = nil
+	}
+	if interval > 0 {
+		slot.janitor = startJanitor(interval, sweep)
+	}
+}
+
+// stop halts the running janitor, if any.
+func (slot *janitorSlot) stop() {
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if slot.janitor != nil {
+		slot.janitor.halt()
+		slot.janitor = nil
+	}
+}
+
+// StartJanitor starts a goroutine that calls DeleteExpired every interval, so that
+// expired entries nobody looks up again do not linger and take up memory. If a
+// janitor is already running, it is replaced by one with the new interval. An
+// interval that is not positive starts no janitor, and stops a running one. The
+// janitor keeps s reachable, so StopJanitor must be called once s is no longer
+// needed, or the goroutine leaks.
+func (s *SyncHashTable[K, V]) StartJanitor(interval time.Duration) {
+	s.janitor.start(interval, func() { s.DeleteExpired() })
+}
+
+// StopJanitor stops the goroutine started by StartJanitor, waiting for a sweep in
+// progress to finish. It does nothing if no janitor is running.
+func (s *SyncHashTable[K, V]) StopJanitor() {
+	s.janitor.stop()
+}
+
+// StartJanitor is like SyncHashTable.StartJanitor. Each sweep locks one shard at a
+// time.
+func (s *ShardedHashTable[K, V]) StartJanitor(interval time.Duration) {
+	s.janitor.start(interval, func() { s.DeleteExpired() })
+}
+
+// StopJanitor is like SyncHashTable.StopJanitor.
+func (s *ShardedHashTable[K, V]) StopJanitor() {
+	s.janitor.stop()
+}
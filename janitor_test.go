@@ -0,0 +1,59 @@
+package hashtable
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJanitor(t *testing.T) {
+	advance := fakeClock(t)
+	for name, table := range map[string]interface {
+		InsertWithTTL(int, int, time.Duration)
+		StartJanitor(time.Duration)
+		StopJanitor()
+		Len() int
+	}{
+		"sync":    NewSync[int, int](16),
+		"sharded": NewSharded[int, int](4, 16),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// stopping a janitor that never started does nothing
+			table.StopJanitor()
+			for k := 0; k < 100; k++ {
+				table.InsertWithTTL(k, k, time.Minute)
+			}
+			// the clock only moves while no janitor reads it
+			advance(time.Minute)
+			defer advance(-time.Minute)
+			table.StartJanitor(time.Hour)
+			// starting again replaces the janitor with one of a new interval
+			table.StartJanitor(time.Millisecond)
+			defer table.StopJanitor()
+
+			deadline := time.Now().Add(10 * time.Second)
+			for table.Len() > 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("Len() = %d after 10s of sweeps, want 0", table.Len())
+				}
+				time.Sleep(time.Millisecond)
+			}
+			table.StopJanitor()
+			table.StopJanitor()
+		})
+	}
+}
+
+func TestJanitor_NoInterval(t *testing.T) {
+	s := NewSync[int, int](4)
+	sharded := NewSharded[int, int](4, 16)
+	s.StartJanitor(time.Hour)
+	sharded.StartJanitor(time.Hour)
+	// an interval that is not positive stops the janitor rather than starting one
+	s.StartJanitor(0)
+	sharded.StartJanitor(-time.Second)
+	if s.janitor.janitor != nil || sharded.janitor.janitor != nil {
+		t.Fatal("StartJanitor with no interval left a janitor running")
+	}
+	s.StopJanitor()
+	sharded.StopJanitor()
+}
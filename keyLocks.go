@@ -0,0 +1,35 @@
+package hashtable
+
+import "sync"
+
+// keyStripes is the number of mutexes a keyLocks spreads keys across, per shard of
+// the table it belongs to.
+const keyStripes = 64
+
+// keyLocks provides advisory per-key locks by striping keys across a fixed set of
+// mutexes, so that locking one key never blocks the table and rarely blocks callers
+// locking other keys. The mutexes are allocated when a key is first locked.
+type keyLocks[K any] struct {
+	hasher    Hasher[K]
+	normalize func(K) K
+	once      sync.Once
+	n         int
+	stripes   []sync.Mutex
+}
+
+// init records how keys are hashed and that n stripes are to be allocated, rounded
+// up to a power of two.
+func (l *keyLocks[K]) init(hasher Hasher[K], normalize func(K) K, n int) {
+	l.hasher, l.normalize, l.n = hasher, normalize, powerOfTwo(n)
+}
+
+// stripe returns the mutex that guards key.
+func (l *keyLocks[K]) stripe(key K) *sync.Mutex {
+	l.once.Do(func() {
+		l.stripes = make([]sync.Mutex, l.n)
+	})
+	if l.normalize != nil {
+		key = l.normalize(key)
+	}
+	return &l.stripes[l.hasher.Hash(key)&uint64(len(l.stripes)-1)]
+}
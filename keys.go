@@ -0,0 +1,56 @@
+package hashtable
+
+import "github.com/jkittell/hashtable/hashers"
+
+// Key2 is a composite key of two fields, such as a tenant and a user ID. Tables
+// created with New hash each field with its own default Hasher and combine the
+// results, so no hand-written hash is needed for multi-field lookups.
+type Key2[A, B comparable] struct {
+	A A
+	B B
+}
+
+// Key3 is a composite key of three fields. See Key2.
+type Key3[A, B, C comparable] struct {
+	A A
+	B B
+	C C
+}
+
+// MakeKey2 returns the Key2 with fields a and b.
+func MakeKey2[A, B comparable](a A, b B) Key2[A, B] {
+	return Key2[A, B]{A: a, B: b}
+}
+
+// MakeKey3 returns the Key3 with fields a, b and c.
+func MakeKey3[A, B, C comparable](a A, b B, c C) Key3[A, B, C] {
+	return Key3[A, B, C]{A: a, B: b, C: c}
+}
+
+// Key2Hasher returns a Hasher for Key2 that hashes A with ha and B with hb.
+func Key2Hasher[A, B comparable](ha Hasher[A], hb Hasher[B]) Hasher[Key2[A, B]] {
+	return HasherFunc[Key2[A, B]](func(k Key2[A, B]) uint64 {
+		return hashers.Combine(ha.Hash(k.A), hb.Hash(k.B))
+	})
+}
+
+// Key3Hasher returns a Hasher for Key3 that hashes A with ha, B with hb and C with hc.
+func Key3Hasher[A, B, C comparable](ha Hasher[A], hb Hasher[B], hc Hasher[C]) Hasher[Key3[A, B, C]] {
+	return HasherFunc[Key3[A, B, C]](func(k Key3[A, B, C]) uint64 {
+		return hashers.Combine(hashers.Combine(ha.Hash(k.A), hb.Hash(k.B)), hc.Hash(k.C))
+	})
+}
+
+// compositeKey is implemented by the composite key types so that defaultHasher can
+// build a Hasher from the default Hashers of their fields.
+type compositeKey interface {
+	defaultHasher() any
+}
+
+func (Key2[A, B]) defaultHasher() any {
+	return Key2Hasher(defaultHasher[A](), defaultHasher[B]())
+}
+
+func (Key3[A, B, C]) defaultHasher() any {
+	return Key3Hasher(defaultHasher[A](), defaultHasher[B](), defaultHasher[C]())
+}
@@ -0,0 +1,67 @@
+package hashtable
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/jkittell/hashtable/hashers"
+)
+
+func TestKey2(t *testing.T) {
+	ht := New[Key2[string, int], string](16)
+	ht.Insert(MakeKey2("acme", 1), "alice")
+	ht.Insert(MakeKey2("acme", 2), "bob")
+	ht.Insert(MakeKey2("globex", 1), "carol")
+
+	if v, ok := ht.Search(MakeKey2("acme", 2)); !ok || v != "bob" {
+		t.Fatalf("Search(acme, 2) = %q, %v, want bob, true", v, ok)
+	}
+	if ht.Contains(MakeKey2("globex", 2)) {
+		t.Fatal("Contains(globex, 2) = true for a key that was never inserted")
+	}
+	if ht.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", ht.Len())
+	}
+}
+
+func TestKey3(t *testing.T) {
+	ht := New[Key3[string, string, uint32], bool](16)
+	ht.Insert(MakeKey3("tcp", "example.com", uint32(443)), true)
+
+	if !ht.Contains(MakeKey3("tcp", "example.com", uint32(443))) {
+		t.Fatal("Contains(tcp, example.com, 443) = false")
+	}
+	if ht.Contains(MakeKey3("udp", "example.com", uint32(443))) {
+		t.Fatal("Contains(udp, example.com, 443) = true for a key that was never inserted")
+	}
+}
+
+func TestKey2Hasher(t *testing.T) {
+	// swapped and neighbouring fields should spread across every bucket
+	const numberOfBuckets, keys = 16, 100
+	h := Key2Hasher[int, int](hashers.Int[int]{Seed: 1}, hashers.Int[int]{Seed: 1})
+	if h.Hash(MakeKey2(1, 2)) == h.Hash(MakeKey2(2, 1)) {
+		t.Error("Key2Hasher hashes (1, 2) and (2, 1) the same")
+	}
+
+	counts := make([]int, numberOfBuckets)
+	for a := 0; a < keys; a++ {
+		for b := 0; b < keys; b++ {
+			counts[h.Hash(MakeKey2(a, b))%numberOfBuckets]++
+		}
+	}
+	for b, c := range counts {
+		if c < keys*keys/numberOfBuckets/2 || c > keys*keys/numberOfBuckets*2 {
+			t.Errorf("bucket %d holds %d of %d keys", b, c, keys*keys)
+		}
+	}
+
+	h3 := Key3Hasher[string, int, int](hashers.String{}, hashers.Int[int]{}, hashers.Int[int]{})
+	seen := make(map[uint64]bool)
+	for i := 0; i < keys; i++ {
+		seen[h3.Hash(MakeKey3(strconv.Itoa(i), i, i))] = true
+	}
+	if len(seen) != keys {
+		t.Errorf("Key3Hasher produced %d distinct hashes for %d keys", len(seen), keys)
+	}
+}
@@ -0,0 +1,203 @@
+package hashtable
+
+// States of a slot in an open-addressing table.
+const (
+	slotEmpty uint8 = iota
+	slotFull
+	// slotDeleted marks a tombstone: the slot is free, but probes for other keys
+	// must continue past it.
+	slotDeleted
+)
+
+// minSlots is the smallest number of slots an open-addressing table allocates.
+const minSlots = 8
+
+// linear is an open-addressing storage with linear probing: every entry lives in
+// one flat array, and a key whose slot is taken moves on to the next free one.
+// Removed entries leave tombstones, which are cleared whenever the table is
+// rebuilt. Slots holding entries and tombstones together never exceed three
+// quarters of the table, so every probe ends at an empty slot.
+type linear[K any, V any] struct {
+	equal      func(a, b K) bool
+	entries    []kv[K, V]
+	ctrl       []uint8
+	size       int
+	tombstones int
+}
+
+func newLinear[K any, V any](l layout[K], n int) *linear[K, V] {
+	capacity := max(minSlots, powerOfTwo(n))
+	return &linear[K, V]{
+		equal:   l.equal,
+		entries: make([]kv[K, V], capacity),
+		ctrl:    make([]uint8, capacity),
+	}
+}
+
+// powerOfTwo returns the smallest power of two that is at least n.
+func powerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+// slotsFor returns the number of slots needed to hold n entries at a load of at
+// most num/den.
+func slotsFor(n, num, den int) int {
+	return max(minSlots, powerOfTwo((den*n+num-1)/num))
+}
+
+// locate returns the slot holding key, or -1.
+func (t *linear[K, V]) locate(key K, h uint64) int {
+	mask := uint64(len(t.entries) - 1)
+	for i := h & mask; ; i = (i + 1) & mask {
+		switch t.ctrl[i] {
+		case slotEmpty:
+			return -1
+		case slotFull:
+			if t.entries[i].hash == h && t.equal(key, t.entries[i].Key) {
+				return int(i)
+			}
+		}
+	}
+}
+
+func (t *linear[K, V]) find(key K, h uint64) *kv[K, V] {
+	i := t.locate(key, h)
+	if i < 0 {
+		return nil
+	}
+	return &t.entries[i]
+}
+
+func (t *linear[K, V]) add(key K, h uint64, value V) int {
+	if 4*(t.size+t.tombstones+1) > 3*len(t.entries) {
+		// rebuilding at the same size is enough when tombstones fill the table
+		t.resize(max(len(t.entries), slotsFor(t.size+1, 3, 4)))
+	}
+	t.size++
+	return t.place(kv[K, V]{Key: key, Value: value, hash: h})
+}
+
+// place puts data in the first free slot of its probe sequence and returns the
+// number of slots probed.
+func (t *linear[K, V]) place(data kv[K, V]) int {
+	mask := uint64(len(t.entries) - 1)
+	probes := 1
+	i := data.hash & mask
+	for t.ctrl[i] == slotFull {
+		i = (i + 1) & mask
+		probes++
+	}
+	if t.ctrl[i] == slotDeleted {
+		t.tombstones--
+	}
+	t.entries[i] = data
+	t.ctrl[i] = slotFull
+	return probes
+}
+
+func (t *linear[K, V]) remove(key K, h uint64) (kv[K, V], bool) {
+	i := t.locate(key, h)
+	if i < 0 {
+		return kv[K, V]{}, false
+	}
+	data := t.entries[i]
+	t.removeAt(i)
+	t.size--
+	return data, true
+}
+
+// removeAt frees slot i. A probe that reaches a free slot followed by an empty one
+// would stop at the empty slot anyway, so such a slot, and any tombstones directly
+// before it, become empty instead of tombstones.
+func (t *linear[K, V]) removeAt(i int) {
+	mask := len(t.entries) - 1
+	// zero the slot so the garbage collector can reclaim what it references
+	t.entries[i] = kv[K, V]{}
+	if t.ctrl[(i+1)&mask] != slotEmpty {
+		t.ctrl[i] = slotDeleted
+		t.tombstones++
+		return
+	}
+	t.ctrl[i] = slotEmpty
+	for j := (i - 1) & mask; t.ctrl[j] == slotDeleted; j = (j - 1) & mask {
+		t.ctrl[j] = slotEmpty
+		t.tombstones--
+	}
+}
+
+func (t *linear[K, V]) len() int {
+	return t.size
+}
+
+func (t *linear[K, V]) clear() {
+	clear(t.entries)
+	clear(t.ctrl)
+	t.size = 0
+	t.tombstones = 0
+}
+
+func (t *linear[K, V]) all(yield func(*kv[K, V]) bool) {
+	for i := range t.entries {
+		if t.ctrl[i] == slotFull && !yield(&t.entries[i]) {
+			return
+		}
+	}
+}
+
+func (t *linear[K, V]) deleteFunc(del func(*kv[K, V]) bool) int {
+	removed := 0
+	for i := range t.entries {
+		if t.ctrl[i] == slotFull && del(&t.entries[i]) {
+			t.removeAt(i)
+			removed++
+		}
+	}
+	t.size -= removed
+	return removed
+}
+
+func (t *linear[K, V]) slots() (int, int) {
+	return len(t.entries), 1
+}
+
+func (t *linear[K, V]) at(p, d int) *kv[K, V] {
+	if d > 0 || t.ctrl[p] != slotFull {
+		return nil
+	}
+	return &t.entries[p]
+}
+
+func (t *linear[K, V]) reserve(n int) {
+	if slots := slotsFor(n, 3, 4); slots > len(t.entries) {
+		t.resize(slots)
+	}
+}
+
+func (t *linear[K, V]) compact() {
+	if t.tombstones > 0 {
+		t.resize(len(t.entries))
+	}
+}
+
+// shrink lowers the number of slots to what the entries need at half the maximum
+// load, then clears any tombstones.
+func (t *linear[K, V]) shrink() {
+	t.resize(min(len(t.entries), slotsFor(2*t.size, 3, 4)))
+}
+
+// resize moves every entry into a new array of n slots, dropping all tombstones.
+func (t *linear[K, V]) resize(n int) {
+	entries, ctrl := t.entries, t.ctrl
+	t.entries = make([]kv[K, V], n)
+	t.ctrl = make([]uint8, n)
+	t.tombstones = 0
+	for i := range entries {
+		if ctrl[i] == slotFull {
+			t.place(entries[i])
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package lru
+
+import "github.com/jkittell/hashtable"
+
+// WithARC makes the Cache evict entries by the Adaptive Replacement Cache policy of
+// Megiddo and Modha, which balances recency and frequency by itself instead of
+// having to be chosen between LRU and LFU. ARC splits the entries between a list of
+// those used once since they were added and a list of those used again, and also
+// remembers the keys recently evicted from each list, without their values. A miss
+// on a key evicted from the first list means it was evicted too soon, so ARC grows
+// its target for that list; a miss on one from the second list shrinks it. Stats
+// reports the four lists. It is shorthand for WithPolicy(ARC).
+func WithARC[K comparable, V any]() Option[K, V] {
+	return WithPolicy[K, V](ARC)
+}
+
+// ARCStats describes the lists of a Cache using the ARC policy, named as in the
+// paper that introduced it.
+type ARCStats struct {
+	// T1 is the number of entries used once since they were added, and T2 the
+	// number used more than once.
+	T1, T2 int
+	// B1 and B2 are the number of keys remembered after their eviction from T1 and
+	// T2 respectively.
+	B1, B2 int
+	// Target is the number of entries ARC currently aims to keep in T1, p in the
+	// paper.
+	Target int
+}
+
+// arcPolicy implements ARC, adapted to a Cache from which entries may also be
+// removed, so that the lists may hold fewer entries than the capacity even while
+// keys are remembered after eviction.
+type arcPolicy[K comparable, V any] struct {
+	capacity int
+	target   int
+	// recent and frequent are T1 and T2, most recently used first.
+	recent, frequent list[K, V]
+	// recentGhosts and frequentGhosts are B1 and B2, most recently evicted first,
+	// holding nodes without values, which ghosts indexes by key.
+	recentGhosts, frequentGhosts list[K, V]
+	ghosts                       *hashtable.HashTable[K, *node[K, V]]
+}
+
+func newARC[K comparable, V any](capacity int) *arcPolicy[K, V] {
+	p := &arcPolicy[K, V]{
+		capacity: capacity,
+		ghosts:   hashtable.New[K, *node[K, V]](capacity),
+	}
+	p.recent.init()
+	p.frequent.init()
+	p.recentGhosts.init()
+	p.frequentGhosts.init()
+	return p
+}
+
+func (p *arcPolicy[K, V]) hit(n *node[K, V]) {
+	if n.list == &p.recent {
+		p.recent.remove(n)
+		p.frequent.pushFront(n)
+		return
+	}
+	p.frequent.moveToFront(n)
+}
+
+func (p *arcPolicy[K, V]) add(n *node[K, V], full bool) *node[K, V] {
+	var victim *node[K, V]
+	if g, ok := p.ghosts.Search(n.key); ok {
+		// the key was evicted too soon from the list it was remembered by, so the
+		// target moves in favor of that list
+		fromFrequent := g.list == &p.frequentGhosts
+		p.target = p.adapt(fromFrequent)
+		p.forget(g)
+		if full {
+			victim = p.replace(fromFrequent)
+		}
+		p.frequent.pushFront(n)
+		return victim
+	}
+
+	switch {
+	case p.recent.len >= p.capacity && full:
+		// T1 alone fills the cache, so its oldest entry goes without being
+		// remembered
+		victim = p.recent.back()
+		p.recent.remove(victim)
+	case p.recent.len+p.recentGhosts.len >= p.capacity:
+		p.forget(p.recentGhosts.back())
+		if full {
+			victim = p.replace(false)
+		}
+	default:
+		if p.recent.len+p.frequent.len+p.recentGhosts.len+p.frequentGhosts.len >= 2*p.capacity && p.frequentGhosts.len > 0 {
+			p.forget(p.frequentGhosts.back())
+		}
+		if full {
+			victim = p.replace(false)
+		}
+	}
+	p.recent.pushFront(n)
+	return victim
+}
+
+func (p *arcPolicy[K, V]) victim(key K) *node[K, V] {
+	if g, ok := p.ghosts.Search(key); ok {
+		fromFrequent := g.list == &p.frequentGhosts
+		return p.source(fromFrequent, p.adapt(fromFrequent)).back()
+	}
+	if p.recent.len >= p.capacity {
+		return p.recent.back()
+	}
+	return p.source(false, p.target).back()
+}
+
+// evict evicts as add does for key, but without adapting the target, which the add
+// that follows does, and then forgets the oldest keys remembered beyond the
+// capacity, since evictions by cost may outnumber the additions that forget them.
+func (p *arcPolicy[K, V]) evict(key K) *node[K, V] {
+	var victim *node[K, V]
+	if g, ok := p.ghosts.Search(key); ok {
+		victim = p.replace(g.list == &p.frequentGhosts)
+	} else if p.recent.len >= p.capacity {
+		victim = p.recent.back()
+		p.recent.remove(victim)
+	} else {
+		victim = p.replace(false)
+	}
+	for p.recentGhosts.len+p.frequentGhosts.len > p.capacity {
+		if p.recentGhosts.len >= p.frequentGhosts.len {
+			p.forget(p.recentGhosts.back())
+		} else {
+			p.forget(p.frequentGhosts.back())
+		}
+	}
+	return victim
+}
+
+// adapt returns the target after a miss on a key remembered in B2 if fromFrequent
+// is set, or in B1 otherwise.
+func (p *arcPolicy[K, V]) adapt(fromFrequent bool) int {
+	if fromFrequent {
+		return max(0, p.target-max(p.recentGhosts.len/p.frequentGhosts.len, 1))
+	}
+	return min(p.capacity, p.target+max(p.frequentGhosts.len/p.recentGhosts.len, 1))
+}
+
+// source returns T1 or T2, whichever target calls for replace to evict from.
+// fromFrequent reports that the entry being added was remembered in B2.
+func (p *arcPolicy[K, V]) source(fromFrequent bool, target int) *list[K, V] {
+	if p.frequent.len == 0 || p.recent.len > 0 && (p.recent.len > target || fromFrequent && p.recent.len == target) {
+		return &p.recent
+	}
+	return &p.frequent
+}
+
+// replace evicts the oldest entry of the list source picks, remembers its key and
+// returns it.
+func (p *arcPolicy[K, V]) replace(fromFrequent bool) *node[K, V] {
+	from, to := &p.frequent, &p.frequentGhosts
+	if p.source(fromFrequent, p.target) == &p.recent {
+		from, to = &p.recent, &p.recentGhosts
+	}
+	victim := from.back()
+	from.remove(victim)
+	g := &node[K, V]{key: victim.key}
+	to.pushFront(g)
+	p.ghosts.Insert(g.key, g)
+	return victim
+}
+
+// forget drops g, a remembered key.
+func (p *arcPolicy[K, V]) forget(g *node[K, V]) {
+	g.list.remove(g)
+	p.ghosts.Delete(g.key)
+}
+
+func (p *arcPolicy[K, V]) remove(n *node[K, V]) {
+	n.list.remove(n)
+}
+
+func (p *arcPolicy[K, V]) stats() ARCStats {
+	return ARCStats{
+		T1:     p.recent.len,
+		T2:     p.frequent.len,
+		B1:     p.recentGhosts.len,
+		B2:     p.frequentGhosts.len,
+		Target: p.target,
+	}
+}
@@ -0,0 +1,82 @@
+package lru
+
+// WithLFU makes the Cache evict the least frequently used entry, the one read or
+// written the fewest times since it was added, instead of the least recently used.
+// Among entries used equally often, the least recently used goes first. LFU suits
+// workloads where a stable set of popular keys is interleaved with many keys used
+// once, which would push the popular keys out of an LRU cache; on the other hand,
+// a key that was popular once stays cached long after it has stopped being used.
+// It is shorthand for WithPolicy(LFU).
+func WithLFU[K comparable, V any]() Option[K, V] {
+	return WithPolicy[K, V](LFU)
+}
+
+// lfuPolicy counts the uses of every entry, in constant time per operation: the
+// entries used the same number of times share a list, in order of use, and the
+// lists are chained in increasing order of their count, with no list for a count
+// that no entry has. A use moves an entry to the list of the next count, creating
+// it if need be, and eviction takes the back of the first list.
+type lfuPolicy[K comparable, V any] struct {
+	// counts is the sentinel of the chain of lists: counts.higher is the list of
+	// the lowest count, and counts.lower that of the highest.
+	counts list[K, V]
+}
+
+func newLFU[K comparable, V any]() *lfuPolicy[K, V] {
+	p := &lfuPolicy[K, V]{}
+	p.counts.lower, p.counts.higher = &p.counts, &p.counts
+	return p
+}
+
+// after returns the list of count freq, which must follow l in the chain, creating
+// it if there is none.
+func (p *lfuPolicy[K, V]) after(l *list[K, V], freq uint64) *list[K, V] {
+	if next := l.higher; next != &p.counts && next.freq == freq {
+		return next
+	}
+	next := &list[K, V]{freq: freq, lower: l, higher: l.higher}
+	next.init()
+	l.higher.lower = next
+	l.higher = next
+	return next
+}
+
+// take removes n from its list, and the list from the chain once it is empty.
+func (p *lfuPolicy[K, V]) take(n *node[K, V]) {
+	l := n.list
+	l.remove(n)
+	if l.len == 0 {
+		l.lower.higher, l.higher.lower = l.higher, l.lower
+	}
+}
+
+func (p *lfuPolicy[K, V]) hit(n *node[K, V]) {
+	l := n.list
+	// the list of the next count is found before taking n leaves l unchained
+	next := p.after(l, l.freq+1)
+	p.take(n)
+	next.pushFront(n)
+}
+
+func (p *lfuPolicy[K, V]) add(n *node[K, V], full bool) *node[K, V] {
+	var victim *node[K, V]
+	if full {
+		victim = p.evict(n.key)
+	}
+	p.after(&p.counts, 1).pushFront(n)
+	return victim
+}
+
+func (p *lfuPolicy[K, V]) victim(K) *node[K, V] {
+	return p.counts.higher.back()
+}
+
+func (p *lfuPolicy[K, V]) evict(K) *node[K, V] {
+	victim := p.counts.higher.back()
+	p.take(victim)
+	return victim
+}
+
+func (p *lfuPolicy[K, V]) remove(n *node[K, V]) {
+	p.take(n)
+}
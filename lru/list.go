@@ -0,0 +1,67 @@
+package lru
+
+import "time"
+
+// A node is an entry of a cache, linked into a list of entries through the entry
+// itself, so that moving an entry within or between lists allocates nothing.
+type node[K any, V any] struct {
+	key        K
+	value      V
+	prev, next *node[K, V]
+	// cost is the cost of the entry under WithMaxCost, or 0.
+	cost int64
+	// staleAt is the time from which a LoadingCache refreshes the entry under
+	// WithStaleWhileRevalidate.
+	staleAt time.Time
+	// list is the list holding the node, or nil.
+	list *list[K, V]
+}
+
+// A list is a doubly-linked list of nodes, circular through a sentinel, so that
+// linking and unlinking need no special cases for the ends.
+type list[K any, V any] struct {
+	root node[K, V]
+	len  int
+	// freq, lower and higher are used by lfuPolicy, which chains a list of the
+	// nodes used freq times to the lists of the nearest lower and higher counts.
+	freq          uint64
+	lower, higher *list[K, V]
+}
+
+// init empties l. A list must be initialized before use.
+func (l *list[K, V]) init() {
+	l.root.prev, l.root.next = &l.root, &l.root
+	l.len = 0
+}
+
+// pushFront links n, which must not be in a list, at the front of l.
+func (l *list[K, V]) pushFront(n *node[K, V]) {
+	n.prev, n.next = &l.root, l.root.next
+	n.prev.next, n.next.prev = n, n
+	n.list = l
+	l.len++
+}
+
+// moveToFront moves n, which must be in l, to the front of l.
+func (l *list[K, V]) moveToFront(n *node[K, V]) {
+	if l.root.next == n {
+		return
+	}
+	l.remove(n)
+	l.pushFront(n)
+}
+
+// remove unlinks n, which must be in l.
+func (l *list[K, V]) remove(n *node[K, V]) {
+	n.prev.next, n.next.prev = n.next, n.prev
+	n.prev, n.next, n.list = nil, nil, nil
+	l.len--
+}
+
+// back returns the last node of l, or nil if l is empty.
+func (l *list[K, V]) back() *node[K, V] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
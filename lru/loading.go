@@ -0,0 +1,218 @@
+package lru
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jkittell/hashtable"
+)
+
+// A LoadingCache is a Cache that loads the values it misses: its Get calls a loader
+// for a key that is not stored, stores the value loaded and returns it, so callers
+// read through the cache without handling misses themselves. The methods of Cache,
+// such as Put and Peek, are available too, and do not call the loader.
+type LoadingCache[K comparable, V any] struct {
+	*Cache[K, V]
+	loader func(ctx context.Context, key K) (V, error)
+	// mu guards loads, the loads in progress, and failures, the errors remembered
+	// under WithErrorTTL, which expire by the TTLs of the table.
+	mu       sync.Mutex
+	loads    *hashtable.HashTable[K, *load[V]]
+	failures *hashtable.HashTable[K, error]
+}
+
+// A load is a call of the loader of a LoadingCache in progress, which other callers
+// missing the same key wait for instead of calling the loader themselves.
+type load[V any] struct {
+	// done is closed once the loader has returned or panicked.
+	done  chan struct{}
+	value V
+	err   error
+	// shared reports that value and err may be handed to the callers waiting, which
+	// is not the case when the loader panicked or failed because the context of its
+	// caller was done.
+	shared bool
+	// invalidated is set, under the mutex of the LoadingCache, when the key is Put
+	// or Removed while the loader runs, whose result is then out of date and not
+	// stored.
+	invalidated bool
+}
+
+// WithErrorTTL makes a LoadingCache remember for d that loading a key failed: until
+// then, Get returns the same error for the key without calling the loader, so a key
+// that cannot be loaded does not send a request to the backend on every call. A Put
+// or a Remove of the key forgets the error.
+func WithErrorTTL[K comparable, V any](d time.Duration) Option[K, V] {
+	return WithErrorTTLFunc[K, V](func(K, error) time.Duration { return d })
+}
+
+// WithErrorTTLFunc is like WithErrorTTL, with the time an error is remembered chosen
+// for each failed load by ttl, so that, for example, a key found not to exist is
+// remembered for a minute while a timeout is not remembered at all. A ttl that is
+// not positive does not remember the error.
+func WithErrorTTLFunc[K comparable, V any](ttl func(key K, err error) time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.errorTTL = ttl
+	}
+}
+
+// timeNow returns the current time. Tests replace it to control the clock.
+var timeNow = time.Now
+
+// WithStaleWhileRevalidate makes a LoadingCache refresh the value of a key once it
+// is older than refreshAfter, without making callers wait: Get returns the stale
+// value at once and starts loading a fresh one in the background, which replaces it
+// when the loader returns. A slow loader then holds up only the first Get of a key
+// that is not stored, not those of keys in use. The background load gets the
+// values of the context of the Get that started it, but is not canceled with it.
+// If it fails, the stale value is kept, and refreshed again once refreshAfter has
+// elapsed once more. A Put of a key stores a fresh value too.
+func WithStaleWhileRevalidate[K comparable, V any](refreshAfter time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.refreshAfter = refreshAfter
+	}
+}
+
+// NewLoadingCache creates a LoadingCache holding up to capacity entries, configured
+// by opts as with New, which calls loader for the keys it misses.
+func NewLoadingCache[K comparable, V any](capacity int, loader func(ctx context.Context, key K) (V, error), opts ...Option[K, V]) *LoadingCache[K, V] {
+	c := New(capacity, opts...)
+	return &LoadingCache[K, V]{
+		Cache:  c,
+		loader: loader,
+		loads:  hashtable.New[K, *load[V]](1),
+		// errors are remembered for no more keys than values are
+		failures: hashtable.New(1, hashtable.WithMaxEntries[K, error](c.capacity)),
+	}
+}
+
+// Get returns the value of key, calling the loader with ctx to load and store it if
+// it is not stored. However many goroutines miss key at once, the loader runs for it
+// in only one of them, while the others wait for its result, or until their own ctx
+// is done. If the loader returns an error, nothing is stored and the error is
+// returned, and remembered if WithErrorTTL says so. If it fails because the ctx of
+// its caller is done, or panics, the callers waiting for it load key in turn.
+func (lc *LoadingCache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	for {
+		if value, stale, ok := lc.Cache.get(key); ok {
+			if stale {
+				lc.refresh(ctx, key)
+			}
+			return value, nil
+		}
+		l, leader, value, err := lc.join(key)
+		if l == nil {
+			return value, err
+		}
+		if leader {
+			return lc.run(ctx, key, l)
+		}
+		select {
+		case <-l.done:
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+		if l.shared {
+			return l.value, l.err
+		}
+	}
+}
+
+// join returns the load in progress for key, or registers a new one and reports
+// that the caller leads it and must carry it out with run. It returns no load, but
+// the error remembered for key, or its value if it has been stored since the
+// caller's lookup, instead.
+func (lc *LoadingCache[K, V]) join(key K) (l *load[V], leader bool, value V, err error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if err, ok := lc.failures.Search(key); ok {
+		return nil, false, value, err
+	}
+	if l, ok := lc.loads.Search(key); ok {
+		return l, false, value, nil
+	}
+	// a load of key may have finished between the caller's lookup and now, so look
+	// again while no load can start or finish
+	if value, ok := lc.Cache.Peek(key); ok {
+		return nil, false, value, nil
+	}
+	l = &load[V]{done: make(chan struct{})}
+	lc.loads.Insert(key, l)
+	return l, true, value, nil
+}
+
+// run calls the loader for key, stores its result unless key was Put or Removed
+// meanwhile, and hands it to the callers waiting for l.
+func (lc *LoadingCache[K, V]) run(ctx context.Context, key K, l *load[V]) (V, error) {
+	defer func() {
+		lc.mu.Lock()
+		lc.loads.Delete(key)
+		lc.mu.Unlock()
+		close(l.done)
+	}()
+	value, err := lc.loader(ctx, key)
+	if err != nil && ctx.Err() != nil {
+		// the error is that of the caller, not of key
+		return value, err
+	}
+	var ttl time.Duration
+	if err != nil && lc.errorTTL != nil {
+		ttl = lc.errorTTL(key, err)
+	}
+	lc.mu.Lock()
+	if !l.invalidated {
+		if err == nil {
+			// an error remembered from a failed refresh is out of date too
+			lc.failures.Delete(key)
+			lc.Cache.Put(key, value)
+		} else if ttl > 0 {
+			lc.failures.InsertWithTTL(key, err, ttl)
+		}
+	}
+	lc.mu.Unlock()
+	l.value, l.err, l.shared = value, err, true
+	return value, err
+}
+
+// refresh starts loading key in the background, unless a load of key is in
+// progress already.
+func (lc *LoadingCache[K, V]) refresh(ctx context.Context, key K) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.loads.Contains(key) {
+		return
+	}
+	l := &load[V]{done: make(chan struct{})}
+	lc.loads.Insert(key, l)
+	go lc.run(context.WithoutCancel(ctx), key, l)
+}
+
+// Put is like Cache.Put, and forgets any error remembered for key. A load of key in
+// progress does not store its result over value.
+func (lc *LoadingCache[K, V]) Put(key K, value V) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.forget(key)
+	lc.Cache.Put(key, value)
+}
+
+// Remove is like Cache.Remove, and forgets any error remembered for key, so that
+// the next Get calls the loader. A load of key in progress does not store its
+// result.
+func (lc *LoadingCache[K, V]) Remove(key K) bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.forget(key)
+	return lc.Cache.Remove(key)
+}
+
+// forget drops the error remembered for key, if any, and keeps a load of key in
+// progress from storing its result. The caller must hold lc.mu.
+func (lc *LoadingCache[K, V]) forget(key K) {
+	lc.failures.Delete(key)
+	if l, ok := lc.loads.Search(key); ok {
+		l.invalidated = true
+	}
+}
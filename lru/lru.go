@@ -0,0 +1,276 @@
+// Package lru provides a cache built on hashtable.HashTable that holds a bounded
+// number of entries, evicting the least recently used one, or the one chosen by
+// another Policy, to make room for a new one.
+package lru
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jkittell/hashtable"
+)
+
+// A Cache holds up to a fixed number of key/value pairs. Once it is full, storing a
+// new key evicts the entry that has gone longest without being read or written,
+// unless another Policy is selected with WithPolicy. Entries are found through a
+// hashtable.HashTable and ordered by the policy in doubly-linked lists threaded
+// through the entries themselves, so every operation takes constant time.
+//
+// A Cache is safe for concurrent use by multiple goroutines. The eviction callback
+// runs with the lock of the Cache held and must not call its methods.
+type Cache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	table    *hashtable.HashTable[K, *node[K, V]]
+	kind     Policy
+	policy   policy[K, V]
+	onEvict  func(key K, value V)
+	// tinyLFU is set by WithTinyLFU, for which New creates sketch to count the
+	// uses of keys and decide whether to admit them.
+	tinyLFU bool
+	sketch  *sketch[K]
+	// costOf, if set by WithMaxCost, gives the cost of an entry, and the entries
+	// stored cost cost in total, at most maxCost.
+	costOf        func(key K, value V) int64
+	cost, maxCost int64
+	// errorTTL, if set by WithErrorTTLFunc, tells a LoadingCache how long to
+	// remember a failed load.
+	errorTTL func(key K, err error) time.Duration
+	// refreshAfter, if set by WithStaleWhileRevalidate, is the age at which a
+	// LoadingCache refreshes an entry.
+	refreshAfter time.Duration
+	// hits, misses, evictions and rejections are reported by Stats.
+	hits, misses, evictions, rejections uint64
+}
+
+// Stats describes the contents of a Cache and counts the outcomes of its
+// operations since it was created.
+type Stats struct {
+	// Len is the number of entries stored, and Capacity the most it can hold.
+	Len, Capacity int
+	// Cost is the total cost of the entries stored, and MaxCost the most they may
+	// cost, both zero without WithMaxCost.
+	Cost, MaxCost int64
+	// Hits and Misses count the calls of Get that found their key and those that
+	// did not.
+	Hits, Misses uint64
+	// Evictions counts the entries evicted to make room for others, and
+	// Rejections the new keys WithTinyLFU did not store, or that cost more than
+	// the budget set by WithMaxCost.
+	Evictions, Rejections uint64
+	// ARC describes the lists of the ARC policy, and is zero for other policies.
+	ARC ARCStats
+}
+
+// An Option configures a Cache created by New.
+type Option[K comparable, V any] func(*Cache[K, V])
+
+// WithOnEvict makes the Cache call fn with every entry it evicts to make room for
+// another. Entries removed with Remove or replaced by Put are not passed to fn.
+func WithOnEvict[K comparable, V any](fn func(key K, value V)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.onEvict = fn
+	}
+}
+
+// WithPolicy makes the Cache choose the entries it evicts by p, so the policy can be
+// chosen per workload, or from configuration, without changing any call site.
+// Options such as WithLFU are shorthands for WithPolicy. An unknown Policy selects
+// LRU.
+func WithPolicy[K comparable, V any](p Policy) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.kind = p
+	}
+}
+
+// WithMaxCost bounds the Cache by the total cost of its entries, as given by cost,
+// rather than by their number alone, for values whose sizes vary widely: with
+// cost returning len(value), a budget of 64<<20 holds up to 64 MiB of values,
+// whether in a few large ones or many small ones. Storing an entry evicts others,
+// chosen by the policy of the Cache, until the total is within budget again. An
+// entry that alone costs more than budget is not stored, and removes the value
+// stored for its key, if any. cost must not be negative. The capacity given to New
+// still bounds the number of entries.
+func WithMaxCost[K comparable, V any](budget int64, cost func(key K, value V) int64) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.costOf, c.maxCost = cost, budget
+	}
+}
+
+// New creates a Cache holding up to capacity entries, configured by opts. A
+// capacity below one is taken as one.
+func New[K comparable, V any](capacity int, opts ...Option[K, V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		capacity: max(capacity, 1),
+		table:    hashtable.New[K, *node[K, V]](max(capacity, 1)),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.policy = newPolicy[K, V](c.kind, c.capacity)
+	if c.tinyLFU {
+		c.sketch = newSketch[K](c.capacity)
+	}
+	return c
+}
+
+// Get returns the value of key and records a use of it.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	value, _, ok := c.get(key)
+	return value, ok
+}
+
+// get is like Get, also reporting whether the entry has become stale under
+// WithStaleWhileRevalidate, in which case it is not reported stale again until
+// refreshAfter has elapsed once more, so that one caller refreshes it at a time.
+func (c *Cache[K, V]) get(key K) (value V, stale, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.table.Search(key)
+	if !ok {
+		// a miss is usually followed by a Put of the key, where its use is counted
+		c.misses++
+		return value, false, false
+	}
+	c.hits++
+	if c.sketch != nil {
+		c.sketch.add(key)
+	}
+	c.policy.hit(n)
+	if c.refreshAfter > 0 {
+		if t := timeNow(); !t.Before(n.staleAt) {
+			stale = true
+			n.staleAt = t.Add(c.refreshAfter)
+		}
+	}
+	return n.value, stale, true
+}
+
+// Peek returns the value of key without recording a use of it, so that reading
+// entries for metrics or debugging neither changes which entry is evicted next nor
+// counts in Stats.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.table.Search(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return n.value, true
+}
+
+// Put stores value for key and records a use of it. If key is new and the Cache is
+// full, an entry chosen by the policy of the Cache is evicted first, unless
+// WithTinyLFU rejects key instead. Under WithMaxCost, as many entries are evicted
+// as it takes to stay within budget, which may include key itself when its new
+// value costs more.
+func (c *Cache[K, V]) Put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sketch != nil {
+		c.sketch.add(key)
+	}
+	var cost int64
+	if c.costOf != nil {
+		cost = c.costOf(key, value)
+	}
+	if n, ok := c.table.Search(key); ok {
+		if c.costOf != nil && cost > c.maxCost {
+			c.rejections++
+			c.policy.remove(n)
+			c.table.Delete(key)
+			c.cost -= n.cost
+			return
+		}
+		c.policy.hit(n)
+		n.value = value
+		c.fresh(n)
+		c.cost += cost - n.cost
+		n.cost = cost
+		for c.costOf != nil && c.cost > c.maxCost {
+			c.evict(c.policy.evict(key))
+		}
+		return
+	}
+	if c.costOf != nil && cost > c.maxCost {
+		c.rejections++
+		return
+	}
+	full := c.table.Len() >= c.capacity
+	over := c.costOf != nil && c.cost+cost > c.maxCost
+	if (full || over) && c.sketch != nil && c.sketch.estimate(key) <= c.sketch.estimate(c.policy.victim(key).key) {
+		c.rejections++
+		return
+	}
+	for ; over; over = c.cost+cost > c.maxCost {
+		c.evict(c.policy.evict(key))
+		full = c.table.Len() >= c.capacity
+	}
+	n := &node[K, V]{key: key, value: value, cost: cost}
+	c.fresh(n)
+	if victim := c.policy.add(n, full); victim != nil {
+		c.evict(victim)
+	}
+	c.table.Insert(key, n)
+	c.cost += cost
+}
+
+// fresh marks n, whose value has just been stored, as fresh under
+// WithStaleWhileRevalidate.
+func (c *Cache[K, V]) fresh(n *node[K, V]) {
+	if c.refreshAfter > 0 {
+		n.staleAt = timeNow().Add(c.refreshAfter)
+	}
+}
+
+// evict deletes victim, which the policy no longer tracks, to make room for another.
+func (c *Cache[K, V]) evict(victim *node[K, V]) {
+	c.table.Delete(victim.key)
+	c.cost -= victim.cost
+	c.evictions++
+	if c.onEvict != nil {
+		c.onEvict(victim.key, victim.value)
+	}
+}
+
+// Remove deletes key, reporting whether it was stored.
+func (c *Cache[K, V]) Remove(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.table.Search(key)
+	if !ok {
+		return false
+	}
+	c.policy.remove(n)
+	c.table.Delete(key)
+	c.cost -= n.cost
+	return true
+}
+
+// Len returns the number of entries stored.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.table.Len()
+}
+
+// Stats reports the contents and counters of the Cache.
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := Stats{
+		Len:        c.table.Len(),
+		Capacity:   c.capacity,
+		Cost:       c.cost,
+		MaxCost:    c.maxCost,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+		Rejections: c.rejections,
+	}
+	if arc, ok := c.policy.(*arcPolicy[K, V]); ok {
+		stats.ARC = arc.stats()
+	}
+	return stats
+}
@@ -0,0 +1,655 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/jkittell/hashtable/hashers"
+)
+
+func TestCache(t *testing.T) {
+	var evicted []int
+	c := New(3, WithOnEvict(func(k int, v string) {
+		evicted = append(evicted, k)
+	}))
+	c.Put(1, "one")
+	c.Put(2, "two")
+	c.Put(3, "three")
+	// reading 1 makes 2 the least recently used entry
+	if v, ok := c.Get(1); !ok || v != "one" {
+		t.Fatalf("Get(1) = %q, %v", v, ok)
+	}
+	c.Put(4, "four")
+	if _, ok := c.Get(2); ok {
+		t.Errorf("2 is still cached after 4 was added to a full cache")
+	}
+	// replacing a value counts as a use and evicts nothing
+	c.Put(3, "THREE")
+	c.Put(5, "five")
+	if !slices.Equal(evicted, []int{2, 1}) {
+		t.Errorf("evicted %v, want [2 1]", evicted)
+	}
+	if v, _ := c.Get(3); v != "THREE" {
+		t.Errorf("Get(3) = %q after it was replaced", v)
+	}
+	if c.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", c.Len())
+	}
+
+	if !c.Remove(4) || c.Remove(4) {
+		t.Errorf("Remove(4) twice did not report true, then false")
+	}
+	c.Put(6, "six")
+	if c.Len() != 3 || len(evicted) != 2 {
+		t.Errorf("after Remove made room, Len() = %d and %d entries were evicted", c.Len(), len(evicted))
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	const goroutines, ops = 8, 2000
+	for p := range Policy(len(policyNames)) {
+		c := New(100, WithPolicy[int, int](p))
+		var wg sync.WaitGroup
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < ops; i++ {
+					k := (i*7 + g) % 300
+					if v, ok := c.Get(k); ok && v != k {
+						t.Errorf("%v: Get(%d) = %d", p, k, v)
+					}
+					c.Put(k, k)
+					if i%10 == 0 {
+						c.Remove(k)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if c.Len() > 100 {
+			t.Errorf("%v: Len() = %d exceeds the capacity of 100", p, c.Len())
+		}
+	}
+}
+
+func TestCache_LFU(t *testing.T) {
+	var evicted []int
+	c := New(3, WithLFU[int, int](), WithOnEvict(func(k, v int) {
+		evicted = append(evicted, k)
+	}))
+	for k := 1; k <= 3; k++ {
+		c.Put(k, k)
+	}
+	// 1 is used three times, 2 twice and 3 once
+	c.Get(1)
+	c.Get(1)
+	c.Get(2)
+	// each new key is then the least used entry, and the next evicted
+	c.Put(4, 4)
+	c.Put(5, 5)
+	c.Put(6, 6)
+	if !slices.Equal(evicted, []int{3, 4, 5}) {
+		t.Errorf("evicted %v, want [3 4 5]", evicted)
+	}
+	for _, k := range []int{1, 2, 6} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("%d was evicted", k)
+		}
+	}
+
+	// removing 6 makes room for 7, which is evicted for 8
+	c.Remove(6)
+	c.Put(7, 7)
+	c.Put(8, 8)
+	if want := []int{3, 4, 5, 7}; !slices.Equal(evicted, want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+}
+
+func TestPolicy_String(t *testing.T) {
+	if TwoQueue.String() != "2Q" || Policy(-1).String() != "Policy(-1)" {
+		t.Errorf("String() = %q, %q", TwoQueue.String(), Policy(-1).String())
+	}
+}
+
+func TestCache_ARC(t *testing.T) {
+	const capacity = 100
+	c := New(capacity, WithARC[int, int]())
+	// a working set of 50 keys, each used twice a round, is interleaved with scans
+	// of keys used once, which flush an LRU cache of the same capacity
+	lru := New[int, int](capacity)
+	hitsARC, hitsLRU := 0, 0
+	scan := 1000
+	for round := 0; round < 50; round++ {
+		for k := 0; k < 50; k++ {
+			if _, ok := c.Get(k); ok {
+				hitsARC++
+			} else {
+				c.Put(k, k)
+			}
+			c.Get(k)
+			if _, ok := lru.Get(k); ok {
+				hitsLRU++
+			} else {
+				lru.Put(k, k)
+			}
+			lru.Get(k)
+		}
+		for i := 0; i < 2*capacity; i++ {
+			scan++
+			c.Put(scan, scan)
+			lru.Put(scan, scan)
+		}
+	}
+	if hitsARC != 49*50 || hitsLRU != 0 {
+		t.Errorf("the working set hit %d times with ARC and %d with LRU, want %d and 0", hitsARC, hitsLRU, 49*50)
+	}
+
+	st := c.Stats()
+	arc := st.ARC
+	if st.Len != capacity || arc.T1+arc.T2 != st.Len || arc.T2 < 50 {
+		t.Errorf("Stats() = %+v", st)
+	}
+	if total := arc.T1 + arc.T2 + arc.B1 + arc.B2; total > 2*capacity || arc.Target < 0 || arc.Target > capacity {
+		t.Errorf("ARCStats = %+v, lists hold %d keys", arc, total)
+	}
+	if st.Hits != uint64(hitsARC+50*50) || st.Evictions == 0 {
+		t.Errorf("Stats() = %+v after %d hits", st, hitsARC)
+	}
+
+	// T1 holds the last 50 keys of the scan and B1 the 50 before; a miss on one
+	// of those says T1 is too small
+	if arc.T1 != 50 || arc.B1 != 50 {
+		t.Fatalf("ARCStats = %+v after the scans, want T1 50 and B1 50", arc)
+	}
+	c.Put(scan-60, 0)
+	if got := c.Stats().ARC; got.Target != arc.Target+1 || got.T2 != arc.T2+1 || got.B1 != arc.B1 {
+		t.Errorf("ARCStats = %+v after a miss on a key in B1, was %+v", got, arc)
+	}
+	if lru.Stats().ARC != (ARCStats{}) {
+		t.Errorf("LRU Stats().ARC = %+v", lru.Stats().ARC)
+	}
+}
+
+func TestCache_TwoQueue(t *testing.T) {
+	const capacity = 100
+	c := New(capacity, WithTwoQueue[int, int]())
+	lru := New[int, int](capacity)
+	put := func(from, to int) {
+		for k := from; k < to; k++ {
+			c.Put(k, k)
+			lru.Put(k, k)
+		}
+	}
+	// 0 to 19 are pushed out of the probationary queue and remembered, so adding
+	// them again protects them
+	put(0, capacity)
+	put(1000, 1020)
+	if _, ok := c.Get(0); ok {
+		t.Fatalf("0 is still cached after 20 keys were added to a full cache")
+	}
+	put(0, 20)
+	// a long scan then only churns the probationary queue
+	put(2000, 2000+10*capacity)
+	hits, hitsLRU := 0, 0
+	for k := 0; k < 20; k++ {
+		if _, ok := c.Get(k); ok {
+			hits++
+		}
+		if _, ok := lru.Get(k); ok {
+			hitsLRU++
+		}
+	}
+	if hits != 20 || hitsLRU != 0 {
+		t.Errorf("after a scan, the working set hit %d times with 2Q and %d with LRU, want 20 and 0", hits, hitsLRU)
+	}
+	if c.Len() != capacity {
+		t.Errorf("Len() = %d, want %d", c.Len(), capacity)
+	}
+}
+
+func TestPolicy_Victim(t *testing.T) {
+	// whatever has happened before, Put evicts the entry victim predicts
+	const capacity = 20
+	r := rand.New(rand.NewPCG(1, 2))
+	for p := range Policy(len(policyNames)) {
+		evicted := -1
+		c := New(capacity, WithPolicy[int, int](p), WithOnEvict(func(k, v int) {
+			evicted = k
+		}))
+		for i := 0; i < 20_000; i++ {
+			k := r.IntN(3 * capacity)
+			switch op := r.IntN(10); {
+			case op < 5:
+				c.Get(k)
+			case op < 9:
+				want := -1
+				if _, ok := c.table.Search(k); !ok && c.Len() == capacity {
+					want = c.policy.victim(k).key
+				}
+				evicted = -1
+				c.Put(k, k)
+				if evicted != want {
+					t.Fatalf("%v: Put(%d) evicted %d, victim predicted %d", p, k, evicted, want)
+				}
+			default:
+				c.Remove(k)
+			}
+		}
+	}
+}
+
+func TestCache_Peek(t *testing.T) {
+	// a cache that is peeked into evicts the same entries as one that is not
+	const capacity = 20
+	for p := range Policy(len(policyNames)) {
+		var evicted [2][]int
+		var caches [2]*Cache[int, int]
+		for i := range caches {
+			caches[i] = New(capacity, WithPolicy[int, int](p), WithOnEvict(func(k, v int) {
+				evicted[i] = append(evicted[i], k)
+			}))
+		}
+		r := rand.New(rand.NewPCG(1, 2))
+		for i := 0; i < 5_000; i++ {
+			k := r.IntN(3 * capacity)
+			get := r.IntN(2) == 0
+			for _, c := range caches {
+				if get {
+					c.Get(k)
+				} else {
+					c.Put(k, k)
+				}
+			}
+			k = r.IntN(3 * capacity)
+			if v, ok := caches[1].Peek(k); ok != caches[1].table.Contains(k) || ok && v != k {
+				t.Fatalf("%v: Peek(%d) = %d, %v", p, k, v, ok)
+			}
+		}
+		if !slices.Equal(evicted[0], evicted[1]) {
+			t.Errorf("%v: peeking changed the entries evicted", p)
+		}
+		if caches[0].Stats() != caches[1].Stats() {
+			t.Errorf("%v: Stats() = %+v with peeks, want %+v", p, caches[1].Stats(), caches[0].Stats())
+		}
+	}
+
+	c := New[string, int](2)
+	c.Put("a", 1)
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Errorf("Peek(a) = %d, %v; want 1, true", v, ok)
+	}
+	if _, ok := c.Peek("b"); ok {
+		t.Errorf("Peek found a key never stored")
+	}
+}
+
+func TestCache_MaxCost(t *testing.T) {
+	var evicted []string
+	c := New(10, WithMaxCost(10, func(k, v string) int64 { return int64(len(v)) }), WithOnEvict(func(k, v string) {
+		evicted = append(evicted, k)
+	}))
+	c.Put("a", "aaaa")
+	c.Put("b", "bbbb")
+	c.Get("a")
+	// fitting 5 bytes under a budget of 10 evicts the least recently used entry
+	c.Put("c", "ccccc")
+	if !slices.Equal(evicted, []string{"b"}) || c.Stats().Cost != 9 {
+		t.Fatalf("evicted %v, cost %d; want [b], 9", evicted, c.Stats().Cost)
+	}
+	// growing a value evicts others as well
+	c.Put("c", "cccccccc")
+	if !slices.Equal(evicted, []string{"b", "a"}) || c.Stats().Cost != 8 {
+		t.Fatalf("evicted %v, cost %d; want [b a], 8", evicted, c.Stats().Cost)
+	}
+	// a value costing more than the budget is rejected, taking the old one with it
+	c.Put("d", "ddddddddddd")
+	c.Put("c", "ccccccccccc")
+	if c.Len() != 0 || c.Stats().Cost != 0 || c.Stats().Rejections != 2 {
+		t.Errorf("after oversized values, Len() = %d and %+v", c.Len(), c.Stats())
+	}
+
+	// whatever the policy, the entries stored stay within budget and their cost is
+	// accounted for
+	const budget = 500
+	for p := range Policy(len(policyNames)) {
+		c := New(100, WithPolicy[int, int](p), WithMaxCost(budget, func(k, v int) int64 { return int64(v) }))
+		r := rand.New(rand.NewPCG(1, 2))
+		for i := 0; i < 20_000; i++ {
+			k := r.IntN(300)
+			switch op := r.IntN(10); {
+			case op < 5:
+				c.Get(k)
+			case op < 9:
+				c.Put(k, r.IntN(60))
+			default:
+				c.Remove(k)
+			}
+			var total int64
+			for _, n := range c.table.All() {
+				total += int64(n.value)
+			}
+			stats := c.Stats()
+			arc := stats.ARC
+			if stats.Cost != total || total > budget || stats.Len > 100 || arc.T1+arc.T2+arc.B1+arc.B2 > 200 {
+				t.Fatalf("%v: %+v with entries costing %d", p, stats, total)
+			}
+		}
+	}
+}
+
+func TestCache_TinyLFU(t *testing.T) {
+	const capacity = 4
+	c := New(capacity, WithTinyLFU[int, int]())
+	// a fixed seed keeps the keys from sharing counters by chance
+	c.sketch.hash = hashers.Int[int]{Seed: 1}.Hash
+	for k := 0; k < capacity; k++ {
+		c.Put(k, k)
+		c.Get(k)
+	}
+	// a key seen once is not worth evicting an entry used twice for
+	c.Put(100, 100)
+	if _, ok := c.Get(100); ok || c.Stats().Rejections != 1 {
+		t.Errorf("a key used once was admitted to a full cache, Stats() = %+v", c.Stats())
+	}
+	// but once it has been put more often than the victim was used, it is admitted
+	for range 3 {
+		c.Put(101, 101)
+	}
+	if _, ok := c.Get(101); !ok || c.Stats().Rejections != 3 {
+		t.Errorf("a key put three times was not admitted on the third, Stats() = %+v", c.Stats())
+	}
+}
+
+func TestCache_TinyLFUHitRate(t *testing.T) {
+	const capacity, requests = 500, 200_000
+	hitRate := func(opts ...Option[int, int]) float64 {
+		c := New(capacity, opts...)
+		zipf := rand.NewZipf(rand.New(rand.NewPCG(3, 4)), 1.1, 1, 100_000)
+		for i := 0; i < requests; i++ {
+			k := int(zipf.Uint64())
+			if _, ok := c.Get(k); !ok {
+				c.Put(k, k)
+			}
+		}
+		st := c.Stats()
+		return float64(st.Hits) / float64(st.Hits+st.Misses)
+	}
+	plain, filtered := hitRate(), hitRate(WithTinyLFU[int, int]())
+	if filtered < plain+0.02 {
+		t.Errorf("LRU hit rate %.3f with TinyLFU, %.3f without", filtered, plain)
+	}
+}
+
+func TestCache_MaxMemory(t *testing.T) {
+	type record struct {
+		name string
+		tags []string
+		next *record
+	}
+	r := &record{name: "abcd", tags: []string{"xy", "z"}}
+	// the name, the array of tags and their bytes
+	own := int64(4) + 2*int64(unsafe.Sizeof("")) + 3
+	if got := footprint(reflect.ValueOf(r), 0); got != int64(unsafe.Sizeof(*r))+own {
+		t.Errorf("footprint of a record = %d, want %d", got, int64(unsafe.Sizeof(*r))+own)
+	}
+	// a cycle is followed as deep as the limit
+	r.next = r
+	if got := footprint(reflect.ValueOf(*r), 0); got != own+maxFootprintDepth*(int64(unsafe.Sizeof(*r))+own) {
+		t.Errorf("footprint of a cyclic record = %d, want %d", got, own+maxFootprintDepth*(int64(unsafe.Sizeof(*r))+own))
+	}
+
+	c := New(1000, WithMaxMemory[int, []byte](1<<20))
+	for k := 0; k < 100; k++ {
+		c.Put(k, make([]byte, 64<<10))
+	}
+	// sixteen values of 64 KiB would take the whole megabyte, leaving no room for
+	// the overhead of their entries
+	if stats := c.Stats(); stats.Len != 15 || stats.Cost > 1<<20 {
+		t.Errorf("a 1 MiB cache holds %d values of 64 KiB, costing %d", stats.Len, stats.Cost)
+	}
+}
+
+func TestLoadingCache(t *testing.T) {
+	errNotFound := errors.New("not found")
+	var loads atomic.Int32
+	release := make(chan struct{})
+	c := NewLoadingCache(10, func(ctx context.Context, k int) (string, error) {
+		loads.Add(1)
+		switch {
+		case k < 0:
+			return "", errNotFound
+		case k == 0:
+			<-release
+		case k == 1:
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return strconv.Itoa(k), nil
+	}, WithErrorTTLFunc[int, string](func(k int, err error) time.Duration {
+		if k == -1 {
+			return time.Hour
+		}
+		return time.Millisecond
+	}))
+
+	// concurrent misses of a key share one load
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Get(context.Background(), 0); v != "0" || err != nil {
+				t.Errorf("Get(0) = %q, %v", v, err)
+			}
+		}()
+	}
+	for c.Stats().Misses < 8 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	if v, err := c.Get(context.Background(), 0); v != "0" || err != nil || loads.Load() != 1 {
+		t.Fatalf("Get(0) = %q, %v after %d loads, want one load", v, err, loads.Load())
+	}
+
+	// an error is remembered for as long as WithErrorTTLFunc says
+	for range 3 {
+		if _, err := c.Get(context.Background(), -1); err != errNotFound {
+			t.Fatalf("Get(-1) = %v, want errNotFound", err)
+		}
+		c.Get(context.Background(), -2)
+		time.Sleep(2 * time.Millisecond)
+	}
+	if n := loads.Load(); n != 5 {
+		t.Errorf("%d loads, want one for the remembered error and three for the other", n-1)
+	}
+	c.Remove(-1)
+	c.Get(context.Background(), -1)
+	if n := loads.Load(); n != 6 {
+		t.Errorf("Remove did not forget the error of -1")
+	}
+
+	// a load failing because its caller gave up is neither remembered nor shared
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Get(ctx, 1); err != context.Canceled {
+		t.Errorf("Get with a canceled context = %v", err)
+	}
+	c.Put(1, "one")
+	if v, err := c.Get(ctx, 1); v != "one" || err != nil {
+		t.Errorf("Get(1) = %q, %v after Put", v, err)
+	}
+}
+
+func TestLoadingCache_WriteDuringLoad(t *testing.T) {
+	release := make(chan struct{})
+	c := NewLoadingCache(10, func(ctx context.Context, k int) (string, error) {
+		<-release
+		return "loaded", nil
+	})
+	// a Put or Remove made while a key loads is not undone by the load
+	for _, tt := range []struct {
+		write  func()
+		want   string
+		stored bool
+	}{
+		{func() { c.Put(1, "newer") }, "newer", true},
+		{func() { c.Remove(1) }, "", false},
+	} {
+		c.Remove(1)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if v, err := c.Get(context.Background(), 1); v != "loaded" || err != nil {
+				t.Errorf("Get(1) = %q, %v; want the value loaded", v, err)
+			}
+		}()
+		for !c.loadsInProgress() {
+			runtime.Gosched()
+		}
+		tt.write()
+		release <- struct{}{}
+		<-done
+		if v, ok := c.Peek(1); v != tt.want || ok != tt.stored {
+			t.Errorf("Peek(1) = %q, %v after the load, want %q, %v as written during it", v, ok, tt.want, tt.stored)
+		}
+	}
+}
+
+func TestLoadingCache_StaleWhileRevalidate(t *testing.T) {
+	var clock atomic.Int64
+	start := time.Now()
+	timeNow = func() time.Time { return start.Add(time.Duration(clock.Load())) }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	var loads atomic.Int32
+	slow := make(chan struct{})
+	loaded := make(chan struct{}, 1)
+	c := NewLoadingCache(10, func(ctx context.Context, k int) (int, error) {
+		n := loads.Add(1)
+		defer func() { loaded <- struct{}{} }()
+		if n == 2 {
+			// the first refresh is slow, and the second fails
+			<-slow
+		} else if n == 3 {
+			return 0, errors.New("unavailable")
+		}
+		return int(n), nil
+	}, WithStaleWhileRevalidate[int, int](time.Minute))
+
+	if v, err := c.Get(context.Background(), 0); v != 1 || err != nil {
+		t.Fatalf("Get(0) = %d, %v; want 1, nil", v, err)
+	}
+	<-loaded
+	clock.Add(int64(time.Minute))
+	// a stale value is served while the slow loader refreshes it, and once only
+	ctx, cancel := context.WithCancel(context.Background())
+	for range 3 {
+		if v, err := c.Get(ctx, 0); v != 1 || err != nil {
+			t.Fatalf("Get(0) = %d, %v while refreshing, want the stale 1", v, err)
+		}
+	}
+	// the refresh outlives the context of the Get that started it
+	cancel()
+	close(slow)
+	<-loaded
+	for c.loadsInProgress() {
+		runtime.Gosched()
+	}
+	if v, _ := c.Get(context.Background(), 0); v != 2 || loads.Load() != 2 {
+		t.Fatalf("Get(0) = %d after %d loads, want the refreshed 2 after 2", v, loads.Load())
+	}
+
+	// a failed refresh keeps the stale value until the next one
+	clock.Add(int64(time.Minute))
+	c.Get(context.Background(), 0)
+	<-loaded
+	for c.loadsInProgress() {
+		runtime.Gosched()
+	}
+	if v, _ := c.Get(context.Background(), 0); v != 2 || loads.Load() != 3 {
+		t.Fatalf("Get(0) = %d after a failed refresh, want the stale 2", v)
+	}
+	clock.Add(int64(time.Minute))
+	c.Get(context.Background(), 0)
+	<-loaded
+	for c.loadsInProgress() {
+		runtime.Gosched()
+	}
+	if v, _ := c.Get(context.Background(), 0); v != 4 {
+		t.Errorf("Get(0) = %d after the next refresh, want 4", v)
+	}
+}
+
+func TestLoadingCache_RefreshWrites(t *testing.T) {
+	var clock atomic.Int64
+	start := time.Now()
+	timeNow = func() time.Time { return start.Add(time.Duration(clock.Load())) }
+	t.Cleanup(func() { timeNow = time.Now })
+	waitLoads := func(c *LoadingCache[int, string]) {
+		for c.loadsInProgress() {
+			runtime.Gosched()
+		}
+	}
+
+	// a Put made during a refresh is not replaced by the refreshed value
+	release := make(chan struct{})
+	c := NewLoadingCache(10, func(ctx context.Context, k int) (string, error) {
+		<-release
+		return "old-refresh", nil
+	}, WithStaleWhileRevalidate[int, string](time.Minute))
+	c.Put(1, "old")
+	clock.Add(int64(time.Minute))
+	if v, _ := c.Get(context.Background(), 1); v != "old" {
+		t.Fatalf("Get(1) = %q, want the stale old", v)
+	}
+	c.Put(1, "newer")
+	close(release)
+	waitLoads(c)
+	if v, _ := c.Peek(1); v != "newer" {
+		t.Errorf("Peek(1) = %q after the refresh, want the newer value Put during it", v)
+	}
+
+	// a successful refresh forgets the error of a failed one, which would otherwise
+	// be returned once the value is evicted
+	var loads atomic.Int32
+	c = NewLoadingCache(1, func(ctx context.Context, k int) (string, error) {
+		n := loads.Add(1)
+		if n == 2 {
+			return "", errors.New("unavailable")
+		}
+		return strconv.Itoa(int(n)), nil
+	}, WithStaleWhileRevalidate[int, string](time.Minute), WithErrorTTL[int, string](time.Hour))
+	c.Get(context.Background(), 0)
+	for range 2 {
+		clock.Add(int64(time.Minute))
+		c.Get(context.Background(), 0)
+		waitLoads(c)
+	}
+	if v, _ := c.Peek(0); v != "3" {
+		t.Fatalf("Peek(0) = %q after a failed and a successful refresh, want 3", v)
+	}
+	c.Put(1, "evicts 0")
+	if v, err := c.Get(context.Background(), 0); v != "4" || err != nil {
+		t.Errorf("Get(0) = %q, %v after eviction, want a fresh load of 4", v, err)
+	}
+}
+
+// loadsInProgress reports whether a load of lc has yet to finish.
+func (lc *LoadingCache[K, V]) loadsInProgress() bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.loads.Len() > 0
+}
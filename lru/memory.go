@@ -0,0 +1,93 @@
+package lru
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// maxFootprintDepth is the number of pointers and interfaces footprint follows from
+// a key or value, which also keeps it from looping on cyclic data.
+const maxFootprintDepth = 4
+
+// WithMaxMemory bounds the Cache to about bytes of memory for its entries, so it
+// can be sized in megabytes, like memcached, rather than in entries. It is
+// WithMaxCost with the cost of an entry estimated, when it is stored, as the memory
+// the Cache uses to hold it plus the bytes its key and value refer to: the contents
+// of strings, slices and maps, and the targets of pointers and interfaces, followed
+// a few levels deep. Memory shared between entries is counted for each of them,
+// and memory used by the policy to remember evicted keys is not counted. The
+// estimate relies on reflection; WithMaxCost with a cost written for the types at
+// hand is faster and can be more accurate.
+func WithMaxMemory[K comparable, V any](bytes int64) Option[K, V] {
+	// a node, and a slot holding the key, the hash and a pointer to the node in the
+	// table, which is about half full on average
+	overhead := int64(unsafe.Sizeof(node[K, V]{})) + 2*int64(unsafe.Sizeof(struct {
+		key  K
+		hash uint64
+		node *node[K, V]
+	}{}))
+	return WithMaxCost(bytes, func(key K, value V) int64 {
+		return overhead + footprint(reflect.ValueOf(&key).Elem(), 0) + footprint(reflect.ValueOf(&value).Elem(), 0)
+	})
+}
+
+// footprint estimates the bytes v refers to outside of its own memory, reached
+// through depth pointers and interfaces so far.
+func footprint(v reflect.Value, depth int) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Slice:
+		if v.IsNil() {
+			return 0
+		}
+		n := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		if !flat(v.Type().Elem()) {
+			for i := range v.Len() {
+				n += footprint(v.Index(i), depth)
+			}
+		}
+		return n
+	case reflect.Array:
+		var n int64
+		if !flat(v.Type().Elem()) {
+			for i := range v.Len() {
+				n += footprint(v.Index(i), depth)
+			}
+		}
+		return n
+	case reflect.Struct:
+		var n int64
+		for i := range v.NumField() {
+			n += footprint(v.Field(i), depth)
+		}
+		return n
+	case reflect.Map:
+		// the contents of keys and elements are not visited
+		return int64(v.Len()) * int64(v.Type().Key().Size()+v.Type().Elem().Size())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() || depth == maxFootprintDepth {
+			return 0
+		}
+		elem := v.Elem()
+		return int64(elem.Type().Size()) + footprint(elem, depth+1)
+	}
+	return 0
+}
+
+// flat reports whether values of t refer to no memory outside of their own.
+func flat(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Pointer, reflect.Interface:
+		return false
+	case reflect.Array:
+		return flat(t.Elem())
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if !flat(t.Field(i).Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
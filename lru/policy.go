@@ -0,0 +1,100 @@
+package lru
+
+import "strconv"
+
+// A Policy is a rule for choosing the entry a Cache evicts when it is full,
+// selected with WithPolicy. Every policy supports every method of Cache; they
+// differ in which entries they predict will be used again.
+type Policy int
+
+const (
+	// LRU evicts the least recently used entry. It is the default.
+	LRU Policy = iota
+	// LFU evicts the least frequently used entry; see WithLFU.
+	LFU
+	// ARC adapts between recency and frequency; see WithARC.
+	ARC
+	// TwoQueue protects entries used again from scans; see WithTwoQueue.
+	TwoQueue
+)
+
+var policyNames = [...]string{"LRU", "LFU", "ARC", "2Q"}
+
+func (p Policy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return "Policy(" + strconv.Itoa(int(p)) + ")"
+	}
+	return policyNames[p]
+}
+
+// A policy tracks the use of the entries of a Cache to choose which one to evict.
+// The Cache calls its methods with its lock held.
+type policy[K comparable, V any] interface {
+	// hit records a use of n, an entry of the cache.
+	hit(n *node[K, V])
+	// add records n, an entry new to the cache. If full is set, the cache has no
+	// room for n, and add returns the entry to evict for it, no longer tracked.
+	add(n *node[K, V], full bool) (victim *node[K, V])
+	// victim returns the entry add would evict for a new entry of key while the
+	// cache is full, without modifying anything.
+	victim(key K) *node[K, V]
+	// evict returns the entry to evict to make room, by cost, for a new entry of
+	// key, no longer tracked, as add would while the cache is full.
+	evict(key K) *node[K, V]
+	// remove stops tracking n, an entry deleted from the cache.
+	remove(n *node[K, V])
+}
+
+// newPolicy returns an empty policy implementing p for a cache of the given
+// capacity.
+func newPolicy[K comparable, V any](p Policy, capacity int) policy[K, V] {
+	switch p {
+	case LFU:
+		return newLFU[K, V]()
+	case ARC:
+		return newARC[K, V](capacity)
+	case TwoQueue:
+		return newTwoQueue[K, V](capacity)
+	default:
+		return newLRU[K, V]()
+	}
+}
+
+// lruPolicy keeps the entries in order of use, evicting from the back.
+type lruPolicy[K comparable, V any] struct {
+	// recent holds the entries, most recently used first.
+	recent list[K, V]
+}
+
+func newLRU[K comparable, V any]() *lruPolicy[K, V] {
+	p := &lruPolicy[K, V]{}
+	p.recent.init()
+	return p
+}
+
+func (p *lruPolicy[K, V]) hit(n *node[K, V]) {
+	p.recent.moveToFront(n)
+}
+
+func (p *lruPolicy[K, V]) add(n *node[K, V], full bool) *node[K, V] {
+	var victim *node[K, V]
+	if full {
+		victim = p.evict(n.key)
+	}
+	p.recent.pushFront(n)
+	return victim
+}
+
+func (p *lruPolicy[K, V]) victim(K) *node[K, V] {
+	return p.recent.back()
+}
+
+func (p *lruPolicy[K, V]) evict(K) *node[K, V] {
+	victim := p.recent.back()
+	p.recent.remove(victim)
+	return victim
+}
+
+func (p *lruPolicy[K, V]) remove(n *node[K, V]) {
+	p.recent.remove(n)
+}
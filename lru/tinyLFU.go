@@ -0,0 +1,92 @@
+package lru
+
+import "github.com/jkittell/hashtable/hashers"
+
+// WithTinyLFU makes the Cache admit a new key, once it is full, only if the key has
+// been used more often recently than the entry the policy would evict for it, as
+// estimated by the TinyLFU filter of Einziger, Friedman and Manes. Keys used once,
+// the bulk of the traffic under the skewed distributions typical of caches, are
+// then turned away instead of evicting entries that are used again. This raises
+// the hit rate of LRU the most; LFU and ARC already weigh frequency and gain
+// little. Put does not store a key turned away; Stats counts them as Rejections.
+// The filter counts the hits of Get and the calls of Put for every key, stored or
+// not, in a count-min sketch of sixteen one-byte counters per cached entry, and
+// halves the counts periodically so that past popularity fades.
+func WithTinyLFU[K comparable, V any]() Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.tinyLFU = true
+	}
+}
+
+const (
+	// sketchDepth is the number of rows of counters of a sketch, each indexed by
+	// a different hash of the key.
+	sketchDepth = 4
+	// sketchMax is the count at which counters saturate, as with the four-bit
+	// counters of TinyLFU.
+	sketchMax = 15
+	// sketchSample is the number of uses, per entry of the cache, after which the
+	// counts of a sketch are halved.
+	sketchSample = 10
+)
+
+// A sketch is a count-min sketch estimating how often keys have been used: every
+// use increments a counter in each row, and the estimate for a key is the lowest of
+// its counters, which keys colliding with it can only have raised.
+type sketch[K comparable] struct {
+	hash func(K) uint64
+	// counters holds sketchDepth rows of mask+1 counters each.
+	counters []uint8
+	mask     uint64
+	// uses counts the uses since the counts were last halved, which happens once
+	// it reaches resetAt.
+	uses, resetAt int
+}
+
+func newSketch[K comparable](capacity int) *sketch[K] {
+	// rows several times wider than the cache keep the keys that matter, those
+	// used often enough to be cached, from sharing counters
+	width := 16
+	for width < 4*capacity {
+		width <<= 1
+	}
+	return &sketch[K]{
+		hash:     hashers.NewComparable[K]().Hash,
+		counters: make([]uint8, sketchDepth*width),
+		mask:     uint64(width - 1),
+		resetAt:  sketchSample * capacity,
+	}
+}
+
+// index returns the index of the counter of hash h in row i. The hashes of the
+// rows are derived from h by double hashing.
+func (s *sketch[K]) index(h uint64, i int) int {
+	return i*int(s.mask+1) + int((h+uint64(i)*(h>>32|1))&s.mask)
+}
+
+// add records a use of key.
+func (s *sketch[K]) add(key K) {
+	h := s.hash(key)
+	for i := range sketchDepth {
+		if c := &s.counters[s.index(h, i)]; *c < sketchMax {
+			*c++
+		}
+	}
+	if s.uses++; s.uses >= s.resetAt {
+		for i := range s.counters {
+			s.counters[i] >>= 1
+		}
+		s.uses /= 2
+	}
+}
+
+// estimate returns the estimated number of uses of key since the counts were last
+// halved, plus half of those before.
+func (s *sketch[K]) estimate(key K) uint8 {
+	h := s.hash(key)
+	lowest := uint8(sketchMax)
+	for i := range sketchDepth {
+		lowest = min(lowest, s.counters[s.index(h, i)])
+	}
+	return lowest
+}
@@ -0,0 +1,106 @@
+package lru
+
+import "github.com/jkittell/hashtable"
+
+// WithTwoQueue makes the Cache evict entries by the 2Q policy of Johnson and
+// Shasha. New keys enter a probationary FIFO queue holding a quarter of the
+// capacity, where further uses do not move them, and the keys that queue evicts
+// are remembered, without their values, for as many again as half the capacity. A
+// miss on a remembered key shows it is used again, so it is stored in a protected
+// LRU list, which only such keys enter. A scan of keys used once therefore passes
+// through the probationary queue without evicting the protected entries, which
+// suits workloads mixing scans with a working set better than LRU. It is shorthand
+// for WithPolicy(TwoQueue).
+func WithTwoQueue[K comparable, V any]() Option[K, V] {
+	return WithPolicy[K, V](TwoQueue)
+}
+
+// twoQueuePolicy implements 2Q, with the lists named as in the paper.
+type twoQueuePolicy[K comparable, V any] struct {
+	// in is A1in, the probationary FIFO queue of new entries, newest first, which
+	// is evicted from while it holds more than inLen entries.
+	in    list[K, V]
+	inLen int
+	// out is A1out, the keys recently evicted from in, newest first, holding up to
+	// outLen nodes without values, which ghosts indexes by key.
+	out    list[K, V]
+	outLen int
+	ghosts *hashtable.HashTable[K, *node[K, V]]
+	// main is Am, the protected LRU list, most recently used first.
+	main list[K, V]
+}
+
+func newTwoQueue[K comparable, V any](capacity int) *twoQueuePolicy[K, V] {
+	p := &twoQueuePolicy[K, V]{
+		inLen:  max(capacity/4, 1),
+		outLen: max(capacity/2, 1),
+		ghosts: hashtable.New[K, *node[K, V]](max(capacity/2, 1)),
+	}
+	p.in.init()
+	p.out.init()
+	p.main.init()
+	return p
+}
+
+func (p *twoQueuePolicy[K, V]) hit(n *node[K, V]) {
+	// uses of a probationary entry are likely correlated with the one that added
+	// it, so they do not count
+	if n.list == &p.main {
+		p.main.moveToFront(n)
+	}
+}
+
+func (p *twoQueuePolicy[K, V]) add(n *node[K, V], full bool) *node[K, V] {
+	// a remembered key is looked up before the eviction below remembers another
+	g, remembered := p.ghosts.Search(n.key)
+	if remembered {
+		p.forget(g)
+	}
+	var victim *node[K, V]
+	if full {
+		victim = p.evict(n.key)
+	}
+	if remembered {
+		p.main.pushFront(n)
+	} else {
+		p.in.pushFront(n)
+	}
+	return victim
+}
+
+func (p *twoQueuePolicy[K, V]) victim(K) *node[K, V] {
+	if p.in.len > p.inLen || p.main.len == 0 {
+		return p.in.back()
+	}
+	return p.main.back()
+}
+
+func (p *twoQueuePolicy[K, V]) evict(key K) *node[K, V] {
+	victim := p.victim(key)
+	if victim.list == &p.in {
+		p.remember(victim.key)
+	}
+	victim.list.remove(victim)
+	return victim
+}
+
+// remember records key, evicted from in, in out, forgetting the oldest key of out
+// if it is full.
+func (p *twoQueuePolicy[K, V]) remember(key K) {
+	g := &node[K, V]{key: key}
+	p.out.pushFront(g)
+	p.ghosts.Insert(key, g)
+	if p.out.len > p.outLen {
+		p.forget(p.out.back())
+	}
+}
+
+// forget drops g, a remembered key.
+func (p *twoQueuePolicy[K, V]) forget(g *node[K, V]) {
+	p.out.remove(g)
+	p.ghosts.Delete(g.key)
+}
+
+func (p *twoQueuePolicy[K, V]) remove(n *node[K, V]) {
+	n.list.remove(n)
+}
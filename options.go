@@ -0,0 +1,218 @@
+package hashtable
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"reflect"
+)
+
+// An Option configures a HashTable created by New.
+type Option[K any, V any] func(*HashTable[K, V])
+
+// WithHasher makes the HashTable hash its keys with h instead of the default Hasher.
+func WithHasher[K any, V any](h Hasher[K]) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.hasher = h
+	}
+}
+
+// WithKeyNormalizer makes the HashTable pass every key through normalize before
+// hashing, comparing or storing it, so keys with the same normal form are the same
+// key. For example, strings.ToLower gives case-insensitive string keys. Stored keys,
+// as returned by Keys and the iterators, are in normal form. normalize must be
+// idempotent.
+func WithKeyNormalizer[K any, V any](normalize func(K) K) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.normalize = normalize
+	}
+}
+
+// WithEqual makes the HashTable compare keys with equal instead of ==, for keys
+// whose natural equality differs from ==, such as structs that may contain NaN.
+// Keys that are equal must have the same hash, which the default Hasher only
+// guarantees for keys that are ==, so WithEqual is usually paired with WithHasher.
+func WithEqual[K any, V any](equal func(a, b K) bool) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.layout.equal = equal
+	}
+}
+
+// WithConstantTimeEqual makes the HashTable compare string or []byte keys in time
+// that depends only on their length, not on how many leading bytes match, so bucket
+// scans cannot leak a secret key, such as an API token, one byte at a time. The
+// hash of a key is computed in full either way; pair this with a keyed Hasher such
+// as hashers.SipHashString so that the bucket a key lands in reveals nothing either.
+func WithConstantTimeEqual[K ~string | ~[]byte, V any]() Option[K, V] {
+	return WithEqual[K, V](constantTimeEqual[K])
+}
+
+// constantTimeEqual reports whether a and b are equal, examining every byte when
+// their lengths match.
+func constantTimeEqual[K ~string | ~[]byte](a, b K) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var diff byte
+	for i := 0; i < len(a); i++ {
+		diff |= a[i] ^ b[i]
+	}
+	return subtle.ConstantTimeByteEq(diff, 0) == 1
+}
+
+// DefaultMaxLoadFactor is the average number of entries per bucket above which a
+// HashTable doubles its number of buckets, unless set otherwise with
+// WithMaxLoadFactor.
+const DefaultMaxLoadFactor = 2.0
+
+// WithMaxLoadFactor makes the HashTable double its number of buckets and rehash
+// every entry whenever the average number of entries per bucket exceeds f. A
+// larger f saves memory at the expense of longer bucket scans. An f of 0 keeps
+// the number of buckets fixed at the number the table was created with.
+func WithMaxLoadFactor[K any, V any](f float64) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.layout.maxLoad = f
+	}
+}
+
+// WithIncrementalRehash makes the HashTable spread the work of growing over later
+// operations, in the style of Redis: when the load factor is exceeded, new buckets
+// are allocated and each subsequent lookup or modification migrates a few of the
+// old buckets, along with the bucket of the key it touches, until none are left.
+// No single insert pays for rehashing the whole table. Operations that visit every
+// entry, such as Keys or All, complete any migration in progress first.
+func WithIncrementalRehash[K any, V any]() Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.layout.incremental = true
+	}
+}
+
+// WithTreeBuckets makes the HashTable index any bucket holding more than eight
+// entries with a balanced tree ordered by hash, then by key, as java.util.HashMap
+// does. Lookups in such a bucket take time logarithmic rather than linear in its
+// length, which bounds the damage a poor Hasher or keys chosen to collide can do.
+// Buckets are lists again once they shrink to six entries. The keys must compare
+// equal under cmp.Compare exactly when they are equal keys of the table. It only
+// applies to the default chained storage.
+func WithTreeBuckets[K cmp.Ordered, V any]() Option[K, V] {
+	return WithTreeBucketsFunc[K, V](cmp.Compare[K])
+}
+
+// WithTreeBucketsFunc is like WithTreeBuckets but orders keys with compare, which
+// must return zero exactly when the keys are equal.
+func WithTreeBucketsFunc[K any, V any](compare func(a, b K) int) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.layout.compare = compare
+	}
+}
+
+// WithSortedBuckets makes the HashTable keep the entries of each bucket sorted by
+// hash, so a lookup binary-searches its bucket instead of scanning it. Unlike
+// WithTreeBuckets it needs no ordering of the keys and no memory beyond the
+// entries, at the cost of inserts and deletes that shift the entries after them.
+// It pays off when buckets are long, as with a large WithMaxLoadFactor, and
+// replaces WithTreeBuckets if both are given. It only applies to the default
+// chained storage.
+func WithSortedBuckets[K any, V any]() Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.layout.sorted = true
+	}
+}
+
+// WithBackend makes the HashTable keep its entries in the storage engine b, so the
+// engine can be chosen per workload, or from configuration, without changing any
+// call site. Options such as WithRobinHood are shorthands for WithBackend. An
+// unknown Backend selects Chaining.
+func WithBackend[K any, V any](b Backend) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.layout.backend = b
+	}
+}
+
+// WithOpenAddressing makes the HashTable store its entries in a single flat array
+// with linear probing instead of in a slice per bucket. Lookups then read adjacent
+// memory instead of following a pointer to a bucket, and inserts allocate nothing
+// until the table grows. The table grows by doubling whenever it becomes three
+// quarters full, so WithMaxLoadFactor does not apply, and the number of buckets
+// given to the constructor is the initial number of slots.
+func WithOpenAddressing[K any, V any]() Option[K, V] {
+	return WithBackend[K, V](OpenAddressing)
+}
+
+// WithRobinHood makes the HashTable store its entries in a single flat array with
+// Robin Hood probing, which keeps every key close to the slot its hash selects:
+// when two keys compete for a slot, the one further from its own slot keeps it.
+// Probe lengths vary little, so read-heavy tables see consistent lookup times even
+// around hot collisions, and deletion shifts later entries back instead of leaving
+// tombstones. The table grows when it becomes seven eighths full; as with
+// WithOpenAddressing, WithMaxLoadFactor does not apply.
+func WithRobinHood[K any, V any]() Option[K, V] {
+	return WithBackend[K, V](RobinHood)
+}
+
+// WithSwissTable makes the HashTable store its entries like Abseil's swiss tables
+// and Go's built-in maps: slots are grouped in sixteens, each with a control byte
+// holding seven bits of the key's hash, and a lookup compares those bytes a word
+// at a time before comparing any key. Most lookups touch one group and compare a
+// single key, even in a nearly full table. The table grows when it becomes seven
+// eighths full; as with WithOpenAddressing, WithMaxLoadFactor does not apply.
+func WithSwissTable[K any, V any]() Option[K, V] {
+	return WithBackend[K, V](SwissTable)
+}
+
+// WithCuckoo makes the HashTable use cuckoo hashing: every key has one slot in each
+// of two tables, and inserting into two taken slots evicts an occupant to its other
+// slot. A lookup reads at most two slots and a small stash, however the keys are
+// laid out, at the cost of keeping the table at most half full and of inserts that
+// occasionally rebuild the table under new seeds. As with WithOpenAddressing,
+// WithMaxLoadFactor does not apply.
+func WithCuckoo[K any, V any]() Option[K, V] {
+	return WithBackend[K, V](Cuckoo)
+}
+
+// WithHopscotch makes the HashTable use hopscotch hashing: open addressing in which
+// every entry stays within 32 slots of the slot its hash selects, and each slot
+// records which of the following slots hold its entries. A lookup reads only those,
+// which are close together in memory, so lookups stay fast up to the maximum load
+// of seven eighths. As with WithOpenAddressing, WithMaxLoadFactor does not apply.
+func WithHopscotch[K any, V any]() Option[K, V] {
+	return WithBackend[K, V](Hopscotch)
+}
+
+// WithFreelist makes the HashTable recycle the memory of its buckets: the backing
+// array of a bucket emptied by a deletion, or outgrown by an insert, goes onto a
+// freelist, from which buckets that need to grow take arrays before allocating. In
+// delete-heavy workloads where inserts land in other buckets than deletes, the
+// table then reaches a steady state with little or no allocation. The freelist
+// holds at most as many free entry slots as the table holds entries, and Compact
+// and Shrink empty it. It only applies to the default chained storage.
+func WithFreelist[K any, V any]() Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.layout.recycle = true
+	}
+}
+
+// WithPointerFreeStorage makes the HashTable store its entries as FlatChaining
+// does, in one array linked by index rather than a slice per bucket, when neither K
+// nor V contains pointers. The garbage collector then has nothing to scan in the
+// table, which for a table of millions of entries saves milliseconds per collection.
+// For other key and value types it has no effect. Buckets grow under
+// WithMaxLoadFactor as with the default storage.
+func WithPointerFreeStorage[K any, V any]() Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		if pointerFree(reflect.TypeFor[K]()) && pointerFree(reflect.TypeFor[V]()) {
+			ht.layout.backend = FlatChaining
+		}
+	}
+}
+
+// WithSmallTable makes the HashTable keep its first 16 entries in a plain array that
+// lookups search by key equality, without hashing keys or allocating buckets. Once
+// a 17th key is added, every key is hashed into the storage the other options
+// select, created with the number of buckets given to New, and the table stays
+// there even if it shrinks again. Many tables never grow past a handful of
+// entries, and comparing a few keys is cheaper than hashing one.
+func WithSmallTable[K any, V any]() Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.small = true
+	}
+}
@@ -0,0 +1,326 @@
+package hashtable
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jkittell/hashtable/hashers"
+)
+
+func TestWithKeyNormalizer(t *testing.T) {
+	ht := New(8, WithKeyNormalizer[string, string](strings.ToLower))
+	ht.Insert("Content-Type", "text/plain")
+	ht.Insert("content-type", "application/json")
+
+	if ht.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", ht.Len())
+	}
+	if v, ok := ht.Search("CONTENT-TYPE"); !ok || v != "application/json" {
+		t.Fatalf("Search(CONTENT-TYPE) = %q, %v, want application/json, true", v, ok)
+	}
+	if k, _, _ := ht.Find(func(string, string) bool { return true }); k != "content-type" {
+		t.Fatalf("stored key = %q, want content-type", k)
+	}
+	if _, ok := ht.Delete("Content-TYPE"); !ok || ht.Len() != 0 {
+		t.Fatal("Delete with a differently cased key did not remove the entry")
+	}
+
+	m := NewFromMap(map[string]int{"A": 1, "a": 2}, WithKeyNormalizer[string, int](strings.ToLower))
+	if m.Len() != 1 {
+		t.Fatalf("NewFromMap kept %d keys that normalize to the same key, want 1", m.Len())
+	}
+}
+
+func TestWithEqual(t *testing.T) {
+	type reading struct {
+		Sensor string
+		Value  float64
+	}
+	sameReading := func(a, b reading) bool {
+		return a.Sensor == b.Sensor &&
+			(a.Value == b.Value || math.IsNaN(a.Value) && math.IsNaN(b.Value))
+	}
+	ht := New(8,
+		WithHasher[reading, int](hashers.Auto[reading]()),
+		WithEqual[reading, int](sameReading))
+
+	ht.Insert(reading{"a", math.NaN()}, 1)
+	ht.Insert(reading{"a", math.NaN()}, 2)
+	if ht.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", ht.Len())
+	}
+	if v, ok := ht.Search(reading{"a", math.NaN()}); !ok || v != 2 {
+		t.Fatalf("Search(a, NaN) = %d, %v, want 2, true", v, ok)
+	}
+	if ht.Contains(reading{"b", math.NaN()}) {
+		t.Fatal("Contains(b, NaN) = true for a key that was never inserted")
+	}
+}
+
+func TestWithConstantTimeEqual(t *testing.T) {
+	ht := New(8,
+		WithHasher[string, string](hashers.NewSipHashString()),
+		WithConstantTimeEqual[string, string]())
+	ht.Insert("tok_3f9a", "alice")
+
+	if v, ok := ht.Search("tok_3f9a"); !ok || v != "alice" {
+		t.Fatalf("Search(tok_3f9a) = %q, %v, want alice, true", v, ok)
+	}
+	for _, key := range []string{"tok_3f9b", "tok_3f9", "tok_3f9aa", ""} {
+		if ht.Contains(key) {
+			t.Errorf("Contains(%q) = true for a key that was never inserted", key)
+		}
+	}
+
+	b := NewBytes(8, WithConstantTimeEqual[[]byte, int]())
+	b.Insert([]byte("secret"), 1)
+	if !b.Contains([]byte("secret")) || b.Contains([]byte("secreT")) {
+		t.Fatal("constant-time []byte comparison does not match bytes.Equal")
+	}
+}
+
+func TestWithMaxLoadFactor(t *testing.T) {
+	ht := New[int, int](4)
+	for k := 0; k < 100; k++ {
+		ht.Insert(k, k)
+	}
+	if d := ht.Distribution(); d.Buckets != 64 {
+		t.Fatalf("Buckets = %d after 100 inserts into 4 buckets, want 64", d.Buckets)
+	}
+	for k := 0; k < 100; k++ {
+		if v, ok := ht.Search(k); !ok || v != k {
+			t.Fatalf("Search(%d) = %d, %v after growing, want %d, true", k, v, ok, k)
+		}
+	}
+
+	dense := New(4, WithMaxLoadFactor[int, int](8))
+	fixed := New(4, WithMaxLoadFactor[int, int](0))
+	for k := 0; k < 100; k++ {
+		dense.Insert(k, k)
+		fixed.Insert(k, k)
+	}
+	if d := dense.Distribution(); d.Buckets != 16 {
+		t.Errorf("Buckets = %d with a load factor of 8, want 16", d.Buckets)
+	}
+	if d := fixed.Distribution(); d.Buckets != 4 {
+		t.Errorf("Buckets = %d with a load factor of 0, want 4", d.Buckets)
+	}
+}
+
+func TestWithIncrementalRehash(t *testing.T) {
+	ht := New(4, WithIncrementalRehash[int, int]())
+	rehashing := 0
+	for k := 0; k < 1000; k++ {
+		ht.Insert(k, k)
+		if chainedStore(ht).old != nil {
+			rehashing++
+		}
+		// lookups during a rehash must find keys on both sides of the migration
+		if v, ok := ht.Search(k / 2); !ok || v != k/2 {
+			t.Fatalf("Search(%d) = %d, %v after inserting %d, want %d, true", k/2, v, ok, k, k/2)
+		}
+	}
+	if rehashing == 0 {
+		t.Fatal("growth never left the table mid-rehash")
+	}
+
+	for k := 0; k < 1000; k += 2 {
+		if _, ok := ht.Delete(k); !ok {
+			t.Fatalf("Delete(%d) = false", k)
+		}
+	}
+	if ht.Len() != 500 {
+		t.Fatalf("Len() = %d, want 500", ht.Len())
+	}
+	seen := 0
+	for k := range ht.KeysSeq() {
+		if k%2 == 0 {
+			t.Fatalf("deleted key %d is still stored", k)
+		}
+		seen++
+	}
+	if seen != 500 || chainedStore(ht).old != nil {
+		t.Fatalf("KeysSeq yielded %d keys, old = %v; want 500 keys and no rehash in progress", seen, chainedStore(ht).old != nil)
+	}
+}
+
+func TestWithTreeBuckets(t *testing.T) {
+	ht := New(4, WithHasher[string, int](HasherFunc[string](collide[string])), WithTreeBuckets[string, int]())
+	for k := 0; k < 1000; k++ {
+		ht.Insert(strconv.Itoa(k), k)
+	}
+	c := chainedStore(ht)
+	root := c.trees[0]
+	if root == nil {
+		t.Fatal("bucket of 1000 colliding keys was not treeified")
+	}
+	if h := height(root); h > 15 {
+		t.Errorf("tree over 1000 entries has height %d", h)
+	}
+
+	for k := 0; k < 1000; k += 2 {
+		if _, ok := ht.Delete(strconv.Itoa(k)); !ok {
+			t.Fatalf("Delete(%d) = false", k)
+		}
+	}
+	// the tree must index every remaining entry of the bucket exactly once
+	indexed := make(map[int]bool)
+	var walk func(*treeNode)
+	walk = func(n *treeNode) {
+		if n == nil {
+			return
+		}
+		if indexed[n.index] {
+			t.Fatalf("entry %d is indexed twice", n.index)
+		}
+		indexed[n.index] = true
+		walk(n.left)
+		walk(n.right)
+	}
+	walk(c.trees[0])
+	if len(indexed) != len(c.table[0]) {
+		t.Fatalf("tree indexes %d entries, bucket holds %d", len(indexed), len(c.table[0]))
+	}
+	for k := 0; k < 1000; k++ {
+		v, ok := ht.Search(strconv.Itoa(k))
+		if want := k%2 == 1; ok != want || (ok && v != k) {
+			t.Fatalf("Search(%d) = %d, %v, want %v", k, v, ok, want)
+		}
+	}
+
+	ht.DeleteFunc(func(k string, v int) bool { return v > 10 })
+	if c.trees[0] != nil {
+		t.Errorf("bucket of %d entries is still treeified", ht.Len())
+	}
+}
+
+func TestWithSortedBuckets(t *testing.T) {
+	ht := New(4, WithMaxLoadFactor[int, int](64), WithSortedBuckets[int, int]())
+	for k := 0; k < 5000; k++ {
+		ht.Insert(k, k)
+	}
+	ht.DeleteFunc(func(k, _ int) bool { return k%3 == 0 })
+	for k := 0; k < 5000; k += 3 {
+		ht.Insert(k, -k)
+	}
+	for k := 1; k < 5000; k += 3 {
+		ht.Delete(k)
+	}
+
+	for b, bucket := range chainedStore(ht).table {
+		if !slices.IsSortedFunc(bucket, func(x, y kv[int, int]) int { return cmp.Compare(x.hash, y.hash) }) {
+			t.Fatalf("bucket %d is not sorted by hash", b)
+		}
+	}
+	for k := 0; k < 5000; k++ {
+		v, ok := ht.Search(k)
+		switch {
+		case k%3 == 0 && (!ok || v != -k), k%3 == 1 && ok, k%3 == 2 && (!ok || v != k):
+			t.Fatalf("Search(%d) = %d, %v", k, v, ok)
+		}
+	}
+}
+
+func TestWithSmallTable(t *testing.T) {
+	hashed := 0
+	counting := HasherFunc[int](func(k int) uint64 {
+		hashed++
+		return uint64(k)
+	})
+	ht := New(64, WithHasher[int, int](counting), WithSmallTable[int, int]())
+	for k := 0; k < smallTableSize; k++ {
+		ht.Insert(k, k)
+		ht.Search(k)
+	}
+	ht.Delete(3)
+	ht.Insert(3, 3)
+	// reserving room for fewer entries than are stored changes nothing
+	ht.Reserve(5)
+	if hashed != 0 || ht.Len() != smallTableSize {
+		t.Fatalf("small table hashed keys %d times, holding %d keys", hashed, ht.Len())
+	}
+	if got := ht.Filter(func(k, _ int) bool { return k%2 == 0 }); got.Len() != smallTableSize/2 || !got.small {
+		t.Fatalf("Filter returned %d entries, small = %v", got.Len(), got.small)
+	}
+
+	ht.Insert(smallTableSize, smallTableSize)
+	if ht.small || hashed != smallTableSize+1 {
+		t.Fatalf("after %d inserts small = %v with %d keys hashed", smallTableSize+1, ht.small, hashed)
+	}
+	if n := len(chainedStore(ht).table); n != 64 {
+		t.Errorf("promoted table has %d buckets, want 64", n)
+	}
+	for k := 0; k <= smallTableSize; k++ {
+		if v, ok := ht.Search(k); !ok || v != k {
+			t.Fatalf("Search(%d) = %d, %v after promotion", k, v, ok)
+		}
+	}
+}
+
+func TestWithPointerFreeStorage(t *testing.T) {
+	type point struct {
+		X, Y float64
+		Tag  [4]byte
+	}
+	if _, ok := New(8, WithPointerFreeStorage[int, point]()).store.(*flat[int, point]); !ok {
+		t.Error("int keys and pointer-free struct values were not stored flat")
+	}
+	if _, ok := New(8, WithPointerFreeStorage[string, int]()).store.(*chained[string, int]); !ok {
+		t.Error("string keys changed the storage")
+	}
+	if _, ok := New(8, WithPointerFreeStorage[int, struct{ P *int }]()).store.(*chained[int, struct{ P *int }]); !ok {
+		t.Error("values holding a pointer changed the storage")
+	}
+
+	// reserving room for fewer entries than are stored changes nothing
+	ht := New(8, WithPointerFreeStorage[int, int]())
+	for k := 0; k < 10; k++ {
+		ht.Insert(k, k)
+	}
+	ht.Reserve(5)
+	for k := 0; k < 10; k++ {
+		if v, ok := ht.Search(k); !ok || v != k {
+			t.Fatalf("Search(%d) = %d, %v after Reserve(5)", k, v, ok)
+		}
+	}
+}
+
+func TestWithFreelist(t *testing.T) {
+	// a sliding window of keys moves through buckets that have never held an entry
+	churn := func(opts ...Option[int, int]) float64 {
+		identity := WithHasher[int, int](HasherFunc[int](func(k int) uint64 { return uint64(k) }))
+		ht := New(1<<16, append(opts, identity, WithMaxLoadFactor[int, int](0))...)
+		for k := 0; k < 64; k++ {
+			ht.Insert(k, k)
+		}
+		next := 64
+		return testing.AllocsPerRun(10000, func() {
+			ht.Delete(next - 64)
+			ht.Insert(next, next)
+			next++
+		})
+	}
+	plain, recycled := churn(), churn(WithFreelist[int, int]())
+	if recycled >= plain || recycled > 0.01 {
+		t.Errorf("churn allocated %v times per operation with a freelist, %v without", recycled, plain)
+	}
+
+	ht := New(16, WithFreelist[int, int]())
+	want := make(map[int]int)
+	for k := 0; k < 2000; k++ {
+		ht.Insert(k, k)
+		want[k] = k
+		if k%3 == 0 {
+			ht.Delete(k / 2)
+			delete(want, k/2)
+		}
+	}
+	checkContents(t, ht, want)
+	if c := chainedStore(ht); c.freeSlots > max(c.size, minFreeSlots) {
+		t.Errorf("freelist holds %d slots for %d entries", c.freeSlots, c.size)
+	}
+}
@@ -0,0 +1,285 @@
+package hashtable
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// minParallelEntries is the number of entries below which InsertAllParallel inserts
+// them from the calling goroutine, since starting workers would cost more than it
+// saves.
+const minParallelEntries = 1 << 12
+
+// InsertAllParallel inserts every entry, using up to GOMAXPROCS goroutines. The
+// entries are first partitioned by shard, with workers hashing separate ranges of
+// them, and then each shard is filled by one worker under a single lock, so loading
+// a large number of entries keeps every core busy instead of one. If a key occurs
+// more than once, its last value in entries is stored, as with a loop of Insert
+// calls. Other goroutines may use s meanwhile.
+func (s *ShardedHashTable[K, V]) InsertAllParallel(entries []Entry[K, V]) {
+	s.InsertAllParallelContext(context.Background(), entries)
+}
+
+// InsertAllParallelContext is like InsertAllParallel, but stops inserting once ctx
+// is canceled. It returns the number of entries inserted, which are not necessarily
+// the first ones, and ctx.Err() if it stopped before inserting them all.
+func (s *ShardedHashTable[K, V]) InsertAllParallelContext(ctx context.Context, entries []Entry[K, V]) (int, error) {
+	done := ctx.Done()
+	workers := runtime.GOMAXPROCS(0)
+	if workers == 1 || len(entries) < minParallelEntries {
+		for i, e := range entries {
+			if i%ctxCheckInterval == 0 && canceled(done) {
+				return i, ctx.Err()
+			}
+			s.Insert(e.Key, e.Value)
+		}
+		return len(entries), nil
+	}
+	// entries are indexed with int32 to halve the memory the partitioning takes
+	inserted := 0
+	for len(entries) > math.MaxInt32 {
+		n := s.insertAllParallel(done, entries[:math.MaxInt32], workers)
+		inserted += n
+		if n < math.MaxInt32 {
+			return inserted, ctx.Err()
+		}
+		entries = entries[math.MaxInt32:]
+	}
+	n := s.insertAllParallel(done, entries, workers)
+	inserted += n
+	if n < len(entries) {
+		return inserted, ctx.Err()
+	}
+	return inserted, nil
+}
+
+// insertAllParallel inserts entries with the given number of workers, as
+// InsertAllParallel describes, until done is closed. It returns the number of
+// entries inserted.
+func (s *ShardedHashTable[K, V]) insertAllParallel(done <-chan struct{}, entries []Entry[K, V], workers int) int {
+
+	// each worker hashes one chunk of the entries and counts them per shard
+	chunk := (len(entries) + workers - 1) / workers
+	shardOf := make([]int32, len(entries))
+	counts := make([][]int, workers)
+	parallel(workers, func(w int) {
+		counts[w] = make([]int, len(s.shards))
+		for i := w * chunk; i < min((w+1)*chunk, len(entries)); i++ {
+			if i%ctxCheckInterval == 0 && canceled(done) {
+				return
+			}
+			shard := s.shardIndex(entries[i].Key)
+			shardOf[i] = int32(shard)
+			counts[w][shard]++
+		}
+	})
+
+	// lay the entries of each shard out contiguously, chunk by chunk, so that
+	// entries keep their order within a shard; counts[w] becomes the position where
+	// chunk w places its next entry of each shard
+	if canceled(done) {
+		return 0
+	}
+	starts := make([]int, len(s.shards)+1)
+	position := 0
+	for shard := range s.shards {
+		starts[shard] = position
+		for w := range counts {
+			n := counts[w][shard]
+			counts[w][shard] = position
+			position += n
+		}
+	}
+	starts[len(s.shards)] = position
+	order := make([]int32, len(entries))
+	parallel(workers, func(w int) {
+		for i := w * chunk; i < min((w+1)*chunk, len(entries)); i++ {
+			order[counts[w][shardOf[i]]] = int32(i)
+			counts[w][shardOf[i]]++
+		}
+	})
+
+	if canceled(done) {
+		return 0
+	}
+
+	// workers then claim whole shards until none are left
+	var next, inserted atomic.Int64
+	parallel(min(workers, len(s.shards)), func(int) {
+		for shard := int(next.Add(1) - 1); shard < len(s.shards); shard = int(next.Add(1) - 1) {
+			indexes := order[starts[shard]:starts[shard+1]]
+			n := s.shards[shard].insertAll(done, entries, indexes)
+			inserted.Add(int64(n))
+			if n < len(indexes) {
+				return
+			}
+		}
+	})
+	return int(inserted.Load())
+}
+
+// insertAll inserts the entries at the given indexes under one lock, until done is
+// closed, and returns the number inserted.
+func (s *SyncHashTable[K, V]) insertAll(done <-chan struct{}, entries []Entry[K, V], indexes []int32) int {
+	if len(indexes) == 0 {
+		return 0
+	}
+	s.lock()
+	defer s.mu.Unlock()
+	s.ht.Reserve(s.ht.Len() + len(indexes))
+	for n, i := range indexes {
+		if n%ctxCheckInterval == 0 && canceled(done) {
+			return n
+		}
+		s.ht.Insert(entries[i].Key, entries[i].Value)
+		s.touch(entries[i].Key)
+	}
+	return len(indexes)
+}
+
+// parallel calls fn(0) through fn(workers-1), each in its own goroutine, and waits
+// for them all to return.
+func parallel(workers int, fn func(worker int)) {
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := range workers {
+		go func() {
+			defer wg.Done()
+			fn(w)
+		}()
+	}
+	wg.Wait()
+}
+
+// rangeBatch is the number of buckets, or entries, a RangeParallel worker claims at
+// a time.
+const rangeBatch = 64
+
+// RangeParallel calls fn for every key/value pair from a pool of workers goroutines,
+// which claim batches of buckets in turn, for CPU-heavy work on each entry such as
+// serialization or validation. fn is called concurrently from several goroutines
+// and must not modify the table. RangeParallel returns once every call of fn has
+// returned. A number of workers below one selects GOMAXPROCS.
+func (ht *HashTable[K, V]) RangeParallel(workers int, fn func(K, V)) {
+	ht.RangeParallelContext(context.Background(), workers, fn)
+}
+
+// RangeParallelContext is like RangeParallel, but stops calling fn once ctx is
+// canceled. It returns, once the calls in progress have returned, the number of
+// calls made and ctx.Err() if it stopped before visiting every entry.
+func (ht *HashTable[K, V]) RangeParallelContext(ctx context.Context, workers int, fn func(K, V)) (int, error) {
+	done := ctx.Done()
+	// slots completes any incremental rehash, after which reading the storage
+	// modifies nothing and may be done concurrently
+	positions, _ := ht.store.slots()
+	var next, visited atomic.Int64
+	var stopped atomic.Bool
+	parallel(poolSize(workers, positions), func(int) {
+		n := 0
+		defer func() { visited.Add(int64(n)) }()
+		for start := int(next.Add(rangeBatch)) - rangeBatch; start < positions; start = int(next.Add(rangeBatch)) - rangeBatch {
+			for p := start; p < min(start+rangeBatch, positions); p++ {
+				for d := 0; ; d++ {
+					data := ht.store.at(p, d)
+					if data == nil {
+						break
+					}
+					if canceled(done) {
+						stopped.Store(true)
+						return
+					}
+					fn(data.Key, data.Value)
+					n++
+				}
+			}
+		}
+	})
+	if stopped.Load() {
+		return int(visited.Load()), ctx.Err()
+	}
+	return int(visited.Load()), nil
+}
+
+// RangeParallel is like HashTable.RangeParallel, visiting a copy of the entries
+// taken under the lock, as Range does. fn may call any method of s.
+func (s *SyncHashTable[K, V]) RangeParallel(workers int, fn func(K, V)) {
+	s.RangeParallelContext(context.Background(), workers, fn)
+}
+
+// RangeParallelContext is like HashTable.RangeParallelContext, with the copy of the
+// entries taken as by EntriesContext.
+func (s *SyncHashTable[K, V]) RangeParallelContext(ctx context.Context, workers int, fn func(K, V)) (int, error) {
+	entries, err := s.EntriesContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	done := ctx.Done()
+	var next, visited atomic.Int64
+	var stopped atomic.Bool
+	parallel(poolSize(workers, len(entries)), func(int) {
+		n := 0
+		defer func() { visited.Add(int64(n)) }()
+		for start := int(next.Add(rangeBatch)) - rangeBatch; start < len(entries); start = int(next.Add(rangeBatch)) - rangeBatch {
+			for _, e := range entries[start:min(start+rangeBatch, len(entries))] {
+				if canceled(done) {
+					stopped.Store(true)
+					return
+				}
+				fn(e.Key, e.Value)
+				n++
+			}
+		}
+	})
+	if stopped.Load() {
+		return int(visited.Load()), ctx.Err()
+	}
+	return int(visited.Load()), nil
+}
+
+// RangeParallel is like HashTable.RangeParallel. Workers claim one shard at a time
+// and visit a copy of its entries, as All does, so fn may call any method of s.
+func (s *ShardedHashTable[K, V]) RangeParallel(workers int, fn func(K, V)) {
+	s.RangeParallelContext(context.Background(), workers, fn)
+}
+
+// RangeParallelContext is like HashTable.RangeParallelContext.
+func (s *ShardedHashTable[K, V]) RangeParallelContext(ctx context.Context, workers int, fn func(K, V)) (int, error) {
+	done := ctx.Done()
+	var next, visited atomic.Int64
+	var stopped atomic.Bool
+	parallel(poolSize(workers, len(s.shards)*rangeBatch), func(int) {
+		n := 0
+		defer func() { visited.Add(int64(n)) }()
+		for shard := int(next.Add(1) - 1); shard < len(s.shards); shard = int(next.Add(1) - 1) {
+			entries, err := s.shards[shard].EntriesContext(ctx)
+			if err != nil {
+				stopped.Store(true)
+				return
+			}
+			for _, e := range entries {
+				if canceled(done) {
+					stopped.Store(true)
+					return
+				}
+				fn(e.Key, e.Value)
+				n++
+			}
+		}
+	})
+	if stopped.Load() {
+		return int(visited.Load()), ctx.Err()
+	}
+	return int(visited.Load()), nil
+}
+
+// poolSize returns the number of goroutines to share n units of work in batches of
+// rangeBatch, given a requested number of workers, below one for GOMAXPROCS.
+func poolSize(workers, n int) int {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return max(1, min(workers, (n+rangeBatch-1)/rangeBatch))
+}
@@ -0,0 +1,111 @@
+package hashtable
+
+import (
+	"maps"
+	"sync"
+	"testing"
+)
+
+func TestInsertAllParallel(t *testing.T) {
+	for _, n := range []int{10, 100_000} {
+		entries := make([]Entry[int, int], n)
+		want := make(map[int]int)
+		for i := range entries {
+			// every key occurs about twice; the later value must win
+			entries[i] = Entry[int, int]{Key: i % (n/2 + 1), Value: i}
+			want[entries[i].Key] = i
+		}
+		want[-1] = -1
+		for _, workers := range []int{0, 3} {
+			s := NewSharded[int, int](8, 16)
+			s.Insert(-1, -1)
+			if workers == 0 {
+				s.InsertAllParallel(entries)
+			} else {
+				// exercise the partitioning whatever GOMAXPROCS is
+				s.insertAllParallel(nil, entries, workers)
+			}
+
+			got := make(map[int]int)
+			for k, v := range s.All() {
+				got[k] = v
+			}
+			if !maps.Equal(got, want) {
+				t.Fatalf("inserting %d entries with %d workers stored %d keys, want %d", n, workers, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestRangeParallel(t *testing.T) {
+	// collect returns a function that records the pairs it is called with, failing
+	// the test if a key is seen twice
+	collect := func(t *testing.T, got map[int]int) func(int, int) {
+		var mu sync.Mutex
+		return func(k, v int) {
+			mu.Lock()
+			defer mu.Unlock()
+			if _, ok := got[k]; ok {
+				t.Errorf("RangeParallel visited key %d twice", k)
+			}
+			got[k] = v
+		}
+	}
+
+	forEachBackend(t, func(t *testing.T, newTable func() *HashTable[int, int]) {
+		ht := newTable()
+		want := make(map[int]int)
+		for k := 0; k < 2000; k++ {
+			ht.Insert(k, -k)
+			want[k] = -k
+		}
+		for k := 0; k < 2000; k += 3 {
+			ht.Delete(k)
+			delete(want, k)
+		}
+		got := make(map[int]int)
+		ht.RangeParallel(3, collect(t, got))
+		if !maps.Equal(got, want) {
+			t.Fatalf("RangeParallel visited %d entries, want %d", len(got), len(want))
+		}
+	})
+
+	s := NewSharded[int, int](4, 16)
+	locked := NewSync[int, int](16)
+	want := make(map[int]int)
+	for k := 0; k < 5000; k++ {
+		s.Insert(k, k)
+		locked.Insert(k, k)
+		want[k] = k
+	}
+	for name, rangeParallel := range map[string]func(int, func(int, int)){
+		"sharded": s.RangeParallel,
+		"sync":    locked.RangeParallel,
+	} {
+		got := make(map[int]int)
+		rangeParallel(0, collect(t, got))
+		if !maps.Equal(got, want) {
+			t.Errorf("%s RangeParallel visited %d entries, want %d", name, len(got), len(want))
+		}
+	}
+}
+
+func BenchmarkInsertAllParallel(b *testing.B) {
+	entries := make([]Entry[int, int], 1<<20)
+	for i := range entries {
+		entries[i] = Entry[int, int]{Key: i, Value: i}
+	}
+	b.Run("Insert", func(b *testing.B) {
+		for b.Loop() {
+			s := NewSharded[int, int](0, 16)
+			for _, e := range entries {
+				s.Insert(e.Key, e.Value)
+			}
+		}
+	})
+	b.Run("InsertAllParallel", func(b *testing.B) {
+		for b.Loop() {
+			NewSharded[int, int](0, 16).InsertAllParallel(entries)
+		}
+	})
+}
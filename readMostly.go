@@ -0,0 +1,422 @@
+package hashtable
+
+import (
+	"iter"
+	"sync"
+	"sync/atomic"
+)
+
+// A ReadMostlyHashTable is a HashTable that is safe for concurrent use by multiple
+// goroutines and is tuned, like sync.Map, for keys that are written once and read
+// many times, or for goroutines that each work on their own keys. Lookups of keys
+// already published read an immutable table through an atomic pointer and take no
+// lock; new keys go into a second, locked dirty table, which replaces the read table
+// once lookups have missed it as many times as it holds keys. Overwriting or
+// deleting a published key only swaps a pointer, also without a lock.
+//
+// For workloads that keep adding new keys, a SyncHashTable or ShardedHashTable is
+// usually faster. WithIncrementalRehash has no effect on a ReadMostlyHashTable,
+// since its read table must never change under concurrent lookups, and neither do
+// WithMaxEntries, WithOnEvict and WithDefaultTTL, since it never evicts or expires
+// entries on its own.
+type ReadMostlyHashTable[K any, V any] struct {
+	// read holds the table that lookups consult first. It is replaced, never
+	// modified, although the values of its entries may be swapped atomically.
+	read atomic.Pointer[readView[K, V]]
+
+	mu sync.Mutex
+	// dirty holds every entry of read that is not expunged, along with the keys
+	// added since read was last replaced, or nil right after read is replaced.
+	dirty *HashTable[K, *readEntry[V]]
+	// misses counts the lookups that had to lock mu since read was last replaced.
+	misses int
+
+	// base is an empty table configured by the constructor options, from which the
+	// read and dirty tables are made.
+	base *HashTable[K, V]
+	// expunged marks an entry that was deleted from read and left out of dirty.
+	expunged *V
+}
+
+// A readView is the read table of a ReadMostlyHashTable.
+type readView[K any, V any] struct {
+	m *HashTable[K, *readEntry[V]]
+	// amended is set when dirty holds keys that m does not.
+	amended bool
+}
+
+// A readEntry holds the value of a key in a ReadMostlyHashTable. Its pointer is nil
+// when the key has been deleted, or the table's expunged marker when the key has
+// been deleted and the dirty table does not hold the entry.
+type readEntry[V any] struct {
+	p atomic.Pointer[V]
+}
+
+// NewReadMostly creates a ReadMostlyHashTable with n number of internal buckets,
+// configured by opts like New.
+func NewReadMostly[K comparable, V any](numberOfBuckets int, opts ...Option[K, V]) *ReadMostlyHashTable[K, V] {
+	return newReadMostly(New(numberOfBuckets, opts...))
+}
+
+// NewReadMostlyFunc creates a ReadMostlyHashTable for keys that are not comparable
+// with ==, hashed with hash and compared with equal like NewFunc.
+func NewReadMostlyFunc[K any, V any](numberOfBuckets int, hash func(K) uint64, equal func(a, b K) bool, opts ...Option[K, V]) *ReadMostlyHashTable[K, V] {
+	return newReadMostly(NewFunc(numberOfBuckets, hash, equal, opts...))
+}
+
+func newReadMostly[K any, V any](base *HashTable[K, V]) *ReadMostlyHashTable[K, V] {
+	base.layout.incremental = false
+	s := &ReadMostlyHashTable[K, V]{base: base, expunged: new(V)}
+	s.read.Store(&readView[K, V]{m: s.newTable()})
+	return s
+}
+
+// newTable returns an empty table configured like base.
+func (s *ReadMostlyHashTable[K, V]) newTable() *HashTable[K, *readEntry[V]] {
+	return newLike[K, V, *readEntry[V]](s.base)
+}
+
+func newReadEntry[V any](value V) *readEntry[V] {
+	e := &readEntry[V]{}
+	e.p.Store(&value)
+	return e
+}
+
+// load returns the value of e, if it has not been deleted.
+func (s *ReadMostlyHashTable[K, V]) load(e *readEntry[V]) (V, bool) {
+	p := e.p.Load()
+	if p == nil || p == s.expunged {
+		var zero V
+		return zero, false
+	}
+	return *p, true
+}
+
+// entry returns the entry for key, consulting the dirty table when read lacks key
+// and might be missing it. The caller must not hold mu.
+func (s *ReadMostlyHashTable[K, V]) entry(key K) (*readEntry[V], bool) {
+	read := s.read.Load()
+	e, ok := read.m.Search(key)
+	if !ok && read.amended {
+		s.mu.Lock()
+		// read may have been replaced while mu was being acquired
+		read = s.read.Load()
+		e, ok = read.m.Search(key)
+		if !ok && read.amended {
+			e, ok = s.dirty.Search(key)
+			// count the miss whether or not key was found: either way the lookup
+			// will keep locking until dirty is promoted
+			s.missLocked()
+		}
+		s.mu.Unlock()
+	}
+	return e, ok
+}
+
+// Search is like HashTable.Search. It takes no lock when key is in the read table.
+func (s *ReadMostlyHashTable[K, V]) Search(key K) (V, bool) {
+	e, ok := s.entry(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return s.load(e)
+}
+
+// Contains is like HashTable.Contains.
+func (s *ReadMostlyHashTable[K, V]) Contains(key K) bool {
+	_, ok := s.Search(key)
+	return ok
+}
+
+// GetOrDefault is like HashTable.GetOrDefault.
+func (s *ReadMostlyHashTable[K, V]) GetOrDefault(key K, fallback V) V {
+	if value, ok := s.Search(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// Insert is like HashTable.Insert.
+func (s *ReadMostlyHashTable[K, V]) Insert(key K, value V) {
+	s.Swap(key, value)
+}
+
+// Swap is like HashTable.Swap. It takes no lock when key is in the read table and
+// has not been expunged.
+func (s *ReadMostlyHashTable[K, V]) Swap(key K, value V) (V, bool) {
+	read := s.read.Load()
+	if e, ok := read.m.Search(key); ok {
+		if previous, ok := s.trySwap(e, &value); ok {
+			return deref(previous)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	read = s.read.Load()
+	if e, ok := read.m.Search(key); ok {
+		if e.p.CompareAndSwap(s.expunged, nil) {
+			// the entry was left out of dirty and must go back in
+			s.dirty.Insert(key, e)
+		}
+		return deref(e.p.Swap(&value))
+	}
+	if e, ok := s.dirtySearch(key); ok {
+		return deref(e.p.Swap(&value))
+	}
+	if !read.amended {
+		s.dirtyLocked()
+		s.read.Store(&readView[K, V]{m: read.m, amended: true})
+	}
+	s.dirty.Insert(key, newReadEntry(value))
+	var zero V
+	return zero, false
+}
+
+// dirtySearch returns the entry for key in dirty, which may be nil. The caller
+// must hold mu.
+func (s *ReadMostlyHashTable[K, V]) dirtySearch(key K) (*readEntry[V], bool) {
+	if s.dirty == nil {
+		return nil, false
+	}
+	return s.dirty.Search(key)
+}
+
+// trySwap stores value in e unless e is expunged, and returns the previous pointer.
+func (s *ReadMostlyHashTable[K, V]) trySwap(e *readEntry[V], value *V) (*V, bool) {
+	for {
+		p := e.p.Load()
+		if p == s.expunged {
+			return nil, false
+		}
+		if e.p.CompareAndSwap(p, value) {
+			return p, true
+		}
+	}
+}
+
+// deref returns the value p points to, if any.
+func deref[V any](p *V) (V, bool) {
+	if p == nil {
+		var zero V
+		return zero, false
+	}
+	return *p, true
+}
+
+// GetOrInsert is like HashTable.GetOrInsert.
+func (s *ReadMostlyHashTable[K, V]) GetOrInsert(key K, value V) (V, bool) {
+	read := s.read.Load()
+	if e, ok := read.m.Search(key); ok {
+		if actual, loaded, ok := s.tryLoadOrStore(e, value); ok {
+			return actual, loaded
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	read = s.read.Load()
+	if e, ok := read.m.Search(key); ok {
+		if e.p.CompareAndSwap(s.expunged, nil) {
+			s.dirty.Insert(key, e)
+		}
+		actual, loaded, _ := s.tryLoadOrStore(e, value)
+		return actual, loaded
+	}
+	if e, ok := s.dirtySearch(key); ok {
+		actual, loaded, _ := s.tryLoadOrStore(e, value)
+		s.missLocked()
+		return actual, loaded
+	}
+	if !read.amended {
+		s.dirtyLocked()
+		s.read.Store(&readView[K, V]{m: read.m, amended: true})
+	}
+	s.dirty.Insert(key, newReadEntry(value))
+	return value, false
+}
+
+// tryLoadOrStore returns the value of e if it has one, or stores value in e if it
+// was deleted. The last result is false if e is expunged.
+func (s *ReadMostlyHashTable[K, V]) tryLoadOrStore(e *readEntry[V], value V) (V, bool, bool) {
+	for {
+		p := e.p.Load()
+		switch p {
+		case s.expunged:
+			return value, false, false
+		case nil:
+			if e.p.CompareAndSwap(nil, &value) {
+				return value, false, true
+			}
+		default:
+			return *p, true, true
+		}
+	}
+}
+
+// Delete is like HashTable.Delete. It takes no lock when key is in the read table.
+func (s *ReadMostlyHashTable[K, V]) Delete(key K) (V, bool) {
+	read := s.read.Load()
+	e, ok := read.m.Search(key)
+	if !ok && read.amended {
+		s.mu.Lock()
+		read = s.read.Load()
+		e, ok = read.m.Search(key)
+		if !ok && read.amended {
+			e, ok = s.dirty.Delete(key)
+			s.missLocked()
+		}
+		s.mu.Unlock()
+	}
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	for {
+		p := e.p.Load()
+		if p == nil || p == s.expunged {
+			var zero V
+			return zero, false
+		}
+		if e.p.CompareAndSwap(p, nil) {
+			return *p, true
+		}
+	}
+}
+
+// Pop is like HashTable.Pop.
+func (s *ReadMostlyHashTable[K, V]) Pop(key K) (V, bool) {
+	return s.Delete(key)
+}
+
+// CompareAndSwapFunc is like HashTable.CompareAndSwapFunc.
+func (s *ReadMostlyHashTable[K, V]) CompareAndSwapFunc(key K, old, value V, eq func(a, b V) bool) bool {
+	e, ok := s.entry(key)
+	if !ok {
+		return false
+	}
+	for {
+		p := e.p.Load()
+		if p == nil || p == s.expunged || !eq(*p, old) {
+			return false
+		}
+		if e.p.CompareAndSwap(p, &value) {
+			return true
+		}
+	}
+}
+
+// CompareAndDeleteFunc is like HashTable.CompareAndDeleteFunc.
+func (s *ReadMostlyHashTable[K, V]) CompareAndDeleteFunc(key K, old V, eq func(a, b V) bool) bool {
+	e, ok := s.entry(key)
+	if !ok {
+		return false
+	}
+	for {
+		p := e.p.Load()
+		if p == nil || p == s.expunged || !eq(*p, old) {
+			return false
+		}
+		if e.p.CompareAndSwap(p, nil) {
+			return true
+		}
+	}
+}
+
+// Range calls fn for each key/value pair until fn returns false, like
+// HashTable.Range. As with sync.Map, Range does not block other methods and sees a
+// consistent set of keys: each key is visited at most once, although a value
+// stored or deleted while Range runs may or may not be reflected. fn may call any
+// method of s.
+func (s *ReadMostlyHashTable[K, V]) Range(fn func(K, V) bool) {
+	for key, value := range s.All() {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over every key/value pair with the semantics of Range.
+func (s *ReadMostlyHashTable[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		read := s.read.Load()
+		if read.amended {
+			// promote dirty so the iteration covers every key without holding mu
+			s.mu.Lock()
+			read = s.read.Load()
+			if read.amended {
+				read = &readView[K, V]{m: s.dirty}
+				s.read.Store(read)
+				s.dirty = nil
+				s.misses = 0
+			}
+			s.mu.Unlock()
+		}
+		for key, e := range read.m.All() {
+			value, ok := s.load(e)
+			if ok && !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of keys stored. It counts them as Range would, so it takes
+// time proportional to the size of the table.
+func (s *ReadMostlyHashTable[K, V]) Len() int {
+	n := 0
+	for range s.All() {
+		n++
+	}
+	return n
+}
+
+// Clear removes every key.
+func (s *ReadMostlyHashTable[K, V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.read.Store(&readView[K, V]{m: s.newTable()})
+	s.dirty = nil
+	s.misses = 0
+}
+
+// missLocked records a lookup that had to lock mu and promotes dirty to the read
+// table once such lookups have cost as much as copying it.
+func (s *ReadMostlyHashTable[K, V]) missLocked() {
+	s.misses++
+	if s.misses < s.dirty.Len() {
+		return
+	}
+	s.read.Store(&readView[K, V]{m: s.dirty})
+	s.dirty = nil
+	s.misses = 0
+}
+
+// dirtyLocked creates dirty from the read table, if it does not exist, marking the
+// deleted entries of read expunged and leaving them out.
+func (s *ReadMostlyHashTable[K, V]) dirtyLocked() {
+	if s.dirty != nil {
+		return
+	}
+	read := s.read.Load()
+	s.dirty = s.newTable()
+	s.dirty.Reserve(read.m.Len())
+	for key, e := range read.m.All() {
+		if !s.tryExpunge(e) {
+			s.dirty.Insert(key, e)
+		}
+	}
+}
+
+// tryExpunge marks e expunged if it has been deleted, and reports whether it is.
+func (s *ReadMostlyHashTable[K, V]) tryExpunge(e *readEntry[V]) bool {
+	p := e.p.Load()
+	for p == nil {
+		if e.p.CompareAndSwap(nil, s.expunged) {
+			return true
+		}
+		p = e.p.Load()
+	}
+	return p == s.expunged
+}
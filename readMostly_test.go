@@ -0,0 +1,103 @@
+package hashtable
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadMostlyHashTable(t *testing.T) {
+	const hot, goroutines = 100, 8
+	s := NewReadMostly[int, int](16)
+	for k := 0; k < hot; k++ {
+		s.Insert(k, k)
+	}
+	// enough misses promote the dirty table, after which hot keys are read without
+	// locking
+	for i := 0; i < hot; i++ {
+		s.Search(-1)
+	}
+	if read := s.read.Load(); read.amended || read.m.Len() != hot {
+		t.Fatalf("read table holds %d keys (amended %v) after promotion, want %d", read.m.Len(), read.amended, hot)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := i % hot
+				if v, ok := s.Search(k); !ok || v%hot != k {
+					t.Errorf("Search(%d) = %d, %v", k, v, ok)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				s.Insert(i%hot, i%hot+hot*g)
+				s.Insert(hot+g*1000+i, i)
+				if i%3 == 0 {
+					s.Delete(hot + g*1000 + i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := hot + goroutines*(1000-334)
+	if n := s.Len(); n != want {
+		t.Errorf("Len() = %d, want %d", n, want)
+	}
+
+	// a deleted key is expunged when the dirty table is rebuilt and must come back
+	// when it is stored again
+	s.Delete(0)
+	s.Insert(-2, 0)
+	if _, ok := s.Search(0); ok {
+		t.Error("Search(0) found a deleted key")
+	}
+	if v, loaded := s.GetOrInsert(0, 42); loaded || v != 42 {
+		t.Errorf("GetOrInsert(0, 42) = %d, %v after delete", v, loaded)
+	}
+	for i := 0; i < want; i++ {
+		s.Search(-1)
+	}
+	if v, ok := s.Search(0); !ok || v != 42 {
+		t.Errorf("Search(0) = %d, %v after promotion, want 42", v, ok)
+	}
+
+	eq := func(a, b int) bool { return a == b }
+	if !s.CompareAndSwapFunc(0, 42, 7, eq) || s.CompareAndSwapFunc(0, 42, 8, eq) {
+		t.Error("CompareAndSwapFunc did not swap exactly once")
+	}
+	if !s.CompareAndDeleteFunc(0, 7, eq) || s.Contains(0) {
+		t.Error("CompareAndDeleteFunc did not delete key 0")
+	}
+
+	s.Clear()
+	if n := s.Len(); n != 0 {
+		t.Errorf("Len() = %d after Clear", n)
+	}
+}
+
+func TestReadMostlyHashTable_IgnoresLimits(t *testing.T) {
+	advance := fakeClock(t)
+	evicted := 0
+	s := NewReadMostly(4, WithMaxEntries[int, int](2), WithOnEvict(func(int, int, EvictReason) { evicted++ }), WithDefaultTTL[int, int](time.Minute))
+	for k := 0; k < 5; k++ {
+		s.Insert(k, k)
+	}
+	s.Delete(0)
+	advance(time.Minute)
+	for k := 1; k < 5; k++ {
+		if !s.Contains(k) {
+			t.Errorf("Contains(%d) = false, want the table unbounded and without TTLs", k)
+		}
+	}
+	if evicted != 0 {
+		t.Errorf("the eviction callback was called %d times, want none", evicted)
+	}
+}
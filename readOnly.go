@@ -0,0 +1,160 @@
+package hashtable
+
+import (
+	"context"
+	"iter"
+
+	"github.com/jkittell/array"
+)
+
+// A ReadOnlyHashTable is a HashTable that can no longer be modified, created by
+// Freeze once the table has been loaded. It has only the methods of HashTable that
+// read, and since nothing can modify it, any number of goroutines may call them at
+// once without locking.
+type ReadOnlyHashTable[K any, V any] struct {
+	ht *HashTable[K, V]
+}
+
+// Freeze moves every entry of ht into a ReadOnlyHashTable, in constant time, and
+// leaves ht empty, as if it had just been created with the same options. ht may go
+// on being used without affecting the ReadOnlyHashTable.
+func (ht *HashTable[K, V]) Freeze() *ReadOnlyHashTable[K, V] {
+	// completing any incremental rehash leaves lookups nothing to modify
+	ht.store.slots()
+	frozen := *ht
+	*ht = *newLike[K, V, V](&frozen)
+	ht.inheritLimits(&frozen)
+	return &ReadOnlyHashTable[K, V]{ht: &frozen}
+}
+
+// Freeze moves every entry of s into a ReadOnlyHashTable like HashTable.Freeze,
+// leaving s empty.
+func (s *SyncHashTable[K, V]) Freeze() *ReadOnlyHashTable[K, V] {
+	s.acquire()
+	defer s.mu.Unlock()
+	s.ht.store.slots()
+	frozen := &ReadOnlyHashTable[K, V]{ht: s.ht}
+	s.ht = newLike[K, V, V](s.ht)
+	s.ht.inheritLimits(frozen.ht)
+	// clones sharing the frozen table still count s among its owners, so they copy
+	// it before modifying it
+	s.owners = nil
+	if s.versions != nil {
+		s.versions.Clear()
+	}
+	return frozen
+}
+
+// Search is like HashTable.Search.
+func (r *ReadOnlyHashTable[K, V]) Search(key K) (V, bool) {
+	return r.ht.peek(key)
+}
+
+// GetOrDefault is like HashTable.GetOrDefault.
+func (r *ReadOnlyHashTable[K, V]) GetOrDefault(key K, fallback V) V {
+	if value, ok := r.ht.peek(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// Contains is like HashTable.Contains.
+func (r *ReadOnlyHashTable[K, V]) Contains(key K) bool {
+	return r.ht.lookup(key) != nil
+}
+
+// Len is like HashTable.Len.
+func (r *ReadOnlyHashTable[K, V]) Len() int {
+	return r.ht.Len()
+}
+
+// Keys is like HashTable.Keys.
+func (r *ReadOnlyHashTable[K, V]) Keys() array.Array[K] {
+	return r.ht.Keys()
+}
+
+// Values is like HashTable.Values.
+func (r *ReadOnlyHashTable[K, V]) Values() array.Array[V] {
+	return r.ht.Values()
+}
+
+// Entries is like HashTable.Entries.
+func (r *ReadOnlyHashTable[K, V]) Entries() []Entry[K, V] {
+	return r.ht.Entries()
+}
+
+// EntriesContext is like HashTable.EntriesContext.
+func (r *ReadOnlyHashTable[K, V]) EntriesContext(ctx context.Context) ([]Entry[K, V], error) {
+	return r.ht.EntriesContext(ctx)
+}
+
+// All is like HashTable.All.
+func (r *ReadOnlyHashTable[K, V]) All() iter.Seq2[K, V] {
+	return r.ht.All()
+}
+
+// KeysSeq is like HashTable.KeysSeq.
+func (r *ReadOnlyHashTable[K, V]) KeysSeq() iter.Seq[K] {
+	return r.ht.KeysSeq()
+}
+
+// ValuesSeq is like HashTable.ValuesSeq.
+func (r *ReadOnlyHashTable[K, V]) ValuesSeq() iter.Seq[V] {
+	return r.ht.ValuesSeq()
+}
+
+// Range is like HashTable.Range.
+func (r *ReadOnlyHashTable[K, V]) Range(fn func(K, V) bool) {
+	r.ht.Range(fn)
+}
+
+// RangeParallel is like HashTable.RangeParallel.
+func (r *ReadOnlyHashTable[K, V]) RangeParallel(workers int, fn func(K, V)) {
+	r.ht.RangeParallel(workers, fn)
+}
+
+// RangeParallelContext is like HashTable.RangeParallelContext.
+func (r *ReadOnlyHashTable[K, V]) RangeParallelContext(ctx context.Context, workers int, fn func(K, V)) (int, error) {
+	return r.ht.RangeParallelContext(ctx, workers, fn)
+}
+
+// Iterate is like HashTable.Iterate. Since the table never changes, every entry is
+// returned exactly once.
+func (r *ReadOnlyHashTable[K, V]) Iterate(cursor Cursor, limit int) ([]Entry[K, V], Cursor) {
+	return r.ht.Iterate(cursor, limit)
+}
+
+// Any is like HashTable.Any.
+func (r *ReadOnlyHashTable[K, V]) Any(pred func(K, V) bool) bool {
+	return r.ht.Any(pred)
+}
+
+// Every is like HashTable.Every.
+func (r *ReadOnlyHashTable[K, V]) Every(pred func(K, V) bool) bool {
+	return r.ht.Every(pred)
+}
+
+// Find is like HashTable.Find.
+func (r *ReadOnlyHashTable[K, V]) Find(pred func(K, V) bool) (K, V, bool) {
+	return r.ht.Find(pred)
+}
+
+// RandomSample is like HashTable.RandomSample.
+func (r *ReadOnlyHashTable[K, V]) RandomSample(n int) []Entry[K, V] {
+	return r.ht.RandomSample(n)
+}
+
+// TopN is like HashTable.TopN.
+func (r *ReadOnlyHashTable[K, V]) TopN(n int, less func(a, b V) bool) []Entry[K, V] {
+	return r.ht.TopN(n, less)
+}
+
+// Distribution is like HashTable.Distribution.
+func (r *ReadOnlyHashTable[K, V]) Distribution() Distribution {
+	return r.ht.Distribution()
+}
+
+// Thaw returns a copy of the table that can be modified.
+func (r *ReadOnlyHashTable[K, V]) Thaw() *HashTable[K, V] {
+	return r.ht.Clone()
+}
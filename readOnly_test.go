@@ -0,0 +1,67 @@
+package hashtable
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	ht := New[int, int](4, WithIncrementalRehash[int, int]())
+	for k := 0; k < 1000; k++ {
+		ht.Insert(k, k)
+	}
+	frozen := ht.Freeze()
+	if ht.Len() != 0 || frozen.Len() != 1000 {
+		t.Fatalf("after Freeze the table holds %d entries and the frozen one %d", ht.Len(), frozen.Len())
+	}
+	// the table goes on independently of the frozen one
+	ht.Insert(0, -1)
+	if v, _ := frozen.Search(0); v != 0 {
+		t.Fatalf("frozen [0] = %d after modifying the table", v)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 1000; k++ {
+				if v, ok := frozen.Search(k); !ok || v != k {
+					t.Errorf("frozen Search(%d) = %d, %v", k, v, ok)
+					return
+				}
+			}
+			n := 0
+			for range frozen.All() {
+				n++
+			}
+			if n != 1000 {
+				t.Errorf("frozen All yielded %d entries", n)
+			}
+		}()
+	}
+	wg.Wait()
+
+	thawed := frozen.Thaw()
+	thawed.Delete(1)
+	if !frozen.Contains(1) || thawed.Len() != 999 {
+		t.Fatal("modifying a thawed copy changed the frozen table")
+	}
+}
+
+func TestSyncHashTable_Freeze(t *testing.T) {
+	s := NewSync[int, int](4)
+	for k := 0; k < 100; k++ {
+		s.Insert(k, k)
+	}
+	clone := s.Clone()
+	frozen := s.Freeze()
+	clone.Insert(0, -1)
+	s.Insert(1, -1)
+	if v, _ := frozen.Search(0); v != 0 || frozen.Len() != 100 {
+		t.Fatalf("frozen [0] = %d, Len() = %d after modifying a clone", v, frozen.Len())
+	}
+	if clone.Len() != 100 || s.Len() != 1 {
+		t.Fatalf("clone holds %d entries and s %d, want 100 and 1", clone.Len(), s.Len())
+	}
+}
@@ -0,0 +1,175 @@
+package hashtable
+
+// robinHood is an open-addressing storage with Robin Hood probing: an insert that
+// meets an entry closer to its own home slot than the new entry is to its home
+// takes that slot and carries on inserting the displaced entry. Probe distances
+// therefore stay close to their average, and a lookup can stop as soon as it meets
+// an entry nearer its home than the key would be. Removal shifts the entries that
+// follow back by one slot, so no tombstones are left behind. The table is kept at
+// most seven eighths full.
+type robinHood[K any, V any] struct {
+	equal   func(a, b K) bool
+	entries []kv[K, V]
+	// dist holds, for each slot, 0 when it is empty or one more than the distance
+	// of its entry from the entry's home slot.
+	dist []uint32
+	size int
+}
+
+func newRobinHood[K any, V any](l layout[K], n int) *robinHood[K, V] {
+	capacity := max(minSlots, powerOfTwo(n))
+	return &robinHood[K, V]{
+		equal:   l.equal,
+		entries: make([]kv[K, V], capacity),
+		dist:    make([]uint32, capacity),
+	}
+}
+
+// locate returns the slot holding key, or -1.
+func (t *robinHood[K, V]) locate(key K, h uint64) int {
+	mask := uint64(len(t.entries) - 1)
+	i := h & mask
+	for d := uint32(1); ; d++ {
+		// an entry nearer its home than key would be means key is not stored
+		if t.dist[i] < d {
+			return -1
+		}
+		if t.entries[i].hash == h && t.equal(key, t.entries[i].Key) {
+			return int(i)
+		}
+		i = (i + 1) & mask
+	}
+}
+
+func (t *robinHood[K, V]) find(key K, h uint64) *kv[K, V] {
+	i := t.locate(key, h)
+	if i < 0 {
+		return nil
+	}
+	return &t.entries[i]
+}
+
+func (t *robinHood[K, V]) add(key K, h uint64, value V) int {
+	if 8*(t.size+1) > 7*len(t.entries) {
+		t.resize(2 * len(t.entries))
+	}
+	t.size++
+	return t.place(kv[K, V]{Key: key, Value: value, hash: h})
+}
+
+// place inserts data, displacing entries that are nearer their home slots, and
+// returns one more than the distance data settled at.
+func (t *robinHood[K, V]) place(data kv[K, V]) int {
+	mask := uint64(len(t.entries) - 1)
+	i := data.hash & mask
+	settled := 0
+	for d := uint32(1); ; d++ {
+		if t.dist[i] < d {
+			if settled == 0 {
+				settled = int(d)
+			}
+			if t.dist[i] == 0 {
+				t.entries[i], t.dist[i] = data, d
+				return settled
+			}
+			t.entries[i], data = data, t.entries[i]
+			t.dist[i], d = d, t.dist[i]
+		}
+		i = (i + 1) & mask
+	}
+}
+
+func (t *robinHood[K, V]) remove(key K, h uint64) (kv[K, V], bool) {
+	i := t.locate(key, h)
+	if i < 0 {
+		return kv[K, V]{}, false
+	}
+	data := t.entries[i]
+	t.removeAt(i)
+	return data, true
+}
+
+// removeAt empties slot i and shifts each following entry that is not in its home
+// slot back by one.
+func (t *robinHood[K, V]) removeAt(i int) {
+	mask := len(t.entries) - 1
+	for j := (i + 1) & mask; t.dist[j] > 1; i, j = j, (j+1)&mask {
+		t.entries[i], t.dist[i] = t.entries[j], t.dist[j]-1
+	}
+	// zero the slot so the garbage collector can reclaim what it references
+	t.entries[i], t.dist[i] = kv[K, V]{}, 0
+	t.size--
+}
+
+func (t *robinHood[K, V]) len() int {
+	return t.size
+}
+
+func (t *robinHood[K, V]) clear() {
+	clear(t.entries)
+	clear(t.dist)
+	t.size = 0
+}
+
+func (t *robinHood[K, V]) all(yield func(*kv[K, V]) bool) {
+	for i := range t.entries {
+		if t.dist[i] > 0 && !yield(&t.entries[i]) {
+			return
+		}
+	}
+}
+
+func (t *robinHood[K, V]) deleteFunc(del func(*kv[K, V]) bool) int {
+	// removal shifts entries, possibly across the end of the array into slots
+	// already visited, so the doomed entries are collected before any is removed
+	var doomed []kv[K, V]
+	for i := range t.entries {
+		if t.dist[i] > 0 && del(&t.entries[i]) {
+			doomed = append(doomed, t.entries[i])
+		}
+	}
+	for _, data := range doomed {
+		t.removeAt(t.locate(data.Key, data.hash))
+	}
+	return len(doomed)
+}
+
+func (t *robinHood[K, V]) slots() (int, int) {
+	return len(t.entries), 1
+}
+
+func (t *robinHood[K, V]) at(p, d int) *kv[K, V] {
+	if d > 0 || t.dist[p] == 0 {
+		return nil
+	}
+	return &t.entries[p]
+}
+
+func (t *robinHood[K, V]) reserve(n int) {
+	if slots := slotsFor(n, 7, 8); slots > len(t.entries) {
+		t.resize(slots)
+	}
+}
+
+// compact does nothing: removal never leaves tombstones behind.
+func (t *robinHood[K, V]) compact() {}
+
+// shrink lowers the number of slots to what the entries need at half the maximum
+// load.
+func (t *robinHood[K, V]) shrink() {
+	if slots := slotsFor(2*t.size, 7, 8); slots < len(t.entries) {
+		t.resize(slots)
+	}
+}
+
+// resize moves every entry into a new array of n slots.
+func (t *robinHood[K, V]) resize(n int) {
+	entries, dist := t.entries, t.dist
+	t.entries = make([]kv[K, V], n)
+	t.dist = make([]uint32, n)
+	for i := range entries {
+		if dist[i] > 0 {
+			t.place(entries[i])
+		}
+	}
+}
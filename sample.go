@@ -0,0 +1,60 @@
+package hashtable
+
+import "math/rand/v2"
+
+// RandomSample returns up to n distinct entries chosen uniformly at random. If n is
+// at least Len, every entry is returned in random order.
+//
+// Sampling picks a random bucket and a random position below the longest bucket
+// length, retrying when the position is empty, so each call costs O(n) as long as
+// the buckets are reasonably full. Sparse tables, such as after mass deletion, fall
+// back to a single reservoir-sampling pass over all entries.
+func (ht *HashTable[K, V]) RandomSample(n int) []Entry[K, V] {
+	if n <= 0 || ht.Len() == 0 {
+		return nil
+	}
+	if n >= ht.Len() {
+		entries := ht.Entries()
+		rand.Shuffle(len(entries), func(i, j int) {
+			entries[i], entries[j] = entries[j], entries[i]
+		})
+		return entries
+	}
+
+	positions, depth := ht.store.slots()
+	type position struct{ bucket, index int }
+	chosen := make(map[position]struct{}, n)
+	sample := make([]Entry[K, V], 0, n)
+	for attempts := 0; len(sample) < n; attempts++ {
+		if attempts >= 32*n+64 {
+			return ht.reservoirSample(n)
+		}
+		// every entry is equally likely to sit at a given (bucket, index) pair
+		p := position{rand.IntN(positions), rand.IntN(depth)}
+		data := ht.store.at(p.bucket, p.index)
+		if data == nil {
+			continue
+		}
+		if _, ok := chosen[p]; ok {
+			continue
+		}
+		chosen[p] = struct{}{}
+		sample = append(sample, Entry[K, V]{Key: data.Key, Value: data.Value})
+	}
+	return sample
+}
+
+// reservoirSample chooses n entries uniformly at random in one pass over the table.
+func (ht *HashTable[K, V]) reservoirSample(n int) []Entry[K, V] {
+	sample := make([]Entry[K, V], 0, n)
+	seen := 0
+	for key, value := range ht.All() {
+		seen++
+		if len(sample) < n {
+			sample = append(sample, Entry[K, V]{Key: key, Value: value})
+		} else if i := rand.IntN(seen); i < n {
+			sample[i] = Entry[K, V]{Key: key, Value: value}
+		}
+	}
+	return sample
+}
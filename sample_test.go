@@ -0,0 +1,68 @@
+package hashtable
+
+import "testing"
+
+func TestHashTable_RandomSample(t *testing.T) {
+	ht := New[int, int](16, withHashInt[int]())
+	for i := 0; i < 100; i++ {
+		ht.Insert(i, i)
+	}
+
+	sample := ht.RandomSample(10)
+	if len(sample) != 10 {
+		t.Fatalf("len(RandomSample(10)) = %d, want 10", len(sample))
+	}
+	seen := make(map[int]bool)
+	for _, e := range sample {
+		if seen[e.Key] {
+			t.Fatalf("key %d sampled twice", e.Key)
+		}
+		seen[e.Key] = true
+		if e.Value != e.Key {
+			t.Fatalf("sampled %d: %d", e.Key, e.Value)
+		}
+	}
+
+	if all := ht.RandomSample(1000); len(all) != 100 {
+		t.Fatalf("len(RandomSample(1000)) = %d, want 100", len(all))
+	}
+	if none := New[int, int](4, withHashInt[int]()).RandomSample(3); len(none) != 0 {
+		t.Fatalf("sampling an empty table returned %d entries", len(none))
+	}
+}
+
+func TestHashTable_RandomSampleUniform(t *testing.T) {
+	// one long chain and many short ones would bias a pick-a-bucket sampler
+	ht := New(8, WithHasher[int, int](HasherFunc[int](func(k int) uint64 {
+		if k < 8 {
+			return 0
+		}
+		return uint64(k)
+	})))
+	for i := 0; i < 16; i++ {
+		ht.Insert(i, i)
+	}
+
+	counts := make([]int, 16)
+	const rounds = 16000
+	for i := 0; i < rounds; i++ {
+		counts[ht.RandomSample(1)[0].Key]++
+	}
+	for k, c := range counts {
+		if c < rounds/16/2 || c > rounds/16*2 {
+			t.Errorf("key %d sampled %d times out of %d", k, c, rounds)
+		}
+	}
+}
+
+func TestHashTable_RandomSampleSparse(t *testing.T) {
+	ht := New[int, int](64, withHashInt[int]())
+	for i := 0; i < 1000; i++ {
+		ht.Insert(i, i)
+	}
+	ht.DeleteFunc(func(k, v int) bool { return k > 2 })
+
+	if sample := ht.RandomSample(2); len(sample) != 2 {
+		t.Fatalf("len(RandomSample(2)) = %d, want 2", len(sample))
+	}
+}
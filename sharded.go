@@ -0,0 +1,466 @@
+package hashtable
+
+import (
+	"iter"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jkittell/array"
+	"github.com/jkittell/hashtable/hashers"
+)
+
+// A ShardedHashTable is a concurrent table that partitions its keys across a number
+// of SyncHashTable shards, each with its own lock, so goroutines writing different
+// keys rarely wait for one another. A key is hashed once to choose its shard and
+// again by the shard itself.
+//
+// Methods that touch one key lock only that key's shard. Methods that visit every
+// entry lock one shard at a time, so they see each shard at a single moment but
+// not the whole table: an entry may be inserted or deleted in one shard while
+// another is being visited.
+//
+// Range and All snapshot one shard at a time: when the iteration reaches a shard,
+// its entries are copied under its lock and the lock is released before the first
+// of them is visited, so the loop body may call any method. A key never changes
+// shard, so each key is visited at most once, and a key stored throughout the
+// iteration is visited exactly once, however the shards are modified or rehashed
+// meanwhile. A key inserted or deleted during the iteration is seen as it was
+// when its shard was copied.
+type ShardedHashTable[K any, V any] struct {
+	shards []*SyncHashTable[K, V]
+	// selector hashes keys to choose their shard, after normalize if it is set, with
+	// the hash mixed under seed so that hashes with few high bits set spread too.
+	selector  Hasher[K]
+	seed      uint64
+	normalize func(K) K
+	keys      keyLocks[K]
+	// async applies the writes queued by AsyncInsert once started by asyncOnce.
+	asyncOnce sync.Once
+	async     atomic.Pointer[asyncApplier[Entry[K, V]]]
+	// janitor holds the goroutine started by StartJanitor.
+	janitor janitorSlot
+}
+
+// A ShardStats describes one shard of a ShardedHashTable.
+type ShardStats struct {
+	// Len is the number of entries stored in the shard.
+	Len int
+	// Buckets is the number of buckets, or slots, of the shard's storage.
+	Buckets int
+	// Longest is the number of entries hashed to the fullest bucket, as in
+	// Distribution.
+	Longest int
+	// Locks is the number of times the shard's lock has been taken, and Contended
+	// the number of those that had to wait for another goroutine to release it.
+	// Both count from the creation of the table.
+	Locks, Contended uint64
+}
+
+// NewSharded creates a ShardedHashTable with the given number of shards, sharing n
+// number of internal buckets between them, and configures every shard with opts
+// like New. A number of shards below one selects a default of four shards per
+// GOMAXPROCS, rounded up to a power of two.
+func NewSharded[K comparable, V any](shards, numberOfBuckets int, opts ...Option[K, V]) *ShardedHashTable[K, V] {
+	return newSharded(shards, numberOfBuckets, defaultHasher[K](), func(n int) *HashTable[K, V] {
+		return New(n, opts...)
+	})
+}
+
+// NewShardedFunc creates a ShardedHashTable for keys that are not comparable with ==,
+// hashed with hash and compared with equal like NewFunc.
+func NewShardedFunc[K any, V any](shards, numberOfBuckets int, hash func(K) uint64, equal func(a, b K) bool, opts ...Option[K, V]) *ShardedHashTable[K, V] {
+	return newSharded(shards, numberOfBuckets, HasherFunc[K](hash), func(n int) *HashTable[K, V] {
+		return NewFunc(n, hash, equal, opts...)
+	})
+}
+
+func newSharded[K any, V any](shards, numberOfBuckets int, selector Hasher[K], newShard func(int) *HashTable[K, V]) *ShardedHashTable[K, V] {
+	if shards < 1 {
+		shards = powerOfTwo(4 * runtime.GOMAXPROCS(0))
+	}
+	s := &ShardedHashTable[K, V]{
+		shards:   make([]*SyncHashTable[K, V], shards),
+		selector: selector,
+		seed:     rand.Uint64(),
+	}
+	for i := range s.shards {
+		s.shards[i] = newSync(newShard(max(numberOfBuckets/shards, 1)))
+	}
+	s.normalize = s.shards[0].ht.normalize
+	s.keys.init(selector, s.normalize, keyStripes*shards)
+	return s
+}
+
+// shard returns the shard that stores key.
+func (s *ShardedHashTable[K, V]) shard(key K) *SyncHashTable[K, V] {
+	return s.shards[s.shardIndex(key)]
+}
+
+// shardIndex returns the index of the shard that stores key.
+func (s *ShardedHashTable[K, V]) shardIndex(key K) int {
+	if s.normalize != nil {
+		key = s.normalize(key)
+	}
+	// the high bits of the mixed hash pick the shard, leaving the low bits of the
+	// hash, which most storages reduce it with, evenly spread within it
+	h := hashers.Mix64(s.selector.Hash(key) ^ s.seed)
+	return int((h >> 32) * uint64(len(s.shards)) >> 32)
+}
+
+// Insert is like HashTable.Insert.
+func (s *ShardedHashTable[K, V]) Insert(key K, value V) {
+	s.shard(key).Insert(key, value)
+}
+
+// GetOrInsert is like SyncHashTable.GetOrInsert.
+func (s *ShardedHashTable[K, V]) GetOrInsert(key K, value V) (V, bool) {
+	return s.shard(key).GetOrInsert(key, value)
+}
+
+// Upsert is like HashTable.Upsert.
+func (s *ShardedHashTable[K, V]) Upsert(key K, value V, merge func(old, new V) V) V {
+	return s.shard(key).Upsert(key, value, merge)
+}
+
+// Update is like SyncHashTable.Update.
+func (s *ShardedHashTable[K, V]) Update(key K, fn func(V) V) V {
+	return s.shard(key).Update(key, fn)
+}
+
+// ComputeIfAbsent is like SyncHashTable.ComputeIfAbsent.
+func (s *ShardedHashTable[K, V]) ComputeIfAbsent(key K, fn func(K) V) V {
+	return s.shard(key).ComputeIfAbsent(key, fn)
+}
+
+// Swap is like HashTable.Swap.
+func (s *ShardedHashTable[K, V]) Swap(key K, value V) (V, bool) {
+	return s.shard(key).Swap(key, value)
+}
+
+// CompareAndSwapFunc is like HashTable.CompareAndSwapFunc.
+func (s *ShardedHashTable[K, V]) CompareAndSwapFunc(key K, old, value V, eq func(a, b V) bool) bool {
+	return s.shard(key).CompareAndSwapFunc(key, old, value, eq)
+}
+
+// CompareAndDeleteFunc is like HashTable.CompareAndDeleteFunc.
+func (s *ShardedHashTable[K, V]) CompareAndDeleteFunc(key K, old V, eq func(a, b V) bool) bool {
+	return s.shard(key).CompareAndDeleteFunc(key, old, eq)
+}
+
+// Delete is like HashTable.Delete.
+func (s *ShardedHashTable[K, V]) Delete(key K) (V, bool) {
+	return s.shard(key).Delete(key)
+}
+
+// Pop is like HashTable.Pop.
+func (s *ShardedHashTable[K, V]) Pop(key K) (V, bool) {
+	return s.Delete(key)
+}
+
+// LockKey is like SyncHashTable.LockKey. Keys are striped across locks of their
+// own, separate from the shard locks, so locking a key never blocks its shard.
+func (s *ShardedHashTable[K, V]) LockKey(key K) {
+	s.keys.stripe(key).Lock()
+}
+
+// UnlockKey unlocks key, which must have been locked with LockKey.
+func (s *ShardedHashTable[K, V]) UnlockKey(key K) {
+	s.keys.stripe(key).Unlock()
+}
+
+// WithKeyLocked calls fn with key locked as by LockKey. fn may call any method of s.
+func (s *ShardedHashTable[K, V]) WithKeyLocked(key K, fn func()) {
+	s.LockKey(key)
+	defer s.UnlockKey(key)
+	fn()
+}
+
+// SearchVersioned is like SyncHashTable.SearchVersioned. Versions are counted per
+// shard, so they only compare meaningfully for the same key.
+func (s *ShardedHashTable[K, V]) SearchVersioned(key K) (V, uint64, bool) {
+	return s.shard(key).SearchVersioned(key)
+}
+
+// InsertIfVersion is like SyncHashTable.InsertIfVersion.
+func (s *ShardedHashTable[K, V]) InsertIfVersion(key K, value V, version uint64) (uint64, bool) {
+	return s.shard(key).InsertIfVersion(key, value, version)
+}
+
+// Txn runs fn as a transaction, applying the puts and deletes fn makes through tx
+// to their shards all at once, and only if fn returns nil. It returns the error of
+// fn. Shards are locked as fn first touches their keys and released when the
+// transaction ends. To stay deadlock-free a transaction may have to release its
+// locks and start over, so fn may be called more than once and must not have side
+// effects other than through tx.
+func (s *ShardedHashTable[K, V]) Txn(fn func(tx *Txn[K, V]) error) error {
+	return runTxn(s.shards, s.shardIndex, fn)
+}
+
+// Search is like HashTable.Search.
+func (s *ShardedHashTable[K, V]) Search(key K) (V, bool) {
+	return s.shard(key).Search(key)
+}
+
+// GetOrDefault is like HashTable.GetOrDefault.
+func (s *ShardedHashTable[K, V]) GetOrDefault(key K, fallback V) V {
+	return s.shard(key).GetOrDefault(key, fallback)
+}
+
+// Contains is like HashTable.Contains.
+func (s *ShardedHashTable[K, V]) Contains(key K) bool {
+	return s.shard(key).Contains(key)
+}
+
+// Len returns the sum of the lengths of the shards, each read under its lock.
+func (s *ShardedHashTable[K, V]) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Keys returns every key, shard by shard.
+func (s *ShardedHashTable[K, V]) Keys() array.Array[K] {
+	var keys array.Array[K]
+	for key := range s.All() {
+		keys.Push(key)
+	}
+	return keys
+}
+
+// Values returns every value, shard by shard.
+func (s *ShardedHashTable[K, V]) Values() array.Array[V] {
+	var values array.Array[V]
+	for _, value := range s.All() {
+		values.Push(value)
+	}
+	return values
+}
+
+// Entries returns every key/value pair, shard by shard.
+func (s *ShardedHashTable[K, V]) Entries() []Entry[K, V] {
+	var entries []Entry[K, V]
+	for _, shard := range s.shards {
+		entries = append(entries, shard.Entries()...)
+	}
+	return entries
+}
+
+// Clear empties every shard in turn.
+func (s *ShardedHashTable[K, V]) Clear() {
+	for _, shard := range s.shards {
+		shard.Clear()
+	}
+}
+
+// Rehash rehashes every shard under h like HashTable.Rehash. Keys stay in the shard
+// they were in.
+func (s *ShardedHashTable[K, V]) Rehash(h Hasher[K]) {
+	for _, shard := range s.shards {
+		shard.Rehash(h)
+	}
+}
+
+// Compact compacts every shard in turn.
+func (s *ShardedHashTable[K, V]) Compact() {
+	for _, shard := range s.shards {
+		shard.Compact()
+	}
+}
+
+// Shrink shrinks every shard in turn.
+func (s *ShardedHashTable[K, V]) Shrink() {
+	for _, shard := range s.shards {
+		shard.Shrink()
+	}
+}
+
+// Reserve prepares every shard for its share of n entries.
+func (s *ShardedHashTable[K, V]) Reserve(n int) {
+	for _, shard := range s.shards {
+		shard.Reserve((n + len(s.shards) - 1) / len(s.shards))
+	}
+}
+
+// DeleteFunc is like HashTable.DeleteFunc, sweeping one shard at a time.
+func (s *ShardedHashTable[K, V]) DeleteFunc(del func(K, V) bool) int {
+	removed := 0
+	for _, shard := range s.shards {
+		removed += shard.DeleteFunc(del)
+	}
+	return removed
+}
+
+// RetainFunc is like HashTable.RetainFunc, sweeping one shard at a time.
+func (s *ShardedHashTable[K, V]) RetainFunc(keep func(K, V) bool) int {
+	return s.DeleteFunc(func(key K, value V) bool {
+		return !keep(key, value)
+	})
+}
+
+// Merge inserts every entry of other into s like HashTable.Merge, copying one shard
+// of other at a time.
+func (s *ShardedHashTable[K, V]) Merge(other *ShardedHashTable[K, V], resolve func(key K, a, b V) V) {
+	for _, shard := range other.shards {
+		for _, e := range shard.Entries() {
+			s.shard(e.Key).merge(e.Key, e.Value, resolve)
+		}
+	}
+}
+
+// Range calls fn for each key/value pair, snapshotting one shard at a time as
+// described for ShardedHashTable. fn may call any method of s.
+func (s *ShardedHashTable[K, V]) Range(fn func(K, V) bool) {
+	for key, value := range s.All() {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over every key/value pair, snapshotting one shard at a
+// time as described for ShardedHashTable. The loop body may call any method of s.
+func (s *ShardedHashTable[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, shard := range s.shards {
+			for _, e := range shard.Entries() {
+				if !yield(e.Key, e.Value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// SnapshotIter returns an iterator over a copy of the entries, taken shard by shard
+// when SnapshotIter is called rather than as the loop reaches each shard. The loop
+// body may call any method of s.
+func (s *ShardedHashTable[K, V]) SnapshotIter() iter.Seq2[K, V] {
+	entries := s.Entries()
+	return func(yield func(K, V) bool) {
+		for _, e := range entries {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate is like SyncHashTable.Iterate, paging through one shard after another.
+// The shard is held in the high 32 bits of the Cursor.
+func (s *ShardedHashTable[K, V]) Iterate(cursor Cursor, limit int) ([]Entry[K, V], Cursor) {
+	limit = max(limit, 1)
+	var page []Entry[K, V]
+	shard, inner := int(cursor>>32), cursor&(1<<32-1)
+	for shard < len(s.shards) && len(page) < limit {
+		entries, next := s.shards[shard].Iterate(inner, limit-len(page))
+		page = append(page, entries...)
+		if inner = next; next == 0 {
+			shard++
+		}
+	}
+	if shard >= len(s.shards) {
+		return page, 0
+	}
+	return page, Cursor(shard)<<32 | inner
+}
+
+// Any is like HashTable.Any.
+func (s *ShardedHashTable[K, V]) Any(pred func(K, V) bool) bool {
+	for _, shard := range s.shards {
+		if shard.Any(pred) {
+			return true
+		}
+	}
+	return false
+}
+
+// Every is like HashTable.Every.
+func (s *ShardedHashTable[K, V]) Every(pred func(K, V) bool) bool {
+	return !s.Any(func(key K, value V) bool {
+		return !pred(key, value)
+	})
+}
+
+// Find is like HashTable.Find.
+func (s *ShardedHashTable[K, V]) Find(pred func(K, V) bool) (K, V, bool) {
+	for _, shard := range s.shards {
+		if key, value, ok := shard.Find(pred); ok {
+			return key, value, true
+		}
+	}
+	var key K
+	var value V
+	return key, value, false
+}
+
+// like returns a ShardedHashTable that selects shards as s does, with its shards yet
+// to be filled in.
+func (s *ShardedHashTable[K, V]) like() *ShardedHashTable[K, V] {
+	like := &ShardedHashTable[K, V]{
+		shards:    make([]*SyncHashTable[K, V], len(s.shards)),
+		selector:  s.selector,
+		seed:      s.seed,
+		normalize: s.normalize,
+	}
+	like.keys.init(s.selector, s.normalize, keyStripes*len(s.shards))
+	return like
+}
+
+// Clone returns an independent copy of s, cloning one shard at a time as
+// SyncHashTable.Clone does, in time proportional to the number of shards. Each
+// shard is copied when it is first modified, in s or in the copy, so the cost of
+// copying is spread across later writes rather than paid at once.
+func (s *ShardedHashTable[K, V]) Clone() *ShardedHashTable[K, V] {
+	clone := s.like()
+	for i, shard := range s.shards {
+		clone.shards[i] = shard.Clone()
+	}
+	return clone
+}
+
+// Filter is like HashTable.Filter, returning a new ShardedHashTable with the same
+// shards.
+func (s *ShardedHashTable[K, V]) Filter(keep func(K, V) bool) *ShardedHashTable[K, V] {
+	filtered := s.like()
+	for i, shard := range s.shards {
+		filtered.shards[i] = shard.Filter(keep)
+	}
+	return filtered
+}
+
+// TopN is like HashTable.TopN. The n largest entries of every shard are merged.
+func (s *ShardedHashTable[K, V]) TopN(n int, less func(a, b V) bool) []Entry[K, V] {
+	if n <= 0 {
+		return nil
+	}
+	var candidates []Entry[K, V]
+	for _, shard := range s.shards {
+		candidates = append(candidates, shard.TopN(n, less)...)
+	}
+	slices.SortFunc(candidates, func(a, b Entry[K, V]) int {
+		// largest first
+		return boolToInt(less(a.Value, b.Value)) - boolToInt(less(b.Value, a.Value))
+	})
+	return candidates[:min(n, len(candidates))]
+}
+
+// ShardStats reports the size and lock contention of every shard, each read under
+// its lock, in shard order. A shard much longer or more contended than the others
+// points at keys its selector hash spreads poorly, or at a few hot keys; a long
+// Longest points at keys the hash of the shard itself spreads poorly.
+func (s *ShardedHashTable[K, V]) ShardStats() []ShardStats {
+	stats := make([]ShardStats, len(s.shards))
+	for i, shard := range s.shards {
+		unlock := shard.rlock()
+		d := shard.ht.Distribution()
+		stats[i] = ShardStats{Len: shard.ht.Len(), Buckets: d.Buckets, Longest: d.Longest}
+		unlock()
+		stats[i].Locks, stats[i].Contended = shard.locks.Load(), shard.contended.Load()
+	}
+	return stats
+}
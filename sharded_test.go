@@ -0,0 +1,186 @@
+package hashtable
+
+import (
+	"maps"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestShardedHashTable(t *testing.T) {
+	const writers, perWriter = 8, 2000
+	s := NewSharded[int, int](0, 64)
+	if n := len(s.shards); n&(n-1) != 0 {
+		t.Fatalf("default shard count %d is not a power of two", n)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := w * perWriter; k < (w+1)*perWriter; k++ {
+				s.Insert(k, k)
+				s.Update(-1, func(v int) int { return v + 1 })
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := map[int]int{-1: writers * perWriter}
+	for k := 0; k < writers*perWriter; k++ {
+		want[k] = k
+	}
+	got := make(map[int]int)
+	for k, v := range s.All() {
+		got[k] = v
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("table holds %d entries, want %d", len(got), len(want))
+	}
+
+	paged := make(map[int]int)
+	for page, cursor := s.Iterate(0, 100); ; page, cursor = s.Iterate(cursor, 100) {
+		for _, e := range page {
+			paged[e.Key] = e.Value
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	if !maps.Equal(paged, want) {
+		t.Fatalf("Iterate returned %d entries, want %d", len(paged), len(want))
+	}
+
+	total := 0
+	for i, st := range s.ShardStats() {
+		total += st.Len
+		if st.Longest < 1 || st.Longest > st.Len || st.Locks == 0 {
+			t.Errorf("shard %d stats = %+v", i, st)
+		}
+		// every shard should hold close to its share of the keys
+		if share := len(want) / len(s.shards); st.Len < share/2 || st.Len > 2*share {
+			t.Errorf("shard %d holds %d entries, expected about %d", i, st.Len, share)
+		}
+	}
+	if total != s.Len() {
+		t.Errorf("shard lengths add up to %d, Len() = %d", total, s.Len())
+	}
+
+	top := s.TopN(3, func(a, b int) bool { return a < b })
+	if len(top) != 3 || top[0].Value != writers*perWriter || top[1].Key != writers*perWriter-1 {
+		t.Errorf("TopN(3) = %v", top)
+	}
+}
+
+func TestShardedHashTable_All(t *testing.T) {
+	const n = 4000
+	s := NewSharded[int, int](4, 4)
+	for k := 0; k < n; k++ {
+		s.Insert(k, k)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// grow and rehash every shard while the iteration runs
+		for k := n; k < 4*n; k++ {
+			s.Insert(k, k)
+		}
+		s.Rehash(defaultHasher[int]())
+	}()
+	seen := make(map[int]int)
+	for k := range s.All() {
+		seen[k]++
+		if k%2 == 0 {
+			s.Delete(k + 1)
+		}
+	}
+	<-done
+	for k := 0; k < n; k += 2 {
+		if seen[k] != 1 {
+			t.Fatalf("All visited key %d %d times, want once", k, seen[k])
+		}
+	}
+	for k, times := range seen {
+		if times != 1 {
+			t.Fatalf("All visited key %d %d times", k, times)
+		}
+	}
+}
+
+func TestShardedHashTable_LockKey(t *testing.T) {
+	const goroutines, increments = 8, 500
+	s := NewSharded[int, int](4, 16)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				k := i % 10
+				s.LockKey(k)
+				v, _ := s.Search(k)
+				s.Insert(k, v+1)
+				s.UnlockKey(k)
+			}
+		}()
+	}
+	wg.Wait()
+	for k := 0; k < 10; k++ {
+		if v, _ := s.Search(k); v != goroutines*increments/10 {
+			t.Fatalf("counter %d = %d, want %d", k, v, goroutines*increments/10)
+		}
+	}
+	// a clone has locks of its own
+	clone := s.Clone()
+	s.LockKey(0)
+	clone.WithKeyLocked(0, func() {})
+	s.UnlockKey(0)
+}
+
+func TestShardedHashTable_ShardStats(t *testing.T) {
+	s := NewSharded[int, int](4, 16)
+	shard := s.shardIndex(1)
+	s.shards[shard].mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Insert(1, 1)
+	}()
+	// the insert counts its wait before blocking on the lock held here
+	for s.shards[shard].contended.Load() == 0 {
+		runtime.Gosched()
+	}
+	s.shards[shard].mu.Unlock()
+	<-done
+
+	for i, st := range s.ShardStats() {
+		if i != shard {
+			if st.Len != 0 || st.Contended != 0 {
+				t.Errorf("untouched shard %d stats = %+v", i, st)
+			}
+			continue
+		}
+		// the Insert and ShardStats itself took the lock
+		if st.Len != 1 || st.Longest != 1 || st.Locks != 2 || st.Contended != 1 {
+			t.Errorf("shard %d stats = %+v, want Len 1, Longest 1, Locks 2, Contended 1", i, st)
+		}
+	}
+}
+
+func TestShardedHashTable_SmallHashes(t *testing.T) {
+	// a hash with its high bits all zero must still spread keys over the shards
+	s := NewShardedFunc[int, int](8, 64, func(key int) uint64 { return uint64(key) }, func(a, b int) bool { return a == b })
+	for i := range 800 {
+		s.Insert(i, i)
+	}
+	for i, st := range s.ShardStats() {
+		if st.Len < 50 || st.Len > 150 {
+			t.Errorf("shard %d holds %d of 800 keys, want about 100", i, st.Len)
+		}
+	}
+	for i := range 800 {
+		if v, ok := s.Search(i); !ok || v != i {
+			t.Fatalf("Search(%d) = %d, %v, want %d, true", i, v, ok, i)
+		}
+	}
+}
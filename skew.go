@@ -0,0 +1,66 @@
+package hashtable
+
+// A SkewEvent describes a bucket that grew much longer than the load factor of its
+// table explains, which may mean the table is under a hash-flooding attack.
+type SkewEvent struct {
+	// Bucket is the index of the long bucket and Length its length.
+	Bucket, Length int
+	// Len and Buckets are the number of entries and buckets in the table.
+	Len, Buckets int
+	// Reseeded reports whether the table was rehashed under a new Hasher.
+	Reseeded bool
+}
+
+// skewDetector holds the settings of WithSkewDetection.
+type skewDetector[K any] struct {
+	maxChain  int
+	newHasher func() Hasher[K]
+	onSkew    func(SkewEvent)
+	// quietUntil suppresses events until the table holds this many entries, so keys
+	// that collide under every seed do not cause a rehash on every insert.
+	quietUntil int
+}
+
+// WithSkewDetection makes the HashTable watch for a bucket growing past maxChain
+// entries while the table holds fewer than maxChain/4 entries per bucket, a chain
+// that ordinary bad luck practically never produces. When that happens the table
+// is rehashed under a Hasher from newHasher and onSkew, if not nil, is called so
+// the event can be logged as a suspected attack.
+//
+// If newHasher is nil, tables created by New reseed with a new default Hasher and
+// other tables only call onSkew. After an event the table reports no more until it
+// has doubled in size.
+func WithSkewDetection[K any, V any](maxChain int, newHasher func() Hasher[K], onSkew func(SkewEvent)) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.skew = &skewDetector[K]{
+			maxChain:  maxChain,
+			newHasher: newHasher,
+			onSkew:    onSkew,
+		}
+	}
+}
+
+// checkSkew reseeds the table if the bucket of a key that hashes to h, now length
+// entries long, is longer than the load factor explains.
+func (ht *HashTable[K, V]) checkSkew(h uint64, length int) {
+	s := ht.skew
+	positions, _ := ht.store.slots()
+	size := ht.Len()
+	if size < s.quietUntil || size >= positions*s.maxChain/4 {
+		return
+	}
+	event := SkewEvent{
+		Bucket:  int(h % uint64(positions)),
+		Length:  length,
+		Len:     size,
+		Buckets: positions,
+	}
+	s.quietUntil = 2 * size
+	if s.newHasher != nil {
+		ht.Rehash(s.newHasher())
+		event.Reseeded = true
+	}
+	if s.onSkew != nil {
+		s.onSkew(event)
+	}
+}
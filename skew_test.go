@@ -0,0 +1,70 @@
+package hashtable
+
+import (
+	"strconv"
+	"testing"
+)
+
+// collide sends every key to bucket 0, like a table whose keys were chosen by an
+// attacker who knows the Hasher.
+func collide[K any](K) uint64 { return 0 }
+
+func TestWithSkewDetection(t *testing.T) {
+	var events []SkewEvent
+	ht := New(64,
+		WithHasher[string, int](HasherFunc[string](collide[string])),
+		WithSkewDetection[string, int](8, nil, func(e SkewEvent) {
+			events = append(events, e)
+		}))
+	for i := 0; i < 9; i++ {
+		ht.Insert(strconv.Itoa(i), i)
+	}
+
+	want := SkewEvent{Bucket: 0, Length: 9, Len: 9, Buckets: 64, Reseeded: true}
+	if len(events) != 1 || events[0] != want {
+		t.Fatalf("events = %+v, want [%+v]", events, want)
+	}
+	if d := ht.Distribution(); d.Longest > 8 {
+		t.Fatalf("longest bucket holds %d keys after reseeding", d.Longest)
+	}
+	for i := 0; i < 9; i++ {
+		if v, ok := ht.Search(strconv.Itoa(i)); !ok || v != i {
+			t.Fatalf("Search(%d) = %d, %v after reseeding, want %d, true", i, v, ok, i)
+		}
+	}
+}
+
+func TestWithSkewDetection_ReportOnly(t *testing.T) {
+	events := 0
+	ht := NewFunc(64, collide[[]byte], func(a, b []byte) bool { return string(a) == string(b) },
+		WithSkewDetection[[]byte, int](8, nil, func(e SkewEvent) {
+			if e.Reseeded {
+				t.Error("table without a newHasher was reseeded")
+			}
+			events++
+		}))
+	for i := 0; i < 40; i++ {
+		ht.Insert([]byte(strconv.Itoa(i)), i)
+	}
+
+	// reported at 9 keys, then not again until the table doubles, at 18 and 36
+	if events != 3 {
+		t.Fatalf("onSkew called %d times, want 3", events)
+	}
+	if ht.Len() != 40 {
+		t.Fatalf("Len() = %d, want 40", ht.Len())
+	}
+}
+
+func TestWithSkewDetection_Loaded(t *testing.T) {
+	// a long bucket in a full table is explained by the load factor
+	ht := New(2,
+		WithMaxLoadFactor[string, int](0),
+		WithHasher[string, int](HasherFunc[string](collide[string])),
+		WithSkewDetection[string, int](8, nil, func(SkewEvent) {
+			t.Error("onSkew called for a table with a high load factor")
+		}))
+	for i := 0; i < 9; i++ {
+		ht.Insert(strconv.Itoa(i), i)
+	}
+}
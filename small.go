@@ -0,0 +1,122 @@
+package hashtable
+
+import "slices"
+
+// smallTableSize is the number of entries a table created with WithSmallTable holds
+// in a plain array before it starts hashing its keys.
+const smallTableSize = 16
+
+// small is the storage of a table created with WithSmallTable while it holds at
+// most smallTableSize entries: an array searched from the start by key equality
+// alone. Keys are never hashed, so the hashes passed in are ignored and the stored
+// ones are zero. The whole array counts as a single position.
+type small[K any, V any] struct {
+	equal   func(a, b K) bool
+	entries []kv[K, V]
+	// n is the size the storage of the table's layout is created with once the
+	// table outgrows the array.
+	n int
+}
+
+func newSmall[K any, V any](l layout[K], n int) *small[K, V] {
+	return &small[K, V]{equal: l.equal, n: n}
+}
+
+func (s *small[K, V]) find(key K, _ uint64) *kv[K, V] {
+	for i := range s.entries {
+		if s.equal(key, s.entries[i].Key) {
+			return &s.entries[i]
+		}
+	}
+	return nil
+}
+
+func (s *small[K, V]) add(key K, _ uint64, value V) int {
+	s.entries = append(s.entries, kv[K, V]{Key: key, Value: value})
+	return len(s.entries)
+}
+
+func (s *small[K, V]) remove(key K, h uint64) (kv[K, V], bool) {
+	e := s.find(key, h)
+	if e == nil {
+		return kv[K, V]{}, false
+	}
+	data := *e
+	last := len(s.entries) - 1
+	*e = s.entries[last]
+	// zero the vacated slot so the garbage collector can reclaim what it references
+	s.entries[last] = kv[K, V]{}
+	s.entries = s.entries[:last]
+	return data, true
+}
+
+func (s *small[K, V]) len() int {
+	return len(s.entries)
+}
+
+func (s *small[K, V]) clear() {
+	clear(s.entries)
+	s.entries = s.entries[:0]
+}
+
+func (s *small[K, V]) all(yield func(*kv[K, V]) bool) {
+	for i := range s.entries {
+		if !yield(&s.entries[i]) {
+			return
+		}
+	}
+}
+
+func (s *small[K, V]) deleteFunc(del func(*kv[K, V]) bool) int {
+	kept := s.entries[:0]
+	for i := range s.entries {
+		if !del(&s.entries[i]) {
+			kept = append(kept, s.entries[i])
+		}
+	}
+	// zero the tail so the garbage collector can reclaim what it references
+	clear(s.entries[len(kept):])
+	removed := len(s.entries) - len(kept)
+	s.entries = kept
+	return removed
+}
+
+func (s *small[K, V]) slots() (int, int) {
+	return 1, len(s.entries)
+}
+
+func (s *small[K, V]) at(_, d int) *kv[K, V] {
+	if d >= len(s.entries) {
+		return nil
+	}
+	return &s.entries[d]
+}
+
+func (s *small[K, V]) reserve(n int) {
+	if extra := n - len(s.entries); extra > 0 {
+		s.entries = slices.Grow(s.entries, extra)
+	}
+}
+
+func (s *small[K, V]) compact() {
+	if len(s.entries) == 0 {
+		s.entries = nil
+		return
+	}
+	s.entries = slices.Clip(slices.Clone(s.entries))
+}
+
+func (s *small[K, V]) shrink() {
+	s.compact()
+}
+
+// promote moves the entries of a small table into the storage of its layout,
+// hashing every key for the first time.
+func (ht *HashTable[K, V]) promote() {
+	s := ht.store.(*small[K, V])
+	ht.store = newStorage[K, V](ht.layout, s.n)
+	ht.small = false
+	for _, data := range s.entries {
+		ht.store.add(data.Key, ht.hasher.Hash(data.Key), data.Value)
+	}
+}
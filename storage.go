@@ -0,0 +1,119 @@
+package hashtable
+
+import "strconv"
+
+// storage is the layout a HashTable keeps its entries in. The HashTable normalizes
+// and hashes keys; a storage places entries by their hash and compares keys with
+// the equality function of the layout it was created from.
+type storage[K any, V any] interface {
+	// find returns the entry for key, whose hash is h, or nil if key is not stored.
+	// The entry may be modified in place until the storage is next modified.
+	find(key K, h uint64) *kv[K, V]
+	// add stores an entry for key, which must not already be stored, and returns
+	// the number of entries that share the position it was placed at or were probed
+	// on the way there, including itself.
+	add(key K, h uint64, value V) int
+	// remove deletes the entry for key and returns it.
+	remove(key K, h uint64) (kv[K, V], bool)
+	len() int
+	// clear removes every entry, keeping the allocated memory.
+	clear()
+	// all calls yield for each entry until yield returns false. yield must not
+	// modify the storage.
+	all(yield func(*kv[K, V]) bool)
+	// deleteFunc removes every entry for which del returns true and reports how many
+	// entries were removed.
+	deleteFunc(del func(*kv[K, V]) bool) int
+	// slots returns the number of positions entries are placed at and an upper bound
+	// on the number of entries at a single position. Positions of a storage are
+	// stable until it is next modified.
+	slots() (positions, depth int)
+	// at returns the entry at index d of position p, or nil. Entries at a position
+	// occupy the lowest indexes. at may only be called after slots, with no
+	// modification in between other than remove, which may move entries into the
+	// position it vacates; at returns nil for positions that no longer exist.
+	at(p, d int) *kv[K, V]
+	// reserve makes room for n entries.
+	reserve(n int)
+	// compact releases memory held for removed entries.
+	compact()
+	// shrink lowers the number of positions to fit the entries, then compacts.
+	shrink()
+}
+
+// A Backend is a storage engine a HashTable can keep its entries in, selected with
+// WithBackend. Every engine supports every operation; they differ in memory use and
+// in how lookups behave as the table fills or keys collide.
+type Backend int
+
+const (
+	// Chaining keeps a slice of entries per bucket. It is the default, and the only
+	// engine that WithMaxLoadFactor, WithIncrementalRehash, WithTreeBuckets and
+	// WithSortedBuckets apply to.
+	Chaining Backend = iota
+	// OpenAddressing keeps entries in one flat array with linear probing; see
+	// WithOpenAddressing.
+	OpenAddressing
+	// RobinHood is open addressing with Robin Hood probing; see WithRobinHood.
+	RobinHood
+	// SwissTable probes groups of slots by their control bytes; see WithSwissTable.
+	SwissTable
+	// Cuckoo bounds every lookup at two slots and a small stash; see WithCuckoo.
+	Cuckoo
+	// Hopscotch keeps entries within a neighborhood of their home slot; see
+	// WithHopscotch.
+	Hopscotch
+	// FlatChaining chains entries by index within one array instead of keeping a
+	// slice per bucket; see WithPointerFreeStorage. WithMaxLoadFactor applies to it.
+	FlatChaining
+)
+
+var backendNames = [...]string{"Chaining", "OpenAddressing", "RobinHood", "SwissTable", "Cuckoo", "Hopscotch", "FlatChaining"}
+
+func (b Backend) String() string {
+	if b < 0 || int(b) >= len(backendNames) {
+		return "Backend(" + strconv.Itoa(int(b)) + ")"
+	}
+	return backendNames[b]
+}
+
+// layout holds the settings that decide how a HashTable stores its entries.
+type layout[K any] struct {
+	backend Backend
+	// equal reports whether two keys are the same key.
+	equal func(a, b K) bool
+	// maxLoad is the load factor above which a chained table doubles its buckets,
+	// or 0 to keep the number of buckets fixed.
+	maxLoad float64
+	// incremental makes a chained table migrate a few buckets per operation when
+	// it grows instead of rehashing every entry at once.
+	incremental bool
+	// compare orders keys consistently with equal, letting a chained table index
+	// long buckets with a tree, or is nil.
+	compare func(a, b K) int
+	// sorted makes a chained table keep each bucket sorted by hash.
+	sorted bool
+	// recycle makes a chained table keep the backing arrays of emptied buckets for
+	// reuse.
+	recycle bool
+}
+
+// newStorage returns an empty storage for l with room for about n entries, or n
+// buckets for chaining.
+func newStorage[K any, V any](l layout[K], n int) storage[K, V] {
+	switch l.backend {
+	case OpenAddressing:
+		return newLinear[K, V](l, n)
+	case RobinHood:
+		return newRobinHood[K, V](l, n)
+	case SwissTable:
+		return newSwiss[K, V](l, n)
+	case Cuckoo:
+		return newCuckoo[K, V](l, n)
+	case Hopscotch:
+		return newHopscotch[K, V](l, n)
+	case FlatChaining:
+		return newFlat[K, V](l, n)
+	}
+	return newChained[K, V](l, n)
+}
@@ -0,0 +1,396 @@
+package hashtable
+
+import (
+	"maps"
+	"math/bits"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// backends lists the storage layouts that every test in this file runs against.
+var backends = []struct {
+	name string
+	opts []Option[int, int]
+}{
+	{"chained", nil},
+	{"incremental", []Option[int, int]{WithIncrementalRehash[int, int]()}},
+	{"tree buckets", []Option[int, int]{WithTreeBuckets[int, int]()}},
+	{"sorted buckets", []Option[int, int]{WithSortedBuckets[int, int]()}},
+	{"freelist", []Option[int, int]{WithFreelist[int, int](), WithIncrementalRehash[int, int]()}},
+	{"small", []Option[int, int]{WithSmallTable[int, int]()}},
+	{"flat", []Option[int, int]{WithPointerFreeStorage[int, int]()}},
+	{"open addressing", []Option[int, int]{WithOpenAddressing[int, int]()}},
+	{"robin hood", []Option[int, int]{WithRobinHood[int, int]()}},
+	{"swiss", []Option[int, int]{WithSwissTable[int, int]()}},
+	{"cuckoo", []Option[int, int]{WithCuckoo[int, int]()}},
+	{"hopscotch", []Option[int, int]{WithHopscotch[int, int]()}},
+}
+
+// forEachBackend runs test against a table of each backend, both with a good Hasher
+// and with one that sends every key to one of a few buckets.
+func forEachBackend(t *testing.T, test func(t *testing.T, newTable func() *HashTable[int, int])) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			test(t, func() *HashTable[int, int] { return New(4, b.opts...) })
+		})
+		t.Run(b.name+"/colliding", func(t *testing.T) {
+			weak := WithHasher[int, int](HasherFunc[int](func(k int) uint64 { return uint64(k % 5) }))
+			test(t, func() *HashTable[int, int] { return New(4, append(slices.Clone(b.opts), weak)...) })
+		})
+	}
+}
+
+// checkContents fails the test unless ht stores exactly the entries of want.
+func checkContents(t *testing.T, ht *HashTable[int, int], want map[int]int) {
+	t.Helper()
+	if ht.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", ht.Len(), len(want))
+	}
+	got := make(map[int]int)
+	for k, v := range ht.All() {
+		if _, ok := got[k]; ok {
+			t.Fatalf("All yielded key %d twice", k)
+		}
+		got[k] = v
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("All yielded %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got, ok := ht.Search(k); !ok || got != v {
+			t.Fatalf("Search(%d) = %d, %v, want %d, true", k, got, ok, v)
+		}
+	}
+}
+
+func TestStorage_RandomOperations(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, newTable func() *HashTable[int, int]) {
+		r := rand.New(rand.NewPCG(1, 2))
+		ht := newTable()
+		want := make(map[int]int)
+		for i := 0; i < 5000; i++ {
+			k := r.IntN(300)
+			switch op := r.IntN(10); {
+			case op < 5:
+				ht.Insert(k, i)
+				want[k] = i
+			case op < 8:
+				_, wantOK := want[k]
+				if _, ok := ht.Delete(k); ok != wantOK {
+					t.Fatalf("Delete(%d) = %v, want %v", k, ok, wantOK)
+				}
+				delete(want, k)
+			default:
+				v, ok := ht.Search(k)
+				if wv, wantOK := want[k]; ok != wantOK || v != wv {
+					t.Fatalf("Search(%d) = %d, %v, want %d, %v", k, v, ok, wv, wantOK)
+				}
+			}
+			if i%500 == 0 {
+				checkContents(t, ht, want)
+			}
+		}
+		checkContents(t, ht, want)
+	})
+}
+
+func TestStorage_BulkOperations(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, newTable func() *HashTable[int, int]) {
+		ht := newTable()
+		ht.Reserve(500)
+		want := make(map[int]int)
+		for k := 0; k < 500; k++ {
+			ht.Insert(k, k*10)
+			want[k] = k * 10
+		}
+		checkContents(t, ht, want)
+
+		if n := ht.DeleteFunc(func(k, _ int) bool { return k%3 == 0 }); n != 167 {
+			t.Fatalf("DeleteFunc removed %d entries, want 167", n)
+		}
+		maps.DeleteFunc(want, func(k, _ int) bool { return k%3 == 0 })
+		checkContents(t, ht, want)
+
+		ht.Compact()
+		checkContents(t, ht, want)
+		ht.DeleteFunc(func(k, _ int) bool { return k >= 50 })
+		maps.DeleteFunc(want, func(k, _ int) bool { return k >= 50 })
+		ht.Shrink()
+		checkContents(t, ht, want)
+		ht.Rehash(HasherFunc[int](func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 }))
+		checkContents(t, ht, want)
+		checkContents(t, ht.Clone(), want)
+
+		sample := ht.RandomSample(10)
+		seen := make(map[int]bool)
+		for _, e := range sample {
+			if seen[e.Key] || want[e.Key] != e.Value {
+				t.Fatalf("RandomSample returned %v", sample)
+			}
+			seen[e.Key] = true
+		}
+		if len(sample) != 10 {
+			t.Fatalf("RandomSample(10) returned %d entries", len(sample))
+		}
+
+		paged := make(map[int]int)
+		for page, cursor := ht.Iterate(0, 7); ; page, cursor = ht.Iterate(cursor, 7) {
+			for _, e := range page {
+				paged[e.Key] = e.Value
+			}
+			if cursor == 0 {
+				break
+			}
+		}
+		if !maps.Equal(paged, want) {
+			t.Fatalf("Iterate returned %v, want %v", paged, want)
+		}
+
+		chunked := make(map[int]int)
+		for _, chunk := range ht.Chunks(3) {
+			for k, v := range chunk {
+				chunked[k] = v
+			}
+		}
+		if !maps.Equal(chunked, want) {
+			t.Fatalf("Chunks yielded %v, want %v", chunked, want)
+		}
+
+		drained := 0
+		for k := range ht.Drain() {
+			if ht.Contains(k) {
+				t.Fatalf("key %d still stored after being drained", k)
+			}
+			drained++
+		}
+		if drained != len(want) || ht.Len() != 0 {
+			t.Fatalf("drained %d entries leaving %d, want %d leaving 0", drained, ht.Len(), len(want))
+		}
+		ht.Insert(1, 1)
+		ht.Clear()
+		checkContents(t, ht, map[int]int{})
+	})
+}
+
+func TestRobinHood_Distances(t *testing.T) {
+	ht := New(4, WithRobinHood[int, int]())
+	for k := 0; k < 20000; k++ {
+		ht.Insert(k, k)
+	}
+	ht.DeleteFunc(func(k, _ int) bool { return k%4 == 0 })
+	for k := 20000; k < 25000; k++ {
+		ht.Insert(k, k)
+	}
+
+	rh := ht.store.(*robinHood[int, int])
+	mask := uint64(len(rh.entries) - 1)
+	longest := uint32(0)
+	for i, d := range rh.dist {
+		if d == 0 {
+			continue
+		}
+		// backward-shift deletion must keep every recorded distance exact
+		if want := (uint64(i)-rh.entries[i].hash)&mask + 1; uint64(d) != want {
+			t.Fatalf("slot %d records distance %d, want %d", i, d, want)
+		}
+		longest = max(longest, d)
+	}
+	if longest > 32 {
+		t.Errorf("longest probe distance is %d at load %d/%d", longest, ht.Len(), len(rh.entries))
+	}
+}
+
+func TestCuckoo_Slots(t *testing.T) {
+	ht := New(4, WithCuckoo[int, int]())
+	for k := 0; k < 20000; k++ {
+		ht.Insert(k, k)
+	}
+	ht.DeleteFunc(func(k, _ int) bool { return k%4 == 0 })
+	for k := 20000; k < 25000; k++ {
+		ht.Insert(k, k)
+	}
+
+	c := ht.store.(*cuckoo[int, int])
+	for side := range c.tables {
+		for i, data := range c.tables[side] {
+			// a lookup only reads the slot index gives for each table
+			if c.used[side][i] && c.index(side, data.hash) != i {
+				t.Fatalf("key %d is in slot %d of table %d, want slot %d", data.Key, i, side, c.index(side, data.hash))
+			}
+		}
+	}
+	if len(c.stash) > stashLimit {
+		t.Errorf("stash holds %d entries, want at most %d", len(c.stash), stashLimit)
+	}
+	if ht.Len() > len(c.tables[0]) {
+		t.Errorf("%d entries in %d slots is more than half full", ht.Len(), 2*len(c.tables[0]))
+	}
+}
+
+func TestHopscotch_Neighborhoods(t *testing.T) {
+	ht := New(4, WithHopscotch[int, int]())
+	for k := 0; k < 20000; k++ {
+		ht.Insert(k, k)
+	}
+	ht.DeleteFunc(func(k, _ int) bool { return k%4 == 0 })
+	for k := 20000; k < 25000; k++ {
+		ht.Insert(k, k)
+	}
+
+	hs := ht.store.(*hopscotch[int, int])
+	mask := len(hs.entries) - 1
+	held := 0
+	for home, hop := range hs.hops {
+		for ; hop != 0; hop &= hop - 1 {
+			i := (home + bits.TrailingZeros32(hop)) & mask
+			if !hs.used[i] || hs.home(hs.entries[i].hash) != home {
+				t.Fatalf("slot %d is in the neighborhood bitmap of slot %d but does not hold one of its entries", i, home)
+			}
+			held++
+		}
+	}
+	if held != ht.Len() {
+		t.Errorf("neighborhood bitmaps name %d entries, table holds %d", held, ht.Len())
+	}
+	if len(hs.overflow) > 0 {
+		t.Errorf("%d entries spilled with a good Hasher", len(hs.overflow))
+	}
+}
+
+func TestSwiss_Match(t *testing.T) {
+	// control bytes, lowest slot first: full(0x12), empty, deleted, full(0x7f),
+	// full(0x12), empty, full(0x00), deleted
+	w := uint64(0xfe_00_80_12_7f_fe_80_12)
+	if got := matchByte(w, 0x12); got&0x80 == 0 || got&0x80_00_00_00_00 == 0 {
+		t.Errorf("matchByte(0x12) = %#x, want slots 0 and 4 flagged", got)
+	}
+	if got := matchEmpty(w); got != 0x00_00_80_00_00_00_80_00 {
+		t.Errorf("matchEmpty = %#x, want slots 1 and 5", got)
+	}
+	if got := matchFree(w); got != 0x80_00_80_00_00_80_80_00 {
+		t.Errorf("matchFree = %#x, want slots 1, 2, 5 and 7", got)
+	}
+}
+
+func TestWithBackend(t *testing.T) {
+	for b := Chaining; b <= FlatChaining; b++ {
+		ht := New(4, WithBackend[int, int](b))
+		if got := ht.layout.backend; got != b {
+			t.Errorf("WithBackend(%v) selected %v", b, got)
+		}
+		for k := 0; k < 100; k++ {
+			ht.Insert(k, k)
+		}
+		checkContents(t, ht, ToMap(ht))
+	}
+	if s := Backend(42).String(); s != "Backend(42)" {
+		t.Errorf("String() of an unknown Backend = %q", s)
+	}
+}
+
+// BenchmarkBackends compares the storage engines on tables of 2^16 int keys.
+func BenchmarkBackends(b *testing.B) {
+	const n = 1 << 16
+	for backend := Chaining; backend <= FlatChaining; backend++ {
+		opt := WithBackend[int, int](backend)
+		full := New(n, opt)
+		for k := 0; k < n; k++ {
+			full.Insert(k, k)
+		}
+
+		b.Run(backend.String()+"/Insert", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				ht := New(8, opt)
+				for k := 0; k < n; k++ {
+					ht.Insert(k, k)
+				}
+			}
+		})
+		b.Run(backend.String()+"/Hit", func(b *testing.B) {
+			k := 0
+			for b.Loop() {
+				full.Search(k & (n - 1))
+				k++
+			}
+		})
+		b.Run(backend.String()+"/Miss", func(b *testing.B) {
+			k := n
+			for b.Loop() {
+				full.Search(k)
+				k++
+			}
+		})
+		b.Run(backend.String()+"/Churn", func(b *testing.B) {
+			ht := full.Clone()
+			k := 0
+			for b.Loop() {
+				ht.Delete(k)
+				ht.Insert(k+n, k)
+				k++
+			}
+		})
+	}
+}
+
+// BenchmarkGC measures a garbage collection with a table of 2^20 pointer-free
+// entries alive, stored in buckets and flat.
+func BenchmarkGC(b *testing.B) {
+	for _, opt := range []struct {
+		name string
+		opt  Option[int, [2]float64]
+	}{
+		{"Chaining", WithBackend[int, [2]float64](Chaining)},
+		{"PointerFree", WithPointerFreeStorage[int, [2]float64]()},
+	} {
+		b.Run(opt.name, func(b *testing.B) {
+			ht := New(8, opt.opt)
+			for k := 0; k < 1<<20; k++ {
+				ht.Insert(k, [2]float64{})
+			}
+			for b.Loop() {
+				runtime.GC()
+			}
+			runtime.KeepAlive(ht)
+		})
+	}
+}
+
+func TestStorage_IterateWhileModified(t *testing.T) {
+	// these storages move entries when other keys are inserted or deleted
+	moving := map[string]bool{"robin hood": true, "cuckoo": true, "hopscotch": true}
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			ht := New(4, b.opts...)
+			// leave room for the churn below, so that the table is never rebuilt
+			ht.Reserve(1000)
+			for k := 0; k < 200; k++ {
+				ht.Insert(k, k)
+			}
+			churn := !moving[b.name]
+			seen := make(map[int]int)
+			next := 1000
+			for page, cursor := ht.Iterate(0, 5); ; page, cursor = ht.Iterate(cursor, 5) {
+				for _, e := range page {
+					seen[e.Key]++
+				}
+				if cursor == 0 {
+					break
+				}
+				if churn {
+					// keys come and go between pages
+					ht.Delete(next - 3)
+					ht.Insert(next, next)
+					next++
+				}
+			}
+			for k := 0; k < 200; k++ {
+				if seen[k] != 1 {
+					t.Fatalf("Iterate returned key %d %d times, want once", k, seen[k])
+				}
+			}
+		})
+	}
+}
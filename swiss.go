@@ -0,0 +1,250 @@
+package hashtable
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Control bytes of a swiss table. A full slot holds the low seven bits of its
+// entry's hash, so its control byte never has the high bit set.
+const (
+	ctrlEmpty   uint8 = 0x80
+	ctrlDeleted uint8 = 0xfe
+)
+
+// groupSize is the number of slots whose control bytes a swiss table probe examines
+// together.
+const groupSize = 16
+
+const (
+	lsbs = 0x0101010101010101
+	msbs = 0x8080808080808080
+)
+
+// swiss is an open-addressing storage in the style of Abseil's flat_hash_map and
+// Go's built-in maps. Slots are divided into groups of 16, and a probe visits whole
+// groups: the 7-bit fingerprint of the key is compared with all 16 control bytes
+// at once, eight bytes per machine word, and only slots whose fingerprint matches
+// have their keys compared. Groups are probed quadratically. The table is kept at
+// most seven eighths full.
+type swiss[K any, V any] struct {
+	equal      func(a, b K) bool
+	entries    []kv[K, V]
+	ctrl       []uint8
+	size       int
+	tombstones int
+}
+
+func newSwiss[K any, V any](l layout[K], n int) *swiss[K, V] {
+	t := &swiss[K, V]{equal: l.equal}
+	t.allocate(max(groupSize, powerOfTwo(n)))
+	return t
+}
+
+// allocate replaces the slots with n empty ones.
+func (t *swiss[K, V]) allocate(n int) {
+	t.entries = make([]kv[K, V], n)
+	t.ctrl = make([]uint8, n)
+	for i := range t.ctrl {
+		t.ctrl[i] = ctrlEmpty
+	}
+	t.tombstones = 0
+}
+
+// fingerprint returns the part of h stored in a control byte.
+func fingerprint(h uint64) uint8 {
+	return uint8(h & 0x7f)
+}
+
+// probe returns the first group to visit for h. Group i of the probe sequence is
+// the first plus the i-th triangular number, which visits every group once.
+func (t *swiss[K, V]) probe(h uint64) (group, mask uint64) {
+	mask = uint64(len(t.entries)/groupSize - 1)
+	return (h >> 7) & mask, mask
+}
+
+// matchByte returns a word with the high bit set in each byte of w equal to b. It
+// may also flag a byte directly above a true match, which callers rule out by
+// comparing hashes.
+func matchByte(w uint64, b uint8) uint64 {
+	x := w ^ (lsbs * uint64(b))
+	return (x - lsbs) &^ x & msbs
+}
+
+// matchEmpty returns a word with the high bit set in each byte of w that is ctrlEmpty.
+func matchEmpty(w uint64) uint64 {
+	return w &^ (w << 6) & msbs
+}
+
+// matchFree returns a word with the high bit set in each byte of w that is empty or
+// deleted.
+func matchFree(w uint64) uint64 {
+	return w & msbs
+}
+
+// word returns the control bytes of slots i to i+7.
+func (t *swiss[K, V]) word(i uint64) uint64 {
+	return binary.LittleEndian.Uint64(t.ctrl[i : i+8])
+}
+
+// locate returns the slot holding key, or -1.
+func (t *swiss[K, V]) locate(key K, h uint64) int {
+	fp := fingerprint(h)
+	g, mask := t.probe(h)
+	for step := uint64(1); ; step++ {
+		base := g * groupSize
+		empty := false
+		for half := uint64(0); half < groupSize; half += 8 {
+			w := t.word(base + half)
+			for m := matchByte(w, fp); m != 0; m &= m - 1 {
+				i := base + half + uint64(bits.TrailingZeros64(m)/8)
+				if t.entries[i].hash == h && t.equal(key, t.entries[i].Key) {
+					return int(i)
+				}
+			}
+			empty = empty || matchEmpty(w) != 0
+		}
+		// a key is never stored beyond a group that has an empty slot
+		if empty {
+			return -1
+		}
+		g = (g + step) & mask
+	}
+}
+
+func (t *swiss[K, V]) find(key K, h uint64) *kv[K, V] {
+	i := t.locate(key, h)
+	if i < 0 {
+		return nil
+	}
+	return &t.entries[i]
+}
+
+func (t *swiss[K, V]) add(key K, h uint64, value V) int {
+	if 8*(t.size+t.tombstones+1) > 7*len(t.entries) {
+		// rebuilding at the same size is enough when tombstones fill the table
+		t.resize(max(len(t.entries), slotsFor(t.size+1, 7, 8)))
+	}
+	t.size++
+	return t.place(kv[K, V]{Key: key, Value: value, hash: h})
+}
+
+// place puts data in the first free slot of its probe sequence and returns the
+// number of slots passed over to reach it, plus one.
+func (t *swiss[K, V]) place(data kv[K, V]) int {
+	g, mask := t.probe(data.hash)
+	for step := uint64(1); ; step++ {
+		base := g * groupSize
+		for half := uint64(0); half < groupSize; half += 8 {
+			if m := matchFree(t.word(base + half)); m != 0 {
+				i := base + half + uint64(bits.TrailingZeros64(m)/8)
+				if t.ctrl[i] == ctrlDeleted {
+					t.tombstones--
+				}
+				t.entries[i] = data
+				t.ctrl[i] = fingerprint(data.hash)
+				return int(step-1)*groupSize + int(i-base) + 1
+			}
+		}
+		g = (g + step) & mask
+	}
+}
+
+func (t *swiss[K, V]) remove(key K, h uint64) (kv[K, V], bool) {
+	i := t.locate(key, h)
+	if i < 0 {
+		return kv[K, V]{}, false
+	}
+	data := t.entries[i]
+	t.removeAt(i)
+	t.size--
+	return data, true
+}
+
+// removeAt frees slot i. Keys are only stored beyond full groups, so when the group
+// of i already has an empty slot no probe depends on i staying occupied, and it can
+// become empty instead of a tombstone.
+func (t *swiss[K, V]) removeAt(i int) {
+	// zero the slot so the garbage collector can reclaim what it references
+	t.entries[i] = kv[K, V]{}
+	base := uint64(i / groupSize * groupSize)
+	if matchEmpty(t.word(base))|matchEmpty(t.word(base+8)) != 0 {
+		t.ctrl[i] = ctrlEmpty
+		return
+	}
+	t.ctrl[i] = ctrlDeleted
+	t.tombstones++
+}
+
+func (t *swiss[K, V]) len() int {
+	return t.size
+}
+
+func (t *swiss[K, V]) clear() {
+	clear(t.entries)
+	for i := range t.ctrl {
+		t.ctrl[i] = ctrlEmpty
+	}
+	t.size = 0
+	t.tombstones = 0
+}
+
+func (t *swiss[K, V]) all(yield func(*kv[K, V]) bool) {
+	for i := range t.entries {
+		if t.ctrl[i] < ctrlEmpty && !yield(&t.entries[i]) {
+			return
+		}
+	}
+}
+
+func (t *swiss[K, V]) deleteFunc(del func(*kv[K, V]) bool) int {
+	removed := 0
+	for i := range t.entries {
+		if t.ctrl[i] < ctrlEmpty && del(&t.entries[i]) {
+			t.removeAt(i)
+			removed++
+		}
+	}
+	t.size -= removed
+	return removed
+}
+
+func (t *swiss[K, V]) slots() (int, int) {
+	return len(t.entries), 1
+}
+
+func (t *swiss[K, V]) at(p, d int) *kv[K, V] {
+	if d > 0 || t.ctrl[p] >= ctrlEmpty {
+		return nil
+	}
+	return &t.entries[p]
+}
+
+func (t *swiss[K, V]) reserve(n int) {
+	if slots := max(groupSize, slotsFor(n, 7, 8)); slots > len(t.entries) {
+		t.resize(slots)
+	}
+}
+
+func (t *swiss[K, V]) compact() {
+	if t.tombstones > 0 {
+		t.resize(len(t.entries))
+	}
+}
+
+// shrink lowers the number of slots to what the entries need at half the maximum
+// load, then clears any tombstones.
+func (t *swiss[K, V]) shrink() {
+	t.resize(min(len(t.entries), max(groupSize, slotsFor(2*t.size, 7, 8))))
+}
+
+// resize moves every entry into a new array of n slots, dropping all tombstones.
+func (t *swiss[K, V]) resize(n int) {
+	entries, ctrl := t.entries, t.ctrl
+	t.allocate(n)
+	for i := range entries {
+		if ctrl[i] < ctrlEmpty {
+			t.place(entries[i])
+		}
+	}
+}
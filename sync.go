@@ -0,0 +1,619 @@
+package hashtable
+
+import (
+	"iter"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jkittell/array"
+)
+
+// A SyncHashTable is a HashTable that is safe for concurrent use by multiple
+// goroutines. Every method locks the table for its duration: methods that only read
+// share a sync.RWMutex read lock, and methods that modify the table hold it
+// exclusively. Tables created with WithIncrementalRehash advance their rehash on
+// lookups, so for them every method locks exclusively.
+//
+// Callbacks passed to methods, such as the fn of Update or the pred of Any, run with
+// the lock held and must not call methods of the same SyncHashTable. Range and All
+// are the exception: they copy the entries under the lock and then visit the copy,
+// so the loop body may call any method, and an iteration can never observe the
+// table halfway through a rehash. Every key stored when the iteration starts is
+// visited exactly once, with the value it held then; changes made while the loop
+// runs are not seen.
+type SyncHashTable[K any, V any] struct {
+	mu sync.RWMutex
+	ht *HashTable[K, V]
+	// exclusive makes read methods take the write lock, for tables whose lookups
+	// modify their storage.
+	exclusive bool
+	keys      keyLocks[K]
+	// versions holds the version of every key written since the first call of
+	// SearchVersioned or InsertIfVersion, or nil before it, and clock the last
+	// version handed out. Keys stored but missing from versions have version 1.
+	// The versions of keys that left the table by expiring or being evicted are
+	// pruned by touch.
+	versions *HashTable[K, uint64]
+	clock    uint64
+	// owners, if set, counts the SyncHashTables that share ht since a Clone made
+	// without copying it. ht must not be modified while it is shared; lock gives s
+	// its own copy first.
+	owners *atomic.Int32
+	// flights holds the GetOrCompute loads in progress, guarded by flightMu, which
+	// is never acquired while mu is held.
+	flightMu sync.Mutex
+	flights  *HashTable[K, *flight[V]]
+	// async applies the writes queued by AsyncInsert once started by asyncOnce.
+	asyncOnce sync.Once
+	async     atomic.Pointer[asyncApplier[Entry[K, V]]]
+	// locks counts the acquisitions of mu, and contended those that had to wait for
+	// another goroutine to release it, for ShardStats.
+	locks, contended atomic.Uint64
+	// janitor holds the goroutine started by StartJanitor.
+	janitor janitorSlot
+	// writes, if set, passes the changes made to s to a Writer, through the queue
+	// started by behindOnce under WithWriteBehind.
+	writes     *writeOptions[K, V]
+	behindOnce sync.Once
+	behind     atomic.Pointer[asyncApplier[Change[K, V]]]
+}
+
+// NewSync creates a SyncHashTable with n number of internal buckets, configured by
+// opts like New.
+func NewSync[K comparable, V any](numberOfBuckets int, opts ...Option[K, V]) *SyncHashTable[K, V] {
+	return newSync(New(numberOfBuckets, opts...))
+}
+
+// NewSyncFunc creates a SyncHashTable for keys that are not comparable with ==,
+// hashed with hash and compared with equal like NewFunc.
+func NewSyncFunc[K any, V any](numberOfBuckets int, hash func(K) uint64, equal func(a, b K) bool, opts ...Option[K, V]) *SyncHashTable[K, V] {
+	return newSync(NewFunc(numberOfBuckets, hash, equal, opts...))
+}
+
+func newSync[K any, V any](ht *HashTable[K, V]) *SyncHashTable[K, V] {
+	s := &SyncHashTable[K, V]{ht: ht, exclusive: ht.layout.incremental, writes: ht.writes}
+	// clones of s wrap ht in turn, and must not write to the Writer of s
+	ht.writes = nil
+	s.keys.init(ht.hasher, ht.normalize, keyStripes)
+	return s
+}
+
+// rlock locks s for reading and returns the matching unlock function.
+func (s *SyncHashTable[K, V]) rlock() func() {
+	if s.exclusive {
+		s.acquire()
+		return s.mu.Unlock
+	}
+	if !s.mu.TryRLock() {
+		s.contended.Add(1)
+		s.mu.RLock()
+	}
+	s.locks.Add(1)
+	return s.mu.RUnlock
+}
+
+// acquire locks s for writing, without taking a copy of a shared ht.
+func (s *SyncHashTable[K, V]) acquire() {
+	if !s.mu.TryLock() {
+		s.contended.Add(1)
+		s.mu.Lock()
+	}
+	s.locks.Add(1)
+}
+
+// lock locks s for writing. If ht is shared with a clone, s first takes a copy of
+// its own.
+func (s *SyncHashTable[K, V]) lock() {
+	s.acquire()
+	s.own()
+}
+
+// own replaces ht with a copy if it is shared with a clone. The caller must hold
+// the write lock.
+func (s *SyncHashTable[K, V]) own() {
+	if s.owners == nil {
+		return
+	}
+	if s.owners.Load() > 1 {
+		s.ht = s.ht.Clone()
+		s.owners.Add(-1)
+	}
+	s.owners = nil
+}
+
+// Insert is like HashTable.Insert.
+func (s *SyncHashTable[K, V]) Insert(key K, value V) {
+	s.lock()
+	defer s.mu.Unlock()
+	s.ht.Insert(key, value)
+	s.touch(key)
+}
+
+// GetOrInsert is like HashTable.GetOrInsert. Checking for key and inserting value
+// happen under one lock, so concurrent callers agree on the stored value.
+func (s *SyncHashTable[K, V]) GetOrInsert(key K, value V) (V, bool) {
+	s.lock()
+	defer s.mu.Unlock()
+	actual, loaded := s.ht.GetOrInsert(key, value)
+	if !loaded {
+		s.touch(key)
+	}
+	return actual, loaded
+}
+
+// Upsert is like HashTable.Upsert.
+func (s *SyncHashTable[K, V]) Upsert(key K, value V, merge func(old, new V) V) V {
+	s.lock()
+	defer s.mu.Unlock()
+	defer s.touch(key)
+	return s.ht.Upsert(key, value, merge)
+}
+
+// Update is like HashTable.Update. The read, fn and the write happen under one
+// lock, so concurrent updates of the same key are never lost.
+func (s *SyncHashTable[K, V]) Update(key K, fn func(V) V) V {
+	s.lock()
+	defer s.mu.Unlock()
+	defer s.touch(key)
+	return s.ht.Update(key, fn)
+}
+
+// ComputeIfAbsent is like HashTable.ComputeIfAbsent. fn is called at most once per
+// missing key, with the lock held.
+func (s *SyncHashTable[K, V]) ComputeIfAbsent(key K, fn func(K) V) V {
+	s.lock()
+	defer s.mu.Unlock()
+	computed := false
+	value := s.ht.ComputeIfAbsent(key, func(key K) V {
+		computed = true
+		return fn(key)
+	})
+	if computed {
+		// the value is stored once fn returns
+		s.touch(key)
+	}
+	return value
+}
+
+// Swap is like HashTable.Swap.
+func (s *SyncHashTable[K, V]) Swap(key K, value V) (V, bool) {
+	s.lock()
+	defer s.mu.Unlock()
+	defer s.touch(key)
+	return s.ht.Swap(key, value)
+}
+
+// CompareAndSwapFunc is like HashTable.CompareAndSwapFunc.
+func (s *SyncHashTable[K, V]) CompareAndSwapFunc(key K, old, value V, eq func(a, b V) bool) bool {
+	s.lock()
+	defer s.mu.Unlock()
+	if !s.ht.CompareAndSwapFunc(key, old, value, eq) {
+		return false
+	}
+	s.touch(key)
+	return true
+}
+
+// CompareAndDeleteFunc is like HashTable.CompareAndDeleteFunc.
+func (s *SyncHashTable[K, V]) CompareAndDeleteFunc(key K, old V, eq func(a, b V) bool) bool {
+	s.lock()
+	defer s.mu.Unlock()
+	if !s.ht.CompareAndDeleteFunc(key, old, eq) {
+		return false
+	}
+	s.forget(key)
+	return true
+}
+
+// Delete is like HashTable.Delete.
+func (s *SyncHashTable[K, V]) Delete(key K) (V, bool) {
+	s.lock()
+	defer s.mu.Unlock()
+	value, ok := s.ht.Delete(key)
+	if ok {
+		s.forget(key)
+	}
+	return value, ok
+}
+
+// Pop is like HashTable.Pop.
+func (s *SyncHashTable[K, V]) Pop(key K) (V, bool) {
+	return s.Delete(key)
+}
+
+// LockKey locks key, blocking until no other goroutine holds the lock of key, so a
+// caller can read, compute and write the value of key in several steps without
+// holding the lock of the whole table in between. Key locks are advisory: they
+// exclude only other callers of LockKey and WithKeyLocked, not the other methods.
+// Keys share a fixed set of locks, so a goroutine holding the lock of one key must
+// not lock another, which may share it.
+func (s *SyncHashTable[K, V]) LockKey(key K) {
+	s.keys.stripe(key).Lock()
+}
+
+// UnlockKey unlocks key, which must have been locked with LockKey.
+func (s *SyncHashTable[K, V]) UnlockKey(key K) {
+	s.keys.stripe(key).Unlock()
+}
+
+// WithKeyLocked calls fn with key locked as by LockKey. fn may call any method of s.
+func (s *SyncHashTable[K, V]) WithKeyLocked(key K, fn func()) {
+	s.LockKey(key)
+	defer s.UnlockKey(key)
+	fn()
+}
+
+// SearchVersioned is like Search, also returning the version of key: a number that
+// changes whenever key is written or deleted, and is 0 when key is not stored. A
+// caller can read a value, compute a new one without holding any lock, and store
+// it with InsertIfVersion, which fails if another goroutine wrote key meanwhile.
+func (s *SyncHashTable[K, V]) SearchVersioned(key K) (V, uint64, bool) {
+	unlock := s.rlock()
+	if s.versions == nil {
+		// versions starts to be recorded from here on, which takes the write lock
+		unlock()
+		s.acquire()
+		unlock = s.mu.Unlock
+		s.startVersions()
+	}
+	defer unlock()
+	value, ok := s.ht.peek(key)
+	return value, s.version(key, ok), ok
+}
+
+// InsertIfVersion stores value for key only if the version of key is still
+// version, as returned by SearchVersioned; a version of 0 stores value only if key
+// is not stored. It returns the new version of key and whether value was stored.
+func (s *SyncHashTable[K, V]) InsertIfVersion(key K, value V, version uint64) (uint64, bool) {
+	s.lock()
+	defer s.mu.Unlock()
+	s.startVersions()
+	key, _, e := s.ht.find(key)
+	if current := s.version(key, e != nil); current != version {
+		return current, false
+	}
+	if e != nil {
+		e.Value = value
+	} else {
+		s.ht.Insert(key, value)
+	}
+	s.touch(key)
+	return s.clock, true
+}
+
+// startVersions starts recording versions if they are not recorded yet. The caller
+// must hold the write lock.
+func (s *SyncHashTable[K, V]) startVersions() {
+	if s.versions == nil {
+		s.versions = newLike[K, V, uint64](s.ht)
+		s.clock = 1
+	}
+}
+
+// version returns the version of key, given whether it is stored.
+func (s *SyncHashTable[K, V]) version(key K, stored bool) uint64 {
+	if !stored {
+		return 0
+	}
+	if version, ok := s.versions.Search(key); ok {
+		return version
+	}
+	return 1
+}
+
+// touch gives key, which has just been written, a new version, if versions are
+// recorded, and passes the write to the Writer of s. The caller must hold the
+// write lock.
+func (s *SyncHashTable[K, V]) touch(key K) {
+	if s.versions != nil {
+		s.clock++
+		s.versions.Insert(key, s.clock)
+		s.pruneVersions()
+	}
+	if s.writes != nil {
+		if e := s.ht.lookup(key); e != nil {
+			s.record(Change[K, V]{Key: e.Key, Value: e.Value})
+		}
+	}
+}
+
+// forget drops the version of key, which has just been deleted, and passes the
+// deletion to the Writer of s. The caller must hold the write lock.
+func (s *SyncHashTable[K, V]) forget(key K) {
+	if s.versions != nil {
+		s.versions.Delete(key)
+	}
+	if s.writes != nil {
+		if s.ht.normalize != nil {
+			key = s.ht.normalize(key)
+		}
+		s.record(Change[K, V]{Key: key, Deleted: true})
+	}
+}
+
+// pruneVersions drops the versions of keys no longer stored once versions holds
+// more than twice as many keys as ht, so that keys which expire or are evicted,
+// rather than deleted, do not make versions grow without bound. The caller must
+// hold the write lock.
+func (s *SyncHashTable[K, V]) pruneVersions() {
+	if s.versions.Len() <= 2*s.ht.Len()+minPrunedVersions {
+		return
+	}
+	s.versions.DeleteFunc(func(key K, _ uint64) bool {
+		return s.ht.lookup(key) == nil
+	})
+}
+
+// minPrunedVersions is the number of versions beyond twice the length of the table
+// that pruneVersions lets accumulate, so small tables are not pruned on every write.
+const minPrunedVersions = 16
+
+// forgetting returns del, extended to forget every key it deletes.
+func (s *SyncHashTable[K, V]) forgetting(del func(K, V) bool) func(K, V) bool {
+	if s.versions == nil && s.writes == nil {
+		return del
+	}
+	return func(key K, value V) bool {
+		if !del(key, value) {
+			return false
+		}
+		s.forget(key)
+		return true
+	}
+}
+
+// Txn runs fn as a transaction, with s locked until fn returns, and applies the
+// puts and deletes fn makes through tx only if fn returns nil. It returns the error
+// of fn.
+func (s *SyncHashTable[K, V]) Txn(fn func(tx *Txn[K, V]) error) error {
+	return runTxn([]*SyncHashTable[K, V]{s}, func(K) int { return 0 }, fn)
+}
+
+// Search is like HashTable.Search. An entry whose TTL has run out is left for the
+// next write of its key to reclaim, since readers share the lock.
+func (s *SyncHashTable[K, V]) Search(key K) (V, bool) {
+	defer s.rlock()()
+	return s.ht.peek(key)
+}
+
+// GetOrDefault is like HashTable.GetOrDefault.
+func (s *SyncHashTable[K, V]) GetOrDefault(key K, fallback V) V {
+	defer s.rlock()()
+	if value, ok := s.ht.peek(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// Contains is like HashTable.Contains.
+func (s *SyncHashTable[K, V]) Contains(key K) bool {
+	defer s.rlock()()
+	return s.ht.lookup(key) != nil
+}
+
+// Len is like HashTable.Len.
+func (s *SyncHashTable[K, V]) Len() int {
+	defer s.rlock()()
+	return s.ht.Len()
+}
+
+// Keys is like HashTable.Keys.
+func (s *SyncHashTable[K, V]) Keys() array.Array[K] {
+	defer s.rlock()()
+	return s.ht.Keys()
+}
+
+// Values is like HashTable.Values.
+func (s *SyncHashTable[K, V]) Values() array.Array[V] {
+	defer s.rlock()()
+	return s.ht.Values()
+}
+
+// Entries is like HashTable.Entries.
+func (s *SyncHashTable[K, V]) Entries() []Entry[K, V] {
+	defer s.rlock()()
+	return s.ht.Entries()
+}
+
+// Clear is like HashTable.Clear.
+func (s *SyncHashTable[K, V]) Clear() {
+	s.acquire()
+	defer s.mu.Unlock()
+	if s.owners != nil && s.owners.Load() > 1 {
+		// none of the shared entries are kept, so there is no need to copy them
+		s.owners.Add(-1)
+		s.owners = nil
+		s.ht.evictAll()
+		cleared := newLike[K, V, V](s.ht)
+		cleared.inheritLimits(s.ht)
+		s.ht = cleared
+	} else {
+		s.own()
+		s.ht.Clear()
+	}
+	if s.versions != nil {
+		s.versions.Clear()
+	}
+}
+
+// Rehash is like HashTable.Rehash.
+func (s *SyncHashTable[K, V]) Rehash(h Hasher[K]) {
+	s.lock()
+	defer s.mu.Unlock()
+	s.ht.Rehash(h)
+}
+
+// Compact is like HashTable.Compact.
+func (s *SyncHashTable[K, V]) Compact() {
+	s.lock()
+	defer s.mu.Unlock()
+	s.ht.Compact()
+	if s.versions != nil {
+		s.versions.Compact()
+	}
+}
+
+// Shrink is like HashTable.Shrink.
+func (s *SyncHashTable[K, V]) Shrink() {
+	s.lock()
+	defer s.mu.Unlock()
+	s.ht.Shrink()
+	if s.versions != nil {
+		s.versions.Shrink()
+	}
+}
+
+// Reserve is like HashTable.Reserve.
+func (s *SyncHashTable[K, V]) Reserve(n int) {
+	s.lock()
+	defer s.mu.Unlock()
+	s.ht.Reserve(n)
+}
+
+// DeleteFunc is like HashTable.DeleteFunc.
+func (s *SyncHashTable[K, V]) DeleteFunc(del func(K, V) bool) int {
+	s.lock()
+	defer s.mu.Unlock()
+	return s.ht.DeleteFunc(s.forgetting(del))
+}
+
+// RetainFunc is like HashTable.RetainFunc.
+func (s *SyncHashTable[K, V]) RetainFunc(keep func(K, V) bool) int {
+	s.lock()
+	defer s.mu.Unlock()
+	return s.ht.DeleteFunc(s.forgetting(func(key K, value V) bool {
+		return !keep(key, value)
+	}))
+}
+
+// Merge inserts every entry of other into s like HashTable.Merge. The entries of
+// other are copied under its lock first, so two tables may merge into each other
+// concurrently without deadlocking.
+func (s *SyncHashTable[K, V]) Merge(other *SyncHashTable[K, V], resolve func(key K, a, b V) V) {
+	entries := other.Entries()
+	s.lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		s.ht.merge(e.Key, e.Value, resolve)
+		s.touch(e.Key)
+	}
+}
+
+// merge stores value for key under the lock of s, as Merge does for each entry.
+func (s *SyncHashTable[K, V]) merge(key K, value V, resolve func(key K, a, b V) V) {
+	s.lock()
+	defer s.mu.Unlock()
+	s.ht.merge(key, value, resolve)
+	s.touch(key)
+}
+
+// Range is like HashTable.Range, visiting a copy of the entries taken under the
+// lock when Range is called. fn may call any method of s.
+func (s *SyncHashTable[K, V]) Range(fn func(K, V) bool) {
+	for key, value := range s.All() {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over every key/value pair like HashTable.All. The entries
+// are copied under the lock when the loop starts, so the loop body may call any
+// method of s.
+func (s *SyncHashTable[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, e := range s.Entries() {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// SnapshotIter returns an iterator over a copy of the entries taken under the lock
+// when SnapshotIter is called, rather than when the loop starts as with All. The
+// loop body may call any method of s.
+func (s *SyncHashTable[K, V]) SnapshotIter() iter.Seq2[K, V] {
+	defer s.rlock()()
+	return s.ht.SnapshotIter()
+}
+
+// Iterate is like HashTable.Iterate. Each page is read under one lock; entries
+// inserted or deleted between pages may or may not be returned, and if the table
+// grows between pages, as inserts may make it, or its storage moves entries as
+// described for HashTable.Iterate, entries may be returned twice or not at all.
+// Use All when every entry must be seen exactly once.
+func (s *SyncHashTable[K, V]) Iterate(cursor Cursor, limit int) ([]Entry[K, V], Cursor) {
+	defer s.rlock()()
+	return s.ht.Iterate(cursor, limit)
+}
+
+// Any is like HashTable.Any.
+func (s *SyncHashTable[K, V]) Any(pred func(K, V) bool) bool {
+	defer s.rlock()()
+	return s.ht.Any(pred)
+}
+
+// Every is like HashTable.Every.
+func (s *SyncHashTable[K, V]) Every(pred func(K, V) bool) bool {
+	defer s.rlock()()
+	return s.ht.Every(pred)
+}
+
+// Find is like HashTable.Find.
+func (s *SyncHashTable[K, V]) Find(pred func(K, V) bool) (K, V, bool) {
+	defer s.rlock()()
+	return s.ht.Find(pred)
+}
+
+// Clone returns an independent copy of s in constant time: the copy shares the
+// entries of s until either table is next modified, and the table modified first
+// then copies them, so Clone never stalls the writers of s. Tables created with
+// WithIncrementalRehash modify their entries on lookups, so for them Clone copies
+// the entries under the lock right away.
+func (s *SyncHashTable[K, V]) Clone() *SyncHashTable[K, V] {
+	s.acquire()
+	defer s.mu.Unlock()
+	if s.exclusive {
+		return newSync(s.ht.Clone())
+	}
+	if s.owners == nil {
+		s.owners = new(atomic.Int32)
+		s.owners.Store(1)
+	}
+	s.owners.Add(1)
+	clone := newSync(s.ht)
+	clone.owners = s.owners
+	return clone
+}
+
+// Snapshot returns a copy of the table as a plain HashTable, made under the lock,
+// for callers that go on to read it from a single goroutine.
+func (s *SyncHashTable[K, V]) Snapshot() *HashTable[K, V] {
+	defer s.rlock()()
+	return s.ht.Clone()
+}
+
+// Filter is like HashTable.Filter, returning a new SyncHashTable.
+func (s *SyncHashTable[K, V]) Filter(keep func(K, V) bool) *SyncHashTable[K, V] {
+	defer s.rlock()()
+	return newSync(s.ht.Filter(keep))
+}
+
+// RandomSample is like HashTable.RandomSample.
+func (s *SyncHashTable[K, V]) RandomSample(n int) []Entry[K, V] {
+	defer s.rlock()()
+	return s.ht.RandomSample(n)
+}
+
+// TopN is like HashTable.TopN.
+func (s *SyncHashTable[K, V]) TopN(n int, less func(a, b V) bool) []Entry[K, V] {
+	defer s.rlock()()
+	return s.ht.TopN(n, less)
+}
+
+// Distribution is like HashTable.Distribution.
+func (s *SyncHashTable[K, V]) Distribution() Distribution {
+	defer s.rlock()()
+	return s.ht.Distribution()
+}
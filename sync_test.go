@@ -0,0 +1,262 @@
+package hashtable
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyncHashTable(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option[int, int]
+	}{
+		{"default", nil},
+		{"incremental", []Option[int, int]{WithIncrementalRehash[int, int]()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			const writers, perWriter = 8, 1000
+			s := NewSync(4, tt.opts...)
+			var wg sync.WaitGroup
+			for w := 0; w < writers; w++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					for k := w * perWriter; k < (w+1)*perWriter; k++ {
+						s.Insert(k, k)
+						s.Update(-1, func(v int) int { return v + 1 })
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					for k := 0; k < perWriter; k++ {
+						if v, ok := s.Search(k); ok && v != k {
+							t.Errorf("Search(%d) = %d", k, v)
+						}
+						s.Len()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got, want := s.Len(), writers*perWriter+1; got != want {
+				t.Fatalf("Len() = %d, want %d", got, want)
+			}
+			if v, _ := s.Search(-1); v != writers*perWriter {
+				t.Fatalf("concurrent Updates counted %d, want %d", v, writers*perWriter)
+			}
+			for k := range s.SnapshotIter() {
+				if k >= 0 && k%2 == 0 {
+					// the body may modify the table while ranging over a snapshot
+					s.Delete(k)
+				}
+			}
+			if got, want := s.Len(), writers*perWriter/2+1; got != want {
+				t.Fatalf("Len() after deleting even keys = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestSyncHashTable_Merge(t *testing.T) {
+	a, b := NewSync[string, int](4), NewSync[string, int](4)
+	a.Insert("x", 1)
+	b.Insert("x", 2)
+	b.Insert("y", 3)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	// merging in both directions at once must not deadlock
+	go func() { defer wg.Done(); a.Merge(b, func(_ string, x, y int) int { return x + y }) }()
+	go func() { defer wg.Done(); b.Merge(a, nil) }()
+	wg.Wait()
+
+	if v, _ := a.Search("y"); v != 3 || a.Len() != 2 || b.Len() != 2 {
+		t.Fatalf("after merging a = %v, b = %v", a.Entries(), b.Entries())
+	}
+}
+
+func TestSyncHashTable_Range(t *testing.T) {
+	const n = 1000
+	s := NewSync[int, int](1)
+	for k := 0; k < n; k++ {
+		s.Insert(k, k)
+	}
+	seen := make(map[int]int)
+	s.Range(func(k, v int) bool {
+		seen[k]++
+		// the body may modify the table, growing it past several rehashes
+		s.Insert(n+k, v)
+		s.Delete(n - 1 - k)
+		return true
+	})
+	if len(seen) != n {
+		t.Fatalf("Range visited %d keys, want %d", len(seen), n)
+	}
+	for k, times := range seen {
+		if times != 1 || k >= n {
+			t.Fatalf("Range visited key %d %d times", k, times)
+		}
+	}
+}
+
+func TestSyncHashTable_WithKeyLocked(t *testing.T) {
+	const goroutines, increments = 8, 500
+	s := NewSync[string, int](4)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				// a read and a separate write, made atomic by the key lock
+				s.WithKeyLocked("n", func() {
+					v, _ := s.Search("n")
+					s.Insert("n", v+1)
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := s.Search("n"); v != goroutines*increments {
+		t.Fatalf("counter = %d, want %d", v, goroutines*increments)
+	}
+}
+
+func TestSyncHashTable_InsertIfVersion(t *testing.T) {
+	s := NewSync[string, int](4)
+	s.Insert("a", 1)
+
+	// keys stored before versions were first asked for still have one
+	v, version, ok := s.SearchVersioned("a")
+	if !ok || v != 1 || version == 0 {
+		t.Fatalf(`SearchVersioned("a") = %d, %d, %v`, v, version, ok)
+	}
+	s.Update("a", func(v int) int { return v + 1 })
+	if _, ok := s.InsertIfVersion("a", 10, version); ok {
+		t.Fatal("InsertIfVersion succeeded after a concurrent Update")
+	}
+	_, version, _ = s.SearchVersioned("a")
+	next, ok := s.InsertIfVersion("a", 10, version)
+	if !ok || next == version {
+		t.Fatalf("InsertIfVersion with the current version = %d, %v", next, ok)
+	}
+	if _, ok := s.InsertIfVersion("a", 11, version); ok {
+		t.Fatal("InsertIfVersion succeeded twice with the same version")
+	}
+
+	// deleting and storing again must not bring an old version back
+	s.Delete("a")
+	if _, version, ok := s.SearchVersioned("a"); ok || version != 0 {
+		t.Fatalf(`SearchVersioned("a") after Delete = %d, %v`, version, ok)
+	}
+	if _, ok := s.InsertIfVersion("a", 1, 0); !ok {
+		t.Fatal("InsertIfVersion with version 0 did not store a missing key")
+	}
+	if _, ok := s.InsertIfVersion("a", 2, next); ok {
+		t.Fatal("InsertIfVersion succeeded with a version from before Delete")
+	}
+
+	// concurrent optimistic increments are never lost
+	const goroutines, increments = 8, 200
+	s.Insert("n", 0)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				for {
+					v, version, _ := s.SearchVersioned("n")
+					if _, ok := s.InsertIfVersion("n", v+1, version); ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := s.Search("n"); v != goroutines*increments {
+		t.Fatalf("counter = %d, want %d", v, goroutines*increments)
+	}
+}
+
+func TestSyncHashTable_Clone(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option[int, int]
+	}{
+		{"default", nil},
+		{"incremental", []Option[int, int]{WithIncrementalRehash[int, int]()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSync(4, tt.opts...)
+			for k := 0; k < 1000; k++ {
+				s.Insert(k, k)
+			}
+			a, b := s.Clone(), s.Clone()
+			c := a.Clone()
+
+			// the clones are read while s and a are written
+			var wg sync.WaitGroup
+			wg.Add(3)
+			go func() { defer wg.Done(); s.Insert(0, -1) }()
+			go func() { defer wg.Done(); a.Delete(1) }()
+			go func() {
+				defer wg.Done()
+				for range b.All() {
+				}
+				c.Search(1)
+			}()
+			wg.Wait()
+			b.Clear()
+
+			for _, check := range []struct {
+				name string
+				s    *SyncHashTable[int, int]
+				len  int
+				zero int
+				one  bool
+			}{
+				{"s", s, 1000, -1, true},
+				{"a", a, 999, 0, false},
+				{"b", b, 0, 0, false},
+				{"c", c, 1000, 0, true},
+			} {
+				zero, _ := check.s.Search(0)
+				if n := check.s.Len(); n != check.len || zero != check.zero || check.s.Contains(1) != check.one {
+					t.Errorf("%s: Len() = %d, [0] = %d, Contains(1) = %v; want %d, %d, %v", check.name, n, zero, check.s.Contains(1), check.len, check.zero, check.one)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncHashTable_VersionsPruned(t *testing.T) {
+	advance := fakeClock(t)
+	// keys that are evicted or expire, rather than being deleted, leave no version
+	// behind for long
+	bounded := NewSync(4, WithMaxEntries[int, int](4))
+	expiring := NewSync[int, int](4)
+	bounded.SearchVersioned(0)
+	expiring.SearchVersioned(0)
+	for k := 0; k < 1000; k++ {
+		bounded.Insert(k, k)
+		expiring.InsertWithTTL(k, k, time.Second)
+		if k%100 == 99 {
+			advance(time.Second)
+			expiring.DeleteExpired()
+		}
+	}
+	if n := bounded.versions.Len(); n > 2*4+minPrunedVersions {
+		t.Errorf("a table bounded to 4 keys holds %d versions", n)
+	}
+	if n := expiring.versions.Len(); n > 2*100+minPrunedVersions {
+		t.Errorf("a table of at most 100 live keys holds %d versions", n)
+	}
+	// the versions of stored keys are kept
+	_, version, _ := bounded.SearchVersioned(999)
+	if _, ok := bounded.InsertIfVersion(999, 0, version); !ok {
+		t.Error("InsertIfVersion failed with the version of a stored key")
+	}
+}
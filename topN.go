@@ -0,0 +1,64 @@
+package hashtable
+
+import (
+	"container/heap"
+	"slices"
+)
+
+// TopN returns the n entries with the largest values according to less, largest
+// first. It keeps a bounded heap of n entries while scanning the buckets, so it
+// costs O(Len log n) time and O(n) memory instead of sorting every entry.
+func (ht *HashTable[K, V]) TopN(n int, less func(a, b V) bool) []Entry[K, V] {
+	if n <= 0 {
+		return nil
+	}
+
+	// h is a min-heap, so its root is the smallest of the current top n
+	h := &entryHeap[K, V]{less: less}
+	for key, value := range ht.All() {
+		if len(h.entries) < n {
+			heap.Push(h, Entry[K, V]{Key: key, Value: value})
+		} else if less(h.entries[0].Value, value) {
+			h.entries[0] = Entry[K, V]{Key: key, Value: value}
+			heap.Fix(h, 0)
+		}
+	}
+
+	top := h.entries
+	slices.SortFunc(top, func(a, b Entry[K, V]) int {
+		switch {
+		case less(b.Value, a.Value):
+			return -1
+		case less(a.Value, b.Value):
+			return 1
+		}
+		return 0
+	})
+	return top
+}
+
+// entryHeap implements heap.Interface as a min-heap of entries ordered by value.
+type entryHeap[K any, V any] struct {
+	entries []Entry[K, V]
+	less    func(a, b V) bool
+}
+
+func (h *entryHeap[K, V]) Len() int { return len(h.entries) }
+
+func (h *entryHeap[K, V]) Less(i, j int) bool {
+	return h.less(h.entries[i].Value, h.entries[j].Value)
+}
+
+func (h *entryHeap[K, V]) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+}
+
+func (h *entryHeap[K, V]) Push(x any) {
+	h.entries = append(h.entries, x.(Entry[K, V]))
+}
+
+func (h *entryHeap[K, V]) Pop() any {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
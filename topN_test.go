@@ -0,0 +1,30 @@
+package hashtable
+
+import "testing"
+
+func TestHashTable_TopN(t *testing.T) {
+	ht := New[string, int](4)
+	scores := map[string]int{"ann": 7, "bob": 3, "cat": 9, "dan": 1, "eve": 5}
+	for k, v := range scores {
+		ht.Insert(k, v)
+	}
+	less := func(a, b int) bool { return a < b }
+
+	top := ht.TopN(3, less)
+	want := []string{"cat", "ann", "eve"}
+	if len(top) != len(want) {
+		t.Fatalf("len(TopN(3)) = %d, want %d", len(top), len(want))
+	}
+	for i, e := range top {
+		if e.Key != want[i] || e.Value != scores[want[i]] {
+			t.Errorf("TopN(3)[%d] = %v, want %s", i, e, want[i])
+		}
+	}
+
+	if all := ht.TopN(10, less); len(all) != len(scores) {
+		t.Fatalf("len(TopN(10)) = %d, want %d", len(all), len(scores))
+	}
+	if none := ht.TopN(0, less); len(none) != 0 {
+		t.Fatalf("len(TopN(0)) = %d, want 0", len(none))
+	}
+}
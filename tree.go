@@ -0,0 +1,185 @@
+package hashtable
+
+// A chained bucket holding more than treeifyThreshold entries is indexed by a
+// balanced tree when the table has a key ordering. The index is dropped again once
+// the bucket holds untreeifyThreshold entries or fewer; the gap stops a bucket
+// whose length hovers around the threshold from being indexed over and over.
+const (
+	treeifyThreshold   = 8
+	untreeifyThreshold = 6
+)
+
+// treeNode is a node of an AVL tree indexing the entries of one chained bucket by
+// hash, then by key. Nodes hold the index of their entry in the bucket rather than
+// the entry itself, so the bucket remains the only place entries are stored.
+type treeNode struct {
+	index       int
+	height      int
+	left, right *treeNode
+}
+
+// An order compares the entry being looked for with the entry at index of a bucket,
+// returning a negative number, zero or a positive number as it sorts before, with
+// or after it.
+type order func(index int) int
+
+func height(t *treeNode) int {
+	if t == nil {
+		return 0
+	}
+	return t.height
+}
+
+func (t *treeNode) fix() {
+	t.height = 1 + max(height(t.left), height(t.right))
+}
+
+func rotateRight(t *treeNode) *treeNode {
+	l := t.left
+	t.left = l.right
+	t.fix()
+	l.right = t
+	l.fix()
+	return l
+}
+
+func rotateLeft(t *treeNode) *treeNode {
+	r := t.right
+	t.right = r.left
+	t.fix()
+	r.left = t
+	r.fix()
+	return r
+}
+
+// balance restores the AVL property at t, whose subtrees differ in height by at
+// most two, and returns the new root of the subtree.
+func balance(t *treeNode) *treeNode {
+	t.fix()
+	switch b := height(t.left) - height(t.right); {
+	case b > 1:
+		if height(t.left.left) < height(t.left.right) {
+			t.left = rotateLeft(t.left)
+		}
+		return rotateRight(t)
+	case b < -1:
+		if height(t.right.right) < height(t.right.left) {
+			t.right = rotateRight(t.right)
+		}
+		return rotateLeft(t)
+	}
+	return t
+}
+
+// treeFind returns the node of t that o matches, or nil.
+func treeFind(t *treeNode, o order) *treeNode {
+	for t != nil {
+		switch c := o(t.index); {
+		case c < 0:
+			t = t.left
+		case c > 0:
+			t = t.right
+		default:
+			return t
+		}
+	}
+	return nil
+}
+
+// treeInsert adds a node for the entry at index, which o orders, and returns the
+// new root.
+func treeInsert(t *treeNode, index int, o order) *treeNode {
+	if t == nil {
+		return &treeNode{index: index, height: 1}
+	}
+	if o(t.index) < 0 {
+		t.left = treeInsert(t.left, index, o)
+	} else {
+		t.right = treeInsert(t.right, index, o)
+	}
+	return balance(t)
+}
+
+// treeDelete removes the node that o matches and returns the new root.
+func treeDelete(t *treeNode, o order) *treeNode {
+	if t == nil {
+		return nil
+	}
+	switch c := o(t.index); {
+	case c < 0:
+		t.left = treeDelete(t.left, o)
+	case c > 0:
+		t.right = treeDelete(t.right, o)
+	default:
+		if t.left == nil {
+			return t.right
+		}
+		if t.right == nil {
+			return t.left
+		}
+		var next *treeNode
+		t.right, next = treeDeleteMin(t.right)
+		next.left, next.right = t.left, t.right
+		t = next
+	}
+	return balance(t)
+}
+
+// treeDeleteMin removes the first node of t and returns the new root and the node.
+func treeDeleteMin(t *treeNode) (*treeNode, *treeNode) {
+	if t.left == nil {
+		return t.right, t
+	}
+	var first *treeNode
+	t.left, first = treeDeleteMin(t.left)
+	return balance(t), first
+}
+
+// orderOf returns the order of the entry for key, whose hash is h, in bucket.
+func (c *chained[K, V]) orderOf(bucket []kv[K, V], key K, h uint64) order {
+	return func(index int) int {
+		switch data := &bucket[index]; {
+		case h < data.hash:
+			return -1
+		case h > data.hash:
+			return 1
+		default:
+			return c.compare(key, data.Key)
+		}
+	}
+}
+
+// treeify indexes bucket b if it has grown past treeifyThreshold.
+func (c *chained[K, V]) treeify(b int) {
+	bucket := c.table[b]
+	if c.compare == nil || c.trees[b] != nil || len(bucket) <= treeifyThreshold {
+		return
+	}
+	var root *treeNode
+	for n := range bucket {
+		root = treeInsert(root, n, c.orderOf(bucket, bucket[n].Key, bucket[n].hash))
+	}
+	c.trees[b] = root
+}
+
+// resetTrees drops every bucket index, for a table whose buckets were replaced.
+func (c *chained[K, V]) resetTrees() {
+	if c.compare != nil && !c.sorted {
+		c.trees = make([]*treeNode, len(c.table))
+	}
+}
+
+// untrack removes entry n of the indexed bucket b from its tree before removeAt
+// moves entry last into its place, dropping the index once the bucket is short.
+func (c *chained[K, V]) untrack(b, n, last int) {
+	bucket := c.table[b]
+	if last <= untreeifyThreshold {
+		c.trees[b] = nil
+		return
+	}
+	root := treeDelete(c.trees[b], c.orderOf(bucket, bucket[n].Key, bucket[n].hash))
+	if n != last {
+		treeFind(root, c.orderOf(bucket, bucket[last].Key, bucket[last].hash)).index = n
+	}
+	c.trees[b] = root
+}
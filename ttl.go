@@ -0,0 +1,215 @@
+package hashtable
+
+import (
+	"math"
+	"time"
+)
+
+// ttlEpoch is the origin of the deadlines of entries given a TTL, which are kept as
+// nanoseconds since then by the monotonic clock, so that changes to the wall clock
+// do not affect them.
+var ttlEpoch = time.Now()
+
+// timeNow returns the current time. Tests replace it to control the clock.
+var timeNow = time.Now
+
+// now returns the current time in nanoseconds since ttlEpoch.
+func now() int64 {
+	return int64(timeNow().Sub(ttlEpoch))
+}
+
+// An expiry is the TTL of a key and the time it runs out, in nanoseconds since
+// ttlEpoch.
+type expiry struct {
+	deadline int64
+	ttl      time.Duration
+}
+
+// deadline returns the time, in nanoseconds since ttlEpoch, at which a TTL of d
+// starting now runs out, saturating rather than overflowing for long TTLs.
+func deadline(d time.Duration) int64 {
+	t := now()
+	if int64(d) > math.MaxInt64-t {
+		return math.MaxInt64
+	}
+	return t + int64(d)
+}
+
+// InsertWithTTL stores value for key like Insert, and makes the entry expire once d
+// has elapsed, so the table can serve as a session or token store. From then on,
+// lookups such as Search and Contains treat key as absent, and the first of them,
+// or the first write of key, reclaims the entry; until then, methods that visit
+// every entry, such as Len and All, still count it. The TTL belongs to the key:
+// methods that store a new value for a key already stored, such as Insert, keep its
+// deadline, while InsertWithTTL replaces it. A d that is not positive makes the
+// entry expire at once. DeleteExpired, or a janitor started with StartJanitor of
+// SyncHashTable, reclaims expired entries that are not looked up again.
+func (ht *HashTable[K, V]) InsertWithTTL(key K, value V, d time.Duration) {
+	key, h, e := ht.find(key)
+	if e != nil {
+		e.Value = value
+	} else {
+		ht.add(key, h, value)
+	}
+	ht.setTTL(key, d)
+}
+
+// setTTL gives key, which must be normalized, a TTL of d starting now.
+func (ht *HashTable[K, V]) setTTL(key K, d time.Duration) {
+	if ht.deadlines == nil {
+		ht.deadlines = newScratch[K, V, expiry](ht)
+	}
+	ht.deadlines.Insert(key, expiry{deadline: deadline(d), ttl: d})
+}
+
+// Touch restarts the TTL of key from now, as if its entry had just been inserted
+// with InsertWithTTL, so that an entry in use, such as an active session, does not
+// expire. It reports whether key is stored with a TTL.
+func (ht *HashTable[K, V]) Touch(key K) bool {
+	key, _, e := ht.find(key)
+	if e == nil || ht.deadlines == nil {
+		return false
+	}
+	x, ok := ht.deadlines.Search(key)
+	if ok {
+		ht.setTTL(key, x.ttl)
+	}
+	return ok
+}
+
+// Expire gives the entry of key a TTL of d starting now, replacing any TTL it had,
+// as InsertWithTTL would without changing the value. It reports whether key is
+// stored.
+func (ht *HashTable[K, V]) Expire(key K, d time.Duration) bool {
+	key, _, e := ht.find(key)
+	if e == nil {
+		return false
+	}
+	ht.setTTL(key, d)
+	return true
+}
+
+// Persist removes the TTL of key, so that its entry no longer expires. It reports
+// whether key is stored with a TTL.
+func (ht *HashTable[K, V]) Persist(key K) bool {
+	key, _, e := ht.find(key)
+	if e == nil || ht.deadlines == nil {
+		return false
+	}
+	_, ok := ht.deadlines.Delete(key)
+	return ok
+}
+
+// WithDefaultTTL makes the HashTable give every key it adds a TTL of d, as if it
+// were inserted with InsertWithTTL, so that no entry outlives d unless given a
+// longer TTL. InsertWithTTL sets a TTL of its own instead. A ReadMostlyHashTable
+// ignores this option.
+func WithDefaultTTL[K any, V any](d time.Duration) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.defaultTTL = d
+	}
+}
+
+// DeleteExpired removes every entry whose TTL has run out and reports how many
+// were removed. It takes time in proportion to the number of keys given a TTL.
+func (ht *HashTable[K, V]) DeleteExpired() int {
+	if ht.deadlines == nil {
+		return 0
+	}
+	t, removed := now(), 0
+	ht.deadlines.DeleteFunc(func(key K, x expiry) bool {
+		if t < x.deadline {
+			return false
+		}
+		// deadlines may outlive their keys, which are not counted
+		if data, ok := ht.store.remove(ht.hash(key)); ok {
+			removed++
+			ht.evicted(data.Key, data.Value, EvictExpired)
+		}
+		return true
+	})
+	return removed
+}
+
+// expired reports whether the TTL of key, which must be normalized, has run out.
+func (ht *HashTable[K, V]) expired(key K) bool {
+	if ht.deadlines == nil {
+		return false
+	}
+	x, ok := ht.deadlines.Search(key)
+	return ok && now() >= x.deadline
+}
+
+// cloneDeadlines returns a copy of the deadlines of ht, for a table holding its
+// keys.
+func (ht *HashTable[K, V]) cloneDeadlines() *HashTable[K, expiry] {
+	if ht.deadlines == nil {
+		return nil
+	}
+	return ht.deadlines.Clone()
+}
+
+// DeleteExpired is like HashTable.DeleteExpired, holding the lock throughout.
+func (s *SyncHashTable[K, V]) DeleteExpired() int {
+	s.lock()
+	defer s.mu.Unlock()
+	return s.ht.DeleteExpired()
+}
+
+// InsertWithTTL is like HashTable.InsertWithTTL.
+func (s *SyncHashTable[K, V]) InsertWithTTL(key K, value V, d time.Duration) {
+	s.lock()
+	defer s.mu.Unlock()
+	s.ht.InsertWithTTL(key, value, d)
+	s.touch(key)
+}
+
+// Touch is like HashTable.Touch.
+func (s *SyncHashTable[K, V]) Touch(key K) bool {
+	s.lock()
+	defer s.mu.Unlock()
+	return s.ht.Touch(key)
+}
+
+// Expire is like HashTable.Expire.
+func (s *SyncHashTable[K, V]) Expire(key K, d time.Duration) bool {
+	s.lock()
+	defer s.mu.Unlock()
+	return s.ht.Expire(key, d)
+}
+
+// Persist is like HashTable.Persist.
+func (s *SyncHashTable[K, V]) Persist(key K) bool {
+	s.lock()
+	defer s.mu.Unlock()
+	return s.ht.Persist(key)
+}
+
+// InsertWithTTL is like HashTable.InsertWithTTL.
+func (s *ShardedHashTable[K, V]) InsertWithTTL(key K, value V, d time.Duration) {
+	s.shard(key).InsertWithTTL(key, value, d)
+}
+
+// DeleteExpired is like HashTable.DeleteExpired, locking one shard at a time.
+func (s *ShardedHashTable[K, V]) DeleteExpired() int {
+	removed := 0
+	for _, shard := range s.shards {
+		removed += shard.DeleteExpired()
+	}
+	return removed
+}
+
+// Touch is like HashTable.Touch.
+func (s *ShardedHashTable[K, V]) Touch(key K) bool {
+	return s.shard(key).Touch(key)
+}
+
+// Expire is like HashTable.Expire.
+func (s *ShardedHashTable[K, V]) Expire(key K, d time.Duration) bool {
+	return s.shard(key).Expire(key, d)
+}
+
+// Persist is like HashTable.Persist.
+func (s *ShardedHashTable[K, V]) Persist(key K) bool {
+	return s.shard(key).Persist(key)
+}
@@ -0,0 +1,243 @@
+package hashtable
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeClock makes the TTL clock stand still until advanced, for the duration of
+// the test.
+func fakeClock(t *testing.T) (advance func(time.Duration)) {
+	current := ttlEpoch
+	timeNow = func() time.Time { return current }
+	t.Cleanup(func() { timeNow = time.Now })
+	return func(d time.Duration) { current = current.Add(d) }
+}
+
+func TestInsertWithTTL(t *testing.T) {
+	advance := fakeClock(t)
+	forEachBackend(t, func(t *testing.T, newTable func() *HashTable[int, int]) {
+		ht := newTable()
+		for k := 0; k < 100; k++ {
+			if k%2 == 0 {
+				ht.InsertWithTTL(k, k, time.Minute)
+			} else {
+				ht.Insert(k, k)
+			}
+		}
+		// replacing a value keeps the TTL, and InsertWithTTL replaces it
+		ht.Insert(0, -1)
+		ht.InsertWithTTL(2, 2, time.Hour)
+		ht.InsertWithTTL(4, 4, math.MaxInt64)
+		advance(time.Minute)
+		defer advance(-time.Minute)
+
+		for k := 0; k < 100; k++ {
+			live := k%2 == 1 || k == 2 || k == 4
+			if _, ok := ht.Search(k); ok != live {
+				t.Fatalf("Search(%d) found %v, want %v", k, ok, live)
+			}
+		}
+		// the lookups above reclaimed every expired entry
+		if ht.Len() != 52 {
+			t.Errorf("Len() = %d after looking up every key, want 52", ht.Len())
+		}
+		// a key added again after expiring has no TTL
+		ht.Insert(0, 0)
+		advance(time.Hour)
+		defer advance(-time.Hour)
+		if !ht.Contains(0) || ht.Contains(2) || !ht.Contains(4) {
+			t.Errorf("Contains(0, 2, 4) = %v, %v, %v; want true, false, true", ht.Contains(0), ht.Contains(2), ht.Contains(4))
+		}
+	})
+}
+
+func TestInsertWithTTL_Methods(t *testing.T) {
+	advance := fakeClock(t)
+	ht := New[string, int](4)
+	ht.InsertWithTTL("a", 1, time.Second)
+	ht.InsertWithTTL("b", 2, time.Second)
+	ht.InsertWithTTL("c", 3, time.Second)
+	clone := ht.Clone()
+	advance(time.Second)
+
+	if _, ok := ht.Delete("a"); ok {
+		t.Errorf("Delete reported an expired key as stored")
+	}
+	if v, loaded := ht.GetOrInsert("b", 20); loaded || v != 20 {
+		t.Errorf("GetOrInsert on an expired key = %d, %v; want 20, false", v, loaded)
+	}
+	if v := ht.Update("c", func(v int) int { return v + 1 }); v != 1 {
+		t.Errorf("Update on an expired key started from %d, want the zero value", v-1)
+	}
+	if clone.Contains("a") || clone.GetOrDefault("b", -1) != -1 {
+		t.Errorf("a clone does not keep the TTLs of its entries")
+	}
+
+	frozen := clone.Freeze()
+	s := NewSync[string, int](4)
+	s.InsertWithTTL("d", 4, time.Second)
+	if _, ok := frozen.Search("c"); ok || !s.Contains("d") {
+		t.Errorf("frozen Search found an expired key, or Sync Contains missed a live one")
+	}
+	advance(time.Second)
+	if _, ok := s.Search("d"); ok || s.Len() != 1 {
+		t.Errorf("Sync Search found an expired key, or reclaimed it under the read lock")
+	}
+	s.Insert("d", 5)
+	if v, ok := s.Search("d"); !ok || v != 5 || s.Len() != 1 {
+		t.Errorf("Sync Search(d) = %d, %v after reinserting the expired key", v, ok)
+	}
+}
+
+func TestDiff_Expired(t *testing.T) {
+	advance := fakeClock(t)
+	a := New[string, int](4)
+	a.Insert("same", 1)
+	a.InsertWithTTL("gone", 2, time.Second)
+	b := New[string, int](4)
+	b.Insert("same", 1)
+	b.InsertWithTTL("gone", 2, time.Second)
+	b.InsertWithTTL("stale", 3, time.Second)
+	advance(time.Second)
+
+	// expired keys are missing from both tables, and stay in them
+	diff := Diff(a, b)
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Errorf("Diff = %+v, want no differences", diff)
+	}
+	if diff := Diff(b, a); len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Errorf("reversed Diff = %+v, want no differences", diff)
+	}
+	if a.Len() != 2 || b.Len() != 3 {
+		t.Errorf("Len() = %d, %d after Diff, want 2, 3", a.Len(), b.Len())
+	}
+}
+
+func TestWithDefaultTTL(t *testing.T) {
+	advance := fakeClock(t)
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			ht := New(4, append(slices.Clone(b.opts), WithDefaultTTL[int, int](time.Minute))...)
+			for k := 0; k < 100; k++ {
+				ht.Insert(k, k)
+			}
+			ht.InsertWithTTL(0, 0, time.Hour)
+			advance(time.Second)
+			defer advance(-time.Second)
+			// a new value does not restart the default TTL
+			ht.Insert(1, -1)
+			filtered := ht.Filter(func(k, v int) bool { return k < 10 })
+			filtered.Insert(100, 100)
+			advance(time.Minute)
+			defer advance(-time.Minute)
+
+			if n := ht.DeleteExpired(); n != 99 {
+				t.Errorf("DeleteExpired() = %d, want 99", n)
+			}
+			if ht.Len() != 1 || !ht.Contains(0) {
+				t.Errorf("Len() = %d after DeleteExpired, want only the key with a longer TTL", ht.Len())
+			}
+			if n := ht.DeleteExpired(); n != 0 {
+				t.Errorf("DeleteExpired() = %d with nothing expired, want 0", n)
+			}
+			// the filtered table gives its own keys the default TTL too
+			if n := filtered.DeleteExpired(); n != 10 || !filtered.Contains(0) {
+				t.Errorf("DeleteExpired() of a filtered table = %d, want 10", n)
+			}
+		})
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	advance := fakeClock(t)
+	s := NewSync[int, int](4)
+	sharded := NewSharded[int, int](4, 4)
+	for k := 0; k < 100; k++ {
+		s.InsertWithTTL(k, k, time.Duration(k)*time.Second)
+		sharded.InsertWithTTL(k, k, time.Duration(k)*time.Second)
+	}
+	// a key that expired and was stored again without a TTL is not removed, and
+	// keys already reclaimed are not counted
+	s.Insert(100, 100)
+	sharded.Insert(100, 100)
+	advance(10 * time.Second)
+	s.Delete(0)
+	s.Insert(1, 1)
+	sharded.Delete(0)
+
+	if n := s.DeleteExpired(); n != 9 {
+		t.Errorf("Sync DeleteExpired() = %d, want 9", n)
+	}
+	if n := sharded.DeleteExpired(); n != 10 {
+		t.Errorf("Sharded DeleteExpired() = %d, want 10", n)
+	}
+	if s.Len() != 91 || sharded.Len() != 90 {
+		t.Errorf("Len() = %d, %d after DeleteExpired, want 91, 90", s.Len(), sharded.Len())
+	}
+}
+
+func TestTouchExpirePersist(t *testing.T) {
+	advance := fakeClock(t)
+	forEachBackend(t, func(t *testing.T, newTable func() *HashTable[int, int]) {
+		ht := newTable()
+		for k := 0; k < 20; k++ {
+			ht.InsertWithTTL(k, k, time.Minute)
+		}
+		ht.Insert(20, 20)
+		if ht.Touch(20) || ht.Persist(20) || ht.Touch(21) || ht.Expire(21, time.Second) || ht.Persist(21) {
+			t.Fatalf("Touch, Expire or Persist reported an entry without a TTL as having one")
+		}
+		advance(30 * time.Second)
+		defer advance(-30 * time.Second)
+		for k := 0; k < 20; k++ {
+			var ok bool
+			switch k % 4 {
+			case 0:
+				ok = ht.Touch(k)
+			case 1:
+				ok = ht.Expire(k, time.Second)
+			case 2:
+				ok = ht.Persist(k)
+			default:
+				continue
+			}
+			if !ok {
+				t.Fatalf("key %d reported absent or without a TTL", k)
+			}
+		}
+		ht.Expire(20, 10*time.Second)
+		advance(40 * time.Second)
+		defer advance(-40 * time.Second)
+
+		// touched keys expire a minute after Touch, and persisted ones never; key 20
+		// expired 10s after the TTL Expire gave it
+		for k := 0; k <= 20; k++ {
+			live := k < 20 && (k%4 == 0 || k%4 == 2)
+			if ht.Contains(k) != live {
+				t.Errorf("Contains(%d) = %v, want %v", k, !live, live)
+			}
+		}
+		if ht.Touch(1) || ht.Expire(3, time.Minute) || ht.Persist(3) {
+			t.Errorf("Touch, Expire or Persist reported an expired key as stored")
+		}
+		advance(time.Minute)
+		defer advance(-time.Minute)
+		if ht.Contains(0) || !ht.Contains(2) {
+			t.Errorf("Contains(0), Contains(2) = %v, %v; want false, true", ht.Contains(0), ht.Contains(2))
+		}
+	})
+
+	s := NewSharded[string, int](4, 4)
+	s.InsertWithTTL("a", 1, time.Second)
+	s.InsertWithTTL("b", 2, time.Second)
+	if !s.Touch("a") || !s.Persist("b") || !s.Expire("a", 2*time.Second) {
+		t.Fatalf("Sharded Touch, Expire or Persist reported a key as absent")
+	}
+	advance(time.Second)
+	if !s.Contains("a") || !s.Contains("b") {
+		t.Errorf("Sharded Expire or Persist did not extend a TTL")
+	}
+}
@@ -0,0 +1,194 @@
+package hashtable
+
+// A Txn is a transaction on a SyncHashTable or ShardedHashTable, passed to the
+// function given to their Txn method. Puts and deletes are buffered in the Txn and
+// become visible to other goroutines all at once when the function returns nil;
+// when it returns an error, none of them are applied. Gets see the writes made
+// earlier in the same transaction.
+//
+// Each shard the transaction touches is locked from its first use until the
+// transaction ends, so other goroutines cannot observe or change the keys it has
+// read or written in between. A ShardedHashTable may have to call the function
+// more than once, as described for ShardedHashTable.Txn. A Txn must not be used
+// after its function returns, nor by other goroutines.
+type Txn[K any, V any] struct {
+	shards []*SyncHashTable[K, V]
+	// pick returns the index of the shard that stores a key.
+	pick func(key K) int
+	// held records the shards whose locks the transaction holds, and top the
+	// highest index among them, or -1.
+	held []bool
+	top  int
+	// writes holds the buffered writes for each held shard, or nil.
+	writes []*HashTable[K, txnWrite[V]]
+	// retry is the shard whose lock could not be taken in order, which the next
+	// attempt locks up front.
+	retry int
+}
+
+// A txnWrite is a buffered put, or a delete when deleted is set.
+type txnWrite[V any] struct {
+	value   V
+	deleted bool
+}
+
+// txnRestart is panicked by a Txn that must release its locks and start over, and
+// recovered by runTxn.
+type txnRestart struct{}
+
+// runTxn runs fn as a transaction over shards, applying its writes if it returns
+// nil. Locks are only ever waited for in increasing shard order, so concurrent
+// transactions cannot deadlock: a transaction that needs a lower shard than one it
+// already holds takes it only if it is free, and otherwise releases every lock,
+// takes them all again in order, along with the new one, and calls fn again.
+func runTxn[K any, V any](shards []*SyncHashTable[K, V], pick func(K) int, fn func(tx *Txn[K, V]) error) error {
+	tx := &Txn[K, V]{
+		shards: shards,
+		pick:   pick,
+		held:   make([]bool, len(shards)),
+		top:    -1,
+		writes: make([]*HashTable[K, txnWrite[V]], len(shards)),
+		retry:  -1,
+	}
+	defer tx.unlock()
+	for {
+		err, restarted := tx.attempt(fn)
+		if restarted {
+			tx.relock()
+			continue
+		}
+		if err == nil {
+			tx.commit()
+		}
+		return err
+	}
+}
+
+// attempt calls fn, reporting whether it was stopped to be restarted.
+func (tx *Txn[K, V]) attempt(fn func(tx *Txn[K, V]) error) (err error, restarted bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(txnRestart); !ok {
+				panic(r)
+			}
+			restarted = true
+		}
+	}()
+	return fn(tx), false
+}
+
+// relock discards the buffered writes, releases every lock and takes those locks
+// and that of the shard in tx.retry again, in increasing order.
+func (tx *Txn[K, V]) relock() {
+	held := tx.held
+	for i, ok := range held {
+		if ok {
+			tx.shards[i].mu.Unlock()
+		}
+	}
+	held[tx.retry] = true
+	tx.retry = -1
+	clear(tx.writes)
+	tx.top = -1
+	for i, ok := range held {
+		if ok {
+			tx.shards[i].acquire()
+			tx.top = i
+		}
+	}
+}
+
+// unlock releases every lock the transaction holds.
+func (tx *Txn[K, V]) unlock() {
+	for i, ok := range tx.held {
+		if ok {
+			tx.shards[i].mu.Unlock()
+			tx.held[i] = false
+		}
+	}
+}
+
+// commit applies the buffered writes to their shards.
+func (tx *Txn[K, V]) commit() {
+	for i, writes := range tx.writes {
+		if writes == nil {
+			continue
+		}
+		shard := tx.shards[i]
+		shard.own()
+		writes.store.all(func(data *kv[K, txnWrite[V]]) bool {
+			if data.Value.deleted {
+				if _, ok := shard.ht.Delete(data.Key); ok {
+					shard.forget(data.Key)
+				}
+			} else {
+				shard.ht.Insert(data.Key, data.Value.value)
+				shard.touch(data.Key)
+			}
+			return true
+		})
+	}
+}
+
+// lock locks the shard of key unless the transaction holds it already, and returns
+// its index. It panics with txnRestart if the lock cannot be taken in order.
+func (tx *Txn[K, V]) lock(key K) int {
+	i := tx.pick(key)
+	if tx.held[i] {
+		return i
+	}
+	shard := tx.shards[i]
+	if i > tx.top {
+		shard.acquire()
+	} else if shard.mu.TryLock() {
+		shard.locks.Add(1)
+	} else {
+		shard.contended.Add(1)
+		tx.retry = i
+		panic(txnRestart{})
+	}
+	tx.held[i] = true
+	tx.top = max(tx.top, i)
+	return i
+}
+
+// buffer returns the buffered writes of shard i, which the transaction must hold,
+// creating them if need be.
+func (tx *Txn[K, V]) buffer(i int) *HashTable[K, txnWrite[V]] {
+	if tx.writes[i] == nil {
+		tx.writes[i] = newScratch[K, V, txnWrite[V]](tx.shards[i].ht)
+	}
+	return tx.writes[i]
+}
+
+// Get returns the value of key, as written earlier in the transaction or else as
+// stored in the table.
+func (tx *Txn[K, V]) Get(key K) (V, bool) {
+	i := tx.lock(key)
+	if writes := tx.writes[i]; writes != nil {
+		if w, ok := writes.Search(key); ok {
+			if w.deleted {
+				var zero V
+				return zero, false
+			}
+			return w.value, true
+		}
+	}
+	// the shard may share its table with a Clone, which lock does not copy, so
+	// leave an expired entry for a write to reclaim
+	return tx.shards[i].ht.peek(key)
+}
+
+// Put stores value for key when the transaction commits.
+func (tx *Txn[K, V]) Put(key K, value V) {
+	i := tx.lock(key)
+	tx.buffer(i).Insert(key, txnWrite[V]{value: value})
+}
+
+// Delete removes key when the transaction commits. It reports whether key is
+// stored, as seen by Get.
+func (tx *Txn[K, V]) Delete(key K) bool {
+	_, ok := tx.Get(key)
+	tx.buffer(tx.lock(key)).Insert(key, txnWrite[V]{deleted: true})
+	return ok
+}
@@ -0,0 +1,139 @@
+package hashtable
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTxn(t *testing.T) {
+	const accounts, balance = 64, 100
+	s := NewSharded[int, int](8, 64)
+	for a := 0; a < accounts; a++ {
+		s.Insert(a, balance)
+	}
+
+	// total reads every account in a random order, so that it locks shards out of
+	// order and has to restart
+	total := func() int {
+		sum := 0
+		if err := s.Txn(func(tx *Txn[int, int]) error {
+			sum = 0
+			for _, a := range rand.Perm(accounts) {
+				v, _ := tx.Get(a)
+				sum += v
+			}
+			return nil
+		}); err != nil {
+			t.Error(err)
+		}
+		return sum
+	}
+
+	errOverdrawn := errors.New("overdrawn")
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				from, to, amount := rand.IntN(accounts), rand.IntN(accounts), rand.IntN(50)
+				err := s.Txn(func(tx *Txn[int, int]) error {
+					a, _ := tx.Get(from)
+					if a < amount {
+						return errOverdrawn
+					}
+					tx.Put(from, a-amount)
+					b, _ := tx.Get(to)
+					tx.Put(to, b+amount)
+					return nil
+				})
+				if err != nil && err != errOverdrawn {
+					t.Error(err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if sum := total(); sum != accounts*balance {
+					t.Errorf("a transaction saw a total of %d, want %d", sum, accounts*balance)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if sum := total(); sum != accounts*balance {
+		t.Fatalf("total after transfers = %d, want %d", sum, accounts*balance)
+	}
+}
+
+func TestTxn_Rollback(t *testing.T) {
+	s := NewSync[string, int](4)
+	s.Insert("a", 1)
+	errAbort := errors.New("abort")
+	err := s.Txn(func(tx *Txn[string, int]) error {
+		tx.Put("b", 2)
+		if !tx.Delete("a") {
+			t.Error(`Delete("a") reported a missing key`)
+		}
+		if _, ok := tx.Get("a"); ok {
+			t.Error("Get saw a key deleted earlier in the transaction")
+		}
+		if v, ok := tx.Get("b"); !ok || v != 2 {
+			t.Errorf(`Get("b") = %d, %v inside the transaction`, v, ok)
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("Txn returned %v, want %v", err, errAbort)
+	}
+	if v, ok := s.Search("a"); !ok || v != 1 || s.Contains("b") {
+		t.Fatalf("a failed transaction changed the table: %v", s.Entries())
+	}
+
+	if err := s.Txn(func(tx *Txn[string, int]) error {
+		tx.Put("b", 2)
+		tx.Delete("a")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.Search("b"); v != 2 || s.Contains("a") {
+		t.Fatalf("a committed transaction left %v", s.Entries())
+	}
+
+	// a panicking transaction releases its locks
+	func() {
+		defer func() { recover() }()
+		s.Txn(func(tx *Txn[string, int]) error {
+			tx.Put("c", 3)
+			panic("boom")
+		})
+	}()
+	if s.Contains("c") {
+		t.Fatal("a panicking transaction was committed")
+	}
+}
+
+func TestTxn_Clone(t *testing.T) {
+	advance := fakeClock(t)
+	s := NewSync[string, int](4)
+	s.InsertWithTTL("a", 1, time.Second)
+	s.Insert("b", 2)
+	clone := s.Clone()
+	advance(time.Second)
+	s.Txn(func(tx *Txn[string, int]) error {
+		if _, ok := tx.Get("a"); ok {
+			t.Error(`Get("a") found an expired key`)
+		}
+		return nil
+	})
+	// the expired entry is still in the table the clone shares, not reclaimed by Get
+	if n := clone.ht.store.len(); n != 2 {
+		t.Fatalf("the clone holds %d entries after a Txn of the original, want 2", n)
+	}
+}
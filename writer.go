@@ -0,0 +1,102 @@
+package hashtable
+
+// A Change is a write made to a table, passed to its Writer: the value stored for
+// Key, or its deletion when Deleted is set.
+type Change[K any, V any] struct {
+	Key     K
+	Value   V
+	Deleted bool
+}
+
+// A Writer persists the changes made to a SyncHashTable or ShardedHashTable, so the
+// table can front a database: the table serves reads, and the Writer copies every
+// write to the database, as set up by WithWriteThrough or WithWriteBehind.
+//
+// Write is called with changes in the order they were made to the table, and must
+// not retain the slice. It must not call methods of the table, whose lock may be
+// held. A Writer shared by the shards of a ShardedHashTable is called by each of
+// them on its own, so it must be safe for concurrent use.
+type Writer[K any, V any] interface {
+	Write(changes []Change[K, V]) error
+}
+
+// writeOptions are the settings of WithWriteThrough and WithWriteBehind.
+type writeOptions[K any, V any] struct {
+	writer  Writer[K, V]
+	onError func(changes []Change[K, V], err error)
+	// behind makes the writes go through a queue instead of being made by the
+	// method changing the table.
+	behind bool
+}
+
+// WithWriteThrough makes a SyncHashTable, or every shard of a ShardedHashTable,
+// pass each change made by a method that stores or deletes a key, such as Insert,
+// Update or Delete, to w before the method returns, with the lock of the table
+// held, so that w sees the changes in the order they were made. Entries removed by
+// Clear, by WithMaxEntries or because their TTL ran out are not written, since
+// they leave the table rather than the data it fronts. If w fails, the change is
+// kept in the table and onError, unless nil, is called with it and the error.
+// Tables created by methods such as Clone do not write to w. A HashTable ignores
+// this option.
+func WithWriteThrough[K any, V any](w Writer[K, V], onError func(changes []Change[K, V], err error)) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.writes = &writeOptions[K, V]{writer: w, onError: onError}
+	}
+}
+
+// WithWriteBehind is like WithWriteThrough, but the changes are queued and written
+// in batches by a goroutine started with the first change, so that writes to the
+// table do not wait for w. A method waits only while 4096 changes are already
+// queued. Flush waits until the changes queued so far have been written, and Close
+// writes them and stops the goroutine, after which changes are written through.
+func WithWriteBehind[K any, V any](w Writer[K, V], onError func(changes []Change[K, V], err error)) Option[K, V] {
+	return func(ht *HashTable[K, V]) {
+		ht.writes = &writeOptions[K, V]{writer: w, onError: onError, behind: true}
+	}
+}
+
+// write passes changes to the Writer, and its error, if any, to onError.
+func (o *writeOptions[K, V]) write(changes []Change[K, V]) {
+	if err := o.writer.Write(changes); err != nil && o.onError != nil {
+		o.onError(changes, err)
+	}
+}
+
+// record passes change to the Writer of s, if it has one, through the write-behind
+// queue if there is one. The caller must hold the write lock.
+func (s *SyncHashTable[K, V]) record(change Change[K, V]) {
+	if s.writes == nil {
+		return
+	}
+	if s.writes.behind {
+		if w := s.writeBehind(); w != nil && w.queue(change) {
+			return
+		}
+	}
+	s.writes.write([]Change[K, V]{change})
+}
+
+// writeBehind returns the queue of the changes s writes behind, starting it on
+// first use, or nil if closeWrites was called before any use.
+func (s *SyncHashTable[K, V]) writeBehind() *asyncApplier[Change[K, V]] {
+	s.behindOnce.Do(func() {
+		s.behind.Store(startAsync(s.writes.write))
+	})
+	return s.behind.Load()
+}
+
+// flushWrites waits until the changes queued by s have been written.
+func (s *SyncHashTable[K, V]) flushWrites() {
+	if w := s.behind.Load(); w != nil {
+		w.flush()
+	}
+}
+
+// closeWrites writes the changes queued by s and stops the goroutine writing them.
+func (s *SyncHashTable[K, V]) closeWrites() {
+	// without a queue yet, keep record from starting one
+	s.behindOnce.Do(func() {})
+	if w := s.behind.Load(); w != nil {
+		w.close()
+	}
+}
@@ -0,0 +1,159 @@
+package hashtable
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingWriter is a Writer keeping the changes written to it, or failing with
+// err while it is set.
+type recordingWriter[K any, V any] struct {
+	mu      sync.Mutex
+	changes []Change[K, V]
+	batches int
+	err     error
+}
+
+func (w *recordingWriter[K, V]) Write(changes []Change[K, V]) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.changes = append(w.changes, changes...)
+	w.batches++
+	return nil
+}
+
+func TestWithWriteThrough(t *testing.T) {
+	w := &recordingWriter[string, int]{}
+	var failed []Change[string, int]
+	s := NewSync(4, WithKeyNormalizer[string, int](strings.ToLower), WithWriteThrough(w, func(changes []Change[string, int], err error) {
+		failed = append(failed, changes...)
+	}))
+	s.Insert("A", 1)
+	s.Update("a", func(v int) int { return v + 1 })
+	s.ComputeIfAbsent("b", func(string) int { return 3 })
+	s.ComputeIfAbsent("b", func(string) int { return 4 })
+	s.GetOrInsert("b", 5)
+	s.CompareAndDeleteFunc("b", 4, func(a, b int) bool { return a == b })
+	s.Delete("B")
+	s.Txn(func(tx *Txn[string, int]) error {
+		tx.Put("c", 6)
+		return nil
+	})
+	s.DeleteFunc(func(k string, v int) bool { return k == "c" })
+	want := []Change[string, int]{
+		{Key: "a", Value: 1},
+		{Key: "a", Value: 2},
+		{Key: "b", Value: 3},
+		{Key: "b", Deleted: true},
+		{Key: "c", Value: 6},
+		{Key: "c", Deleted: true},
+	}
+	if !slices.Equal(w.changes, want) {
+		t.Fatalf("wrote %v, want %v", w.changes, want)
+	}
+
+	// a clone writes nothing, and neither does Clear
+	clone := s.Clone()
+	clone.Insert("d", 7)
+	s.Clear()
+	if len(w.changes) != len(want) {
+		t.Errorf("wrote %v after Clone and Clear", w.changes[len(want):])
+	}
+	w.err = errors.New("unavailable")
+	s.Insert("e", 8)
+	if !s.Contains("e") || !slices.Equal(failed, []Change[string, int]{{Key: "e", Value: 8}}) {
+		t.Errorf("a failed write reported %v, want it kept and reported", failed)
+	}
+}
+
+func TestWithWriteBehind(t *testing.T) {
+	const goroutines, perGoroutine = 8, 2000
+	w := &recordingWriter[int, int]{}
+	s := NewSharded(4, 16, WithWriteBehind[int, int](w, nil))
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				k := (g*perGoroutine + i) % 1000
+				if i%5 == 4 {
+					s.Delete(k)
+				} else {
+					s.Insert(k, i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	s.Flush()
+
+	// replaying the changes written rebuilds the table
+	w.mu.Lock()
+	replayed := make(map[int]int)
+	inserts := 0
+	for _, c := range w.changes {
+		if c.Deleted {
+			delete(replayed, c.Key)
+		} else {
+			replayed[c.Key] = c.Value
+			inserts++
+		}
+	}
+	// every insert is written, and only the deletes of keys that were stored
+	if want := goroutines * perGoroutine * 4 / 5; inserts != want || w.batches >= len(w.changes) {
+		t.Errorf("wrote %d inserts in %d changes and %d batches, want %d inserts batched", inserts, len(w.changes), w.batches, want)
+	}
+	w.mu.Unlock()
+	if got := maps.Collect(s.All()); !maps.Equal(got, replayed) {
+		t.Errorf("replaying the changes written gives %d entries, want the %d of the table", len(replayed), len(got))
+	}
+
+	// after Close, changes are written right away
+	s.Close()
+	s.Insert(-1, -1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if last := w.changes[len(w.changes)-1]; last != (Change[int, int]{Key: -1, Value: -1}) {
+		t.Errorf("last change written after Close is %v", last)
+	}
+}
+
+func TestWithWriteBehind_CloseUnused(t *testing.T) {
+	w := &recordingWriter[int, int]{}
+	s := NewSync(4, WithWriteBehind[int, int](w, nil))
+	// Close before any change starts no goroutine, and later changes are written
+	// through
+	s.Close()
+	if s.behind.Load() != nil {
+		t.Fatal("Close started the write-behind queue")
+	}
+	s.Insert(1, 1)
+	if s.behind.Load() != nil || len(w.changes) != 1 {
+		t.Fatalf("Insert after Close queued its change, or wrote %d changes, want 1", len(w.changes))
+	}
+}
+
+func TestWithWriteThrough_DeleteMissing(t *testing.T) {
+	w := &recordingWriter[int, int]{}
+	s := NewSync(4, WithWriteThrough[int, int](w, nil))
+	s.Insert(1, 1)
+	// deleting a key that is not stored, directly or in a transaction, writes nothing
+	s.Delete(2)
+	s.Txn(func(tx *Txn[int, int]) error {
+		tx.Delete(3)
+		return nil
+	})
+	s.Delete(1)
+	want := []Change[int, int]{{Key: 1, Value: 1}, {Key: 1, Deleted: true}}
+	if !slices.Equal(w.changes, want) {
+		t.Errorf("wrote %v, want %v", w.changes, want)
+	}
+}